@@ -0,0 +1,352 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// ========= AKUN & PERAN (SATU NIP, BANYAK PERAN) =========
+
+// PeranAkun adalah satu peran (profil UserOPD / UserPemda) yang dimiliki akun.
+type PeranAkun struct {
+	Role      string `json:"role"`       // "opd" / "pemda"
+	ID        uint   `json:"id"`         // ID UserOPD / UserPemda
+	KodePeran string `json:"kode_peran"` // Peran RBAC, mis. "validator"
+	IDOPD     uint   `json:"id_opd"`
+	NamaOPD   string `json:"opd"`
+	NIP       string `json:"nip"`
+	Nama      string `json:"nama"`
+	Jabatan   string `json:"jabatan"`
+	Aktif     bool   `json:"-"` // false jika profil dinonaktifkan atau dihapus
+}
+
+// claims menyusun Claims access token untuk peran ini.
+func (p PeranAkun) claims(idAkun uint) *Claims {
+	return &Claims{ID: p.ID, IDOPD: p.IDOPD, NIP: p.NIP, Nama: p.Nama, Jabatan: p.Jabatan, Role: p.Role, Peran: p.KodePeran, IDAkun: idAkun}
+}
+
+func peranDariUserOPD(user UserOPD) PeranAkun {
+	return PeranAkun{Role: "opd", ID: user.ID, KodePeran: user.KodePeran, IDOPD: user.IDOPD, NamaOPD: user.OPD.NamaOPD, NIP: user.NIP, Nama: user.Nama, Jabatan: user.Jabatan,
+		Aktif: user.Aktif && user.DihapusPada == nil}
+}
+
+func peranDariUserPemda(user UserPemda) PeranAkun {
+	return PeranAkun{Role: "pemda", ID: user.ID, KodePeran: user.KodePeran, NamaOPD: "Pemerintah Daerah", NIP: user.NIP, Nama: user.Nama, Jabatan: user.Jabatan,
+		Aktif: user.Aktif && user.DihapusPada == nil}
+}
+
+// kondisiProfilAktif adalah kondisi SQL untuk profil yang belum dinonaktifkan maupun dihapus.
+const kondisiProfilAktif = "aktif = true AND dihapus_pada IS NULL"
+
+// daftarPeranAkun mengambil semua peran aktif milik akun (peran OPD lebih dulu).
+// Profil yang dinonaktifkan atau dihapus tidak dapat dipakai untuk login maupun ganti peran.
+func daftarPeranAkun(idAkun uint) ([]PeranAkun, error) {
+	var usersOPD []UserOPD
+	if err := DB.Preload("OPD").Where("id_akun = ?", idAkun).Where(kondisiProfilAktif).Order("id_user_opd").Find(&usersOPD).Error; err != nil {
+		return nil, err
+	}
+	var usersPemda []UserPemda
+	if err := DB.Where("id_akun = ?", idAkun).Where(kondisiProfilAktif).Order("id_user_pemda").Find(&usersPemda).Error; err != nil {
+		return nil, err
+	}
+
+	peran := make([]PeranAkun, 0, len(usersOPD)+len(usersPemda))
+	for _, u := range usersOPD {
+		peran = append(peran, peranDariUserOPD(u))
+	}
+	for _, u := range usersPemda {
+		peran = append(peran, peranDariUserPemda(u))
+	}
+	return peran, nil
+}
+
+// adaProfilNonaktif memeriksa apakah akun memiliki profil yang dinonaktifkan admin (belum dihapus).
+func adaProfilNonaktif(idAkun uint) bool {
+	var jumlahOPD, jumlahPemda int64
+	DB.Model(&UserOPD{}).Where("id_akun = ? AND aktif = false AND dihapus_pada IS NULL", idAkun).Count(&jumlahOPD)
+	DB.Model(&UserPemda{}).Where("id_akun = ? AND aktif = false AND dihapus_pada IS NULL", idAkun).Count(&jumlahPemda)
+	return jumlahOPD+jumlahPemda > 0
+}
+
+// peranUntukUser mengambil satu peran berdasarkan role dan ID profil, beserta ID akunnya.
+// Profil nonaktif / terhapus tetap dikembalikan (Aktif = false) untuk keperluan admin.
+func peranUntukUser(role string, id uint) (PeranAkun, uint, error) {
+	switch role {
+	case "opd":
+		var user UserOPD
+		if err := DB.Preload("OPD").First(&user, id).Error; err != nil {
+			return PeranAkun{}, 0, err
+		}
+		return peranDariUserOPD(user), user.IDAkun, nil
+	case "pemda":
+		var user UserPemda
+		if err := DB.First(&user, id).Error; err != nil {
+			return PeranAkun{}, 0, err
+		}
+		return peranDariUserPemda(user), user.IDAkun, nil
+	}
+	return PeranAkun{}, 0, errors.New("role harus opd atau pemda")
+}
+
+// cariPeran memilih peran dengan role tertentu. id = 0 hanya diterima jika
+// akun memiliki tepat satu peran dengan role tersebut.
+func cariPeran(daftar []PeranAkun, role string, id uint) (PeranAkun, error) {
+	var cocok []PeranAkun
+	for _, p := range daftar {
+		if p.Role == role && (id == 0 || p.ID == id) {
+			cocok = append(cocok, p)
+		}
+	}
+	switch len(cocok) {
+	case 0:
+		return PeranAkun{}, errors.New("Akun tidak memiliki peran " + role)
+	case 1:
+		return cocok[0], nil
+	}
+	return PeranAkun{}, errors.New("Akun memiliki lebih dari satu peran " + role + ", sertakan id peran")
+}
+
+// siapkanAkun mengambil akun untuk NIP, atau membuat akun baru jika belum ada.
+// Password hanya dipakai (dan wajib) saat akun baru dibuat.
+// Nilai bool bernilai true jika akun baru dibuat.
+func siapkanAkun(tx *gorm.DB, nip, password string) (*Akun, bool, error) {
+	var akun Akun
+	err := tx.Where("nip = ?", nip).First(&akun).Error
+	if err == nil {
+		return &akun, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if password == "" {
+		return nil, false, errParameter("Password wajib diisi untuk NIP yang belum memiliki akun")
+	}
+	if err := kebijakanPassword().Validasi(password, nip); err != nil {
+		return nil, false, errParameter(err.Error())
+	}
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return nil, false, err
+	}
+	akun = Akun{NIP: nip, Password: hashed}
+	if err := tx.Create(&akun).Error; err != nil {
+		return nil, false, err
+	}
+	return &akun, true, nil
+}
+
+// ----- Tiket: JWT berumur pendek untuk langkah login lanjutan (2FA, pilih peran) -----
+
+// klaimTiket adalah payload tiket. Tiket memakai aud audTiketLogin dan tidak memiliki sid
+// sehingga tidak dapat dipakai sebagai access token oleh AuthMiddleware.
+type klaimTiket struct {
+	IDAkun  uint   `json:"aid"`
+	Tujuan  string `json:"tujuan"`         // mis. "2fa" / "pilih_peran"
+	Role    string `json:"role,omitempty"` // Peran yang diminta saat login, diteruskan ke langkah berikutnya
+	IDPeran uint   `json:"id_peran,omitempty"`
+	jwt.RegisteredClaims
+}
+
+const tujuanPilihPeran = "pilih_peran"
+
+// terbitkanTiket membuat tiket sekali pakai untuk akun (umur env LOGIN_TIKET_TTL, default 5 menit).
+// role / idPeran opsional, dipakai jika user sudah memilih peran sebelum langkah lanjutan.
+func terbitkanTiket(idAkun uint, tujuan, role string, idPeran uint) (string, error) {
+	jti, err := tokenAcak(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	klaim := klaimTiket{
+		IDAkun:  idAkun,
+		Tujuan:  tujuan,
+		Role:    role,
+		IDPeran: idPeran,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Audience:  jwt.ClaimStrings{audTiketLogin},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(envDurasi("LOGIN_TIKET_TTL", 5*time.Minute))),
+		},
+	}
+	return tandatanganiJWT(klaim)
+}
+
+// periksaTiket memverifikasi tiket untuk tujuan tertentu tanpa mencabutnya.
+func periksaTiket(tiket, tujuan string) (*klaimTiket, error) {
+	klaim := &klaimTiket{}
+	token, err := parseJWT(tiket, klaim, audTiketLogin)
+	if err != nil || !token.Valid || klaim.Tujuan != tujuan || klaim.ID == "" || tokenDicabut(klaim.ID) {
+		return nil, errors.New("Tiket login tidak valid atau kedaluwarsa, silakan login kembali")
+	}
+	return klaim, nil
+}
+
+// bacaTiket memverifikasi tiket untuk tujuan tertentu lalu mencabutnya (sekali pakai).
+func bacaTiket(tiket, tujuan string) (*klaimTiket, error) {
+	klaim, err := periksaTiket(tiket, tujuan)
+	if err != nil {
+		return nil, err
+	}
+	if err := cabutJTI(DB, klaim.ID, klaim.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+	return klaim, nil
+}
+
+// PilihPeranHandler: POST /api/login/pilih-peran
+// Langkah kedua login untuk akun dengan lebih dari satu peran.
+func PilihPeranHandler(c *gin.Context) {
+	var req PilihPeranRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Tiket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tiket dan role wajib diisi"})
+		return
+	}
+
+	klaim, err := bacaTiket(req.Tiket, tujuanPilihPeran)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var akun Akun
+	if err := DB.First(&akun, klaim.IDAkun).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Akun tidak ditemukan"})
+		return
+	}
+	if akun.WajibGantiPassword {
+		tolakWajibGantiPassword(c, akun.NIP)
+		return
+	}
+
+	daftar, err := daftarPeranAkun(akun.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat peran akun"})
+		return
+	}
+	peran, err := cariPeran(daftar, req.Role, req.ID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Println("[LOGIN SUCCESS] Role:", peran.Role, ", Nama:", peran.Nama, ", OPD:", peran.NamaOPD)
+	generateTokenAndRespond(c, &akun, peran)
+}
+
+// SwitchRole: POST /api/me/switch-role
+// Mengganti peran aktif pada sesi saat ini dan menerbitkan ulang access token.
+func SwitchRole(c *gin.Context) {
+	var req PilihPeranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role wajib diisi"})
+		return
+	}
+
+	userClaims, _ := c.Get("user")
+	claims := userClaims.(*Claims)
+
+	daftar, err := daftarPeranAkun(claims.IDAkun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat peran akun"})
+		return
+	}
+	peran, err := cariPeran(daftar, req.Role, req.ID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sesi Sesi
+	if err := DB.First(&sesi, claims.IDSesi).Error; err != nil || sesi.DicabutPada != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi sudah berakhir, silakan login kembali"})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&sesi).Updates(map[string]interface{}{"role": peran.Role, "id_user": peran.ID}).Error; err != nil {
+			return err
+		}
+		// Access token dengan peran lama tidak berlaku lagi
+		if err := cabutJTI(tx, claims.RegisteredClaims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "sesi.ganti_peran", targetUser(peran.Role, peran.ID),
+			fmt.Sprintf("Peran aktif diganti dari %s", targetUser(claims.Role, claims.ID)))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengganti peran"})
+		return
+	}
+
+	klaimBaru := peran.claims(claims.IDAkun)
+	accessToken, err := terbitkanAccessToken(klaimBaru, &sesi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
+		return
+	}
+
+	setCookieAkses(c, peran.Role, accessToken)
+	responLogin(c, klaimBaru, peran, daftar)
+}
+
+// migrasiAkun memindahkan password dari user_opd / user_pemda (skema lama) ke tabel akun,
+// menautkan setiap profil ke akunnya berdasarkan NIP, lalu menghapus kolom lama.
+// Jika NIP yang sama ada di kedua tabel, password user_opd yang dipakai.
+func migrasiAkun() {
+	m := DB.Migrator()
+	for _, t := range []struct {
+		model interface{}
+		tabel string
+		pk    string
+		role  string
+	}{{&UserOPD{}, "user_opd", "id_user_opd", "opd"}, {&UserPemda{}, "user_pemda", "id_user_pemda", "pemda"}} {
+		if m.HasColumn(t.model, "password") {
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				wajibGanti := "false"
+				if m.HasColumn(t.model, "wajib_ganti_password") {
+					wajibGanti = "wajib_ganti_password"
+				}
+
+				var bentrok int64
+				tx.Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s u JOIN akun a ON a.nip = u.nip WHERE a.password <> u.password", t.tabel)).Scan(&bentrok)
+				if bentrok > 0 {
+					log.Printf("⚠ %d NIP di %s sudah memiliki akun dengan password berbeda; password akun yang ada dipertahankan", bentrok, t.tabel)
+				}
+
+				if err := tx.Exec(fmt.Sprintf(`INSERT INTO akun (nip, password, wajib_ganti_password, created_at, updated_at)
+					SELECT nip, password, %s, created_at, NOW() FROM %s
+					ON CONFLICT (nip) DO NOTHING`, wajibGanti, t.tabel)).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(fmt.Sprintf(`UPDATE %s u SET id_akun = a.id_akun FROM akun a
+					WHERE a.nip = u.nip AND (u.id_akun IS NULL OR u.id_akun = 0)`, t.tabel)).Error; err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(t.model, "password"); err != nil {
+					return err
+				}
+				if wajibGanti != "false" {
+					return tx.Migrator().DropColumn(t.model, "wajib_ganti_password")
+				}
+				return nil
+			})
+			if err != nil {
+				log.Fatal("❌ Migrasi akun "+t.tabel+" gagal: ", err)
+			}
+			log.Println("✅ Password", t.tabel, "dipindahkan ke tabel akun")
+		}
+
+		// Sesi lama (sebelum ada akun) ditautkan ke akun pemilik profil
+		DB.Exec(fmt.Sprintf(`UPDATE sesi s SET id_akun = u.id_akun FROM %s u
+			WHERE s.role = ? AND s.id_user = u.%s AND (s.id_akun IS NULL OR s.id_akun = 0)`, t.tabel, t.pk), t.role)
+	}
+}
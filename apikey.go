@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= API KEY UNTUK KLIEN MESIN =========
+//
+// API key dikirim lewat header "Authorization: Bearer bpk_...". Kunci bertindak atas
+// nama profil akun layanan (UserOPD / UserPemda) sehingga data yang dibuat tetap
+// memiliki petugas penginput. Izin efektif adalah irisan izin kunci dan izin peran
+// akun layanan, sehingga menurunkan peran akun ikut membatasi kuncinya.
+
+const prefixAPIKey = "bpk_"
+
+// izinTerlarangAPIKey tidak dapat diberikan ke API key agar kunci tidak dapat
+// dipakai untuk menerbitkan kunci lain atau mengubah hak akses.
+var izinTerlarangAPIKey = []string{izinSemua, "api_key.manage", "peran.manage", "user.peran"}
+
+// intervalCatatPemakaian membatasi penulisan terakhir_dipakai_pada agar tidak terjadi
+// satu UPDATE untuk setiap request.
+const intervalCatatPemakaian = time.Minute
+
+// bacaAPIKey memverifikasi API key dan membentuk Claims akun layanannya.
+func bacaAPIKey(kunci, ip string) (*Claims, bool) {
+	var key APIKey
+	if err := DB.Preload("Izin").Where("kunci_hash = ? AND dicabut_pada IS NULL", hashToken(kunci)).First(&key).Error; err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	if key.KedaluwarsaPada != nil && now.After(*key.KedaluwarsaPada) {
+		return nil, false
+	}
+	peran, idAkun, err := peranUntukUser(key.Role, key.IDUser)
+	if err != nil || !peran.Aktif {
+		return nil, false
+	}
+
+	claims := peran.claims(idAkun)
+	claims.Nama = peran.Nama + " (API key: " + key.Nama + ")" // Terbaca di log audit dan riwayat pengajuan
+	claims.IDAPIKey = key.ID
+	if key.IDOPD != nil && key.Role == "pemda" {
+		claims.IDOPD = *key.IDOPD
+	}
+	for _, iz := range key.Izin {
+		claims.IzinKunci = append(claims.IzinKunci, iz.Izin)
+	}
+
+	if key.TerakhirDipakaiPada == nil || now.Sub(*key.TerakhirDipakaiPada) > intervalCatatPemakaian {
+		if err := DB.Model(&key).Updates(map[string]interface{}{
+			"terakhir_dipakai_pada": now,
+			"terakhir_dipakai_ip":   ip,
+		}).Error; err != nil {
+			log.Println("[API KEY] Gagal mencatat pemakaian:", err)
+		}
+	}
+	return claims, true
+}
+
+// isiDaftarIzinAPIKey mengisi DaftarIzin dari relasi Izin untuk response.
+func isiDaftarIzinAPIKey(key *APIKey) {
+	key.DaftarIzin = []string{}
+	for _, iz := range key.Izin {
+		key.DaftarIzin = append(key.DaftarIzin, iz.Izin)
+	}
+}
+
+// ----- Handler admin -----
+
+// kolomSortAPIKey: nilai ?sort= yang diizinkan untuk list API key
+var kolomSortAPIKey = map[string]string{
+	"created_at":            "created_at",
+	"nama":                  "nama",
+	"terakhir_dipakai_pada": "terakhir_dipakai_pada",
+}
+
+// GetAllAPIKey: GET /api/api-keys (?role=&id_user=&aktif=true|false)
+func GetAllAPIKey(c *gin.Context) {
+	query := DB.Model(&APIKey{})
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch c.Query("aktif") {
+	case "true":
+		query = query.Where("dicabut_pada IS NULL AND (kedaluwarsa_pada IS NULL OR kedaluwarsa_pada > ?)", time.Now())
+	case "false":
+		query = query.Where("dicabut_pada IS NOT NULL OR kedaluwarsa_pada <= ?", time.Now())
+	}
+	query, err := filterID(c, query, "id_user", "id_user")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := bindPaginasi(c, kolomSortAPIKey, "created_at", "DESC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	daftar := []APIKey{}
+	meta, err := ambilHalaman(query, p, "id_api_key", func(q *gorm.DB) *gorm.DB { return q.Preload("Izin") }, &daftar)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range daftar {
+		isiDaftarIzinAPIKey(&daftar[i])
+	}
+	responList(c, daftar, meta)
+}
+
+// CreateAPIKey: POST /api/api-keys
+// Kunci hanya ditampilkan sekali pada respons ini.
+func CreateAPIKey(c *gin.Context) {
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama, role, id_user, dan izin wajib diisi"})
+		return
+	}
+	if req.KedaluwarsaPada != nil && !req.KedaluwarsaPada.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Waktu kedaluwarsa harus di masa depan"})
+		return
+	}
+
+	peran, _, err := peranUntukUser(req.Role, req.IDUser)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Akun layanan tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !peran.Aktif {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Akun layanan sudah dinonaktifkan atau dihapus"})
+		return
+	}
+
+	// Kunci profil OPD selalu terbatas pada OPD profilnya.
+	if req.IDOPD != nil {
+		if req.Role == "opd" && *req.IDOPD != peran.IDOPD {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API key user OPD hanya dapat mengakses OPD user tersebut"})
+			return
+		}
+		var jumlah int64
+		DB.Model(&OPD{}).Where("id_opd = ?", *req.IDOPD).Count(&jumlah)
+		if jumlah == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "OPD tidak ditemukan"})
+			return
+		}
+	}
+
+	izin, err := validasiDaftarIzin(req.Role, req.Izin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(izin) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimal satu izin harus diberikan"})
+		return
+	}
+	for _, iz := range izin {
+		if mengandung(izinTerlarangAPIKey, iz) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Izin " + iz + " tidak dapat diberikan ke API key"})
+			return
+		}
+		dimiliki, err := izinCache.punya(peran.KodePeran, iz)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa izin peran"})
+			return
+		}
+		if !dimiliki {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Izin " + iz + " tidak dimiliki peran akun layanan (" + peran.KodePeran + ")"})
+			return
+		}
+	}
+
+	acak, err := tokenAcak(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat API key"})
+		return
+	}
+	kunci := prefixAPIKey + acak
+
+	key := APIKey{
+		Nama:            req.Nama,
+		Prefix:          kunci[:len(prefixAPIKey)+8],
+		KunciHash:       hashToken(kunci),
+		Role:            req.Role,
+		IDUser:          req.IDUser,
+		IDOPD:           req.IDOPD,
+		IDAkunPembuat:   ambilClaims(c).IDAkun,
+		KedaluwarsaPada: req.KedaluwarsaPada,
+	}
+	if req.Role == "opd" {
+		key.IDOPD = &peran.IDOPD
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		for _, iz := range izin {
+			if err := tx.Create(&IzinAPIKey{IDAPIKey: key.ID, Izin: iz}).Error; err != nil {
+				return err
+			}
+		}
+		return catatAudit(tx, c, "api_key.buat", fmt.Sprintf("api_key:%d", key.ID),
+			fmt.Sprintf("%s untuk %s, izin: %s", key.Nama, targetUser(key.Role, key.IDUser), strings.Join(izin, ", ")))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan API key"})
+		return
+	}
+
+	key.DaftarIzin = izin
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    key,
+		"kunci":   kunci,
+		"message": "Simpan API key ini sekarang; kunci tidak dapat ditampilkan lagi",
+	})
+}
+
+// CabutAPIKey: DELETE /api/api-keys/:id, menonaktifkan API key secara permanen
+func CabutAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID API key tidak valid"})
+		return
+	}
+	var key APIKey
+	if err := DB.First(&key, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key tidak ditemukan"})
+		return
+	}
+	if key.DicabutPada != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "API key sudah dicabut"})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&key).Update("dicabut_pada", time.Now()).Error; err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "api_key.cabut", fmt.Sprintf("api_key:%d", key.ID), key.Nama)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencabut API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API key berhasil dicabut"})
+}
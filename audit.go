@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= LOG AUDIT =========
+
+// catatAudit menyimpan satu baris log audit. Aktor diambil dari Claims jika request
+// sudah terautentikasi; untuk endpoint publik aktor dibiarkan kosong.
+func catatAudit(tx *gorm.DB, c *gin.Context, aksi, target, keterangan string) error {
+	log := LogAudit{
+		Aksi:       aksi,
+		Target:     target,
+		Keterangan: keterangan,
+		AlamatIP:   c.ClientIP(),
+	}
+	if userClaims, ok := c.Get("user"); ok {
+		claims := userClaims.(*Claims)
+		log.RoleAktor = claims.Role
+		log.IDAktor = claims.ID
+		log.NamaAktor = claims.Nama
+	}
+	return tx.Create(&log).Error
+}
+
+// kolomSortLogAudit: nilai ?sort= yang diizinkan untuk list log audit
+var kolomSortLogAudit = map[string]string{
+	"created_at": "created_at",
+	"aksi":       "aksi",
+}
+
+// GetAllLogAudit: Mendapatkan log audit (?aksi=&target=&role_aktor=&id_aktor=&created_from=&created_to=)
+func GetAllLogAudit(c *gin.Context) {
+	logs := []LogAudit{}
+
+	query := DB.Model(&LogAudit{})
+	if aksi := c.Query("aksi"); aksi != "" {
+		query = query.Where("aksi = ?", aksi)
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	if role := c.Query("role_aktor"); role != "" {
+		query = query.Where("role_aktor = ?", role)
+	}
+	query, err := filterID(c, query, "id_aktor", "id_aktor")
+	if err == nil {
+		query, err = filterRentangTanggal(c, query, "created_at")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := bindPaginasi(c, kolomSortLogAudit, "created_at", "DESC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := ambilHalaman(query, p, "id_log_audit", nil, &logs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	responList(c, logs, meta)
+}
@@ -1,34 +1,38 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"net/http"
-	"os"
-	
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-// jwtKey diambil dari environment variable untuk keamanan.
-var jwtKey = []byte(os.Getenv("JWT_SECRET_KEY"))
-
 // LoginRequest merepresentasikan body JSON yang diharapkan saat login.
 type LoginRequest struct {
 	NIP      string `json:"nip" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"` // Opsional: langsung memilih peran jika akun memiliki lebih dari satu peran
+	IDPeran  uint   `json:"id_peran"`
 }
 
 // Claims merepresentasikan data (payload) yang akan disimpan di dalam JWT.
 type Claims struct {
-	ID      uint   `json:"id"`
-	IDOPD   uint   `json:"id_opd"` // IDOPD disertakan untuk user dari OPD.
-	NIP     string `json:"nip"`
-	Nama    string `json:"nama"`
-	Jabatan string `json:"jabatan"`
-	Role    string `json:"role"`
+	ID        uint     `json:"id"`
+	IDOPD     uint     `json:"id_opd"` // IDOPD disertakan untuk user dari OPD.
+	NIP       string   `json:"nip"`
+	Nama      string   `json:"nama"`
+	Jabatan   string   `json:"jabatan"`
+	Role      string   `json:"role"`               // Jenis profil: "opd" / "pemda"
+	Peran     string   `json:"peran"`              // Peran RBAC, dasar pemeriksaan izin di RequirePermission
+	IDAkun    uint     `json:"aid"`                // Akun login; satu akun dapat memiliki beberapa peran
+	IDSesi    uint     `json:"sid"`                // ID sesi login; jti token ada di RegisteredClaims.ID
+	Terbatas  bool     `json:"terbatas,omitempty"` // Peran wajib 2FA tetapi 2FA belum aktif: hanya endpoint /api/me yang dapat diakses
+	IDAPIKey  uint     `json:"-"`                  // Diisi jika request memakai API key (bukan bagian dari JWT)
+	IzinKunci []string `json:"-"`                  // Izin milik API key; membatasi izin peran akun layanan
 	jwt.RegisteredClaims
 }
 
@@ -40,165 +44,314 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	// Coba cari pengguna di tabel user_opd terlebih dahulu.
-	var userOPD UserOPD
-	// [DIUBAH] Gunakan Preload("OPD") untuk mengambil data OPD terkait secara otomatis
-	if err := DB.Preload("OPD").Where("nip = ?", req.NIP).First(&userOPD).Error; err == nil {
-		if bcrypt.CompareHashAndPassword([]byte(userOPD.Password), []byte(req.Password)) == nil {
-			log.Println("[LOGIN SUCCESS] Role: OPD, Nama:", userOPD.Nama, ", OPD:", userOPD.OPD.NamaOPD)
-			// Kirim nama OPD ke fungsi generateToken
-			generateTokenAndRespond(c, userOPD.ID, userOPD.IDOPD, userOPD.NIP, userOPD.Nama, userOPD.Jabatan, "opd", userOPD.OPD.NamaOPD)
+	// Tolak lebih awal jika NIP atau IP ini sedang dalam masa tunggu / terkunci.
+	if sisa := cekKunciLogin(req.NIP, c.ClientIP()); sisa > 0 {
+		log.Println("[LOGIN DITOLAK] Terkunci, NIP:", req.NIP, ", IP:", c.ClientIP())
+		catatPercobaanLogin(c, req.NIP, false, "terkunci")
+		tolakLoginTerkunci(c, sisa)
+		return
+	}
+
+	// Cari akun berdasarkan NIP lalu cocokkan password. NIP yang tidak ditemukan tetap
+	// melewati bcrypt dan penghitung gagal yang sama agar tidak dapat dibedakan dari password salah.
+	var akun Akun
+	errAkun := DB.Where("nip = ?", req.NIP).First(&akun).Error
+	if !cocokkanPassword(akun.Password, req.Password) || errAkun != nil {
+		log.Println("[LOGIN FAILED] NIP:", req.NIP)
+		catatLoginGagal(req.NIP, c.ClientIP())
+		catatPercobaanLogin(c, req.NIP, false, "password_salah")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "NIP atau Password salah"})
+		return
+	}
+
+	if akun.WajibGantiPassword {
+		catatLoginBerhasil(req.NIP)
+		catatPercobaanLogin(c, req.NIP, false, "wajib_ganti_password")
+		tolakWajibGantiPassword(c, akun.NIP)
+		return
+	}
+
+	// Akun dengan 2FA aktif: cookie sesi baru diterbitkan setelah kode diverifikasi di POST /api/login/2fa.
+	// Penghitung gagal belum direset agar tebakan kode tetap dibatasi.
+	if akun.TOTPAktif {
+		tiket, err := terbitkanTiket(akun.ID, tujuan2FA, req.Role, req.IDPeran)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat tiket login"})
 			return
 		}
+		catatPercobaanLogin(c, req.NIP, true, "menunggu_2fa")
+		c.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"wajib_2fa": true,
+			"tiket":     tiket,
+		})
+		return
 	}
 
-	// Jika tidak ditemukan atau password salah, coba cari di tabel user_pemda.
-	var userPemda UserPemda
-	if err := DB.Where("nip = ?", req.NIP).First(&userPemda).Error; err == nil {
-		if bcrypt.CompareHashAndPassword([]byte(userPemda.Password), []byte(req.Password)) == nil {
-			log.Println("[LOGIN SUCCESS] Role: Pemda, Nama:", userPemda.Nama)
-			// Untuk Pemda, nama OPD bisa string kosong
-			generateTokenAndRespond(c, userPemda.ID, 0, userPemda.NIP, userPemda.Nama, userPemda.Jabatan, "pemda", "Pemerintah Daerah")
+	catatLoginBerhasil(req.NIP)
+	lanjutkanLogin(c, &akun, req.Role, req.IDPeran)
+}
+
+// lanjutkanLogin menyelesaikan login setelah semua faktor terverifikasi: langsung masuk
+// jika peran sudah jelas, atau meminta user memilih peran lewat POST /api/login/pilih-peran.
+func lanjutkanLogin(c *gin.Context, akun *Akun, role string, idPeran uint) {
+	daftar, err := daftarPeranAkun(akun.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat peran akun"})
+		return
+	}
+	if len(daftar) == 0 {
+		if adaProfilNonaktif(akun.ID) {
+			log.Println("[LOGIN DITOLAK] User dinonaktifkan, NIP:", akun.NIP)
+			catatPercobaanLogin(c, akun.NIP, false, "nonaktif")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Akun Anda telah dinonaktifkan. Hubungi admin Pemda.", "nonaktif": true})
 			return
 		}
+		catatPercobaanLogin(c, akun.NIP, false, "tanpa_peran")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akun belum memiliki peran OPD maupun Pemda"})
+		return
 	}
 
-	// Jika setelah semua pengecekan pengguna tidak ditemukan atau password salah.
-	log.Println("[LOGIN FAILED] NIP:", req.NIP)
-	c.JSON(http.StatusUnauthorized, gin.H{"error": "NIP atau Password salah"})
+	// Akun dengan satu peran (atau yang sudah memilih peran) langsung masuk.
+	if role != "" || len(daftar) == 1 {
+		peran := daftar[0]
+		if role != "" {
+			if peran, err = cariPeran(daftar, role, idPeran); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		catatPercobaanLogin(c, akun.NIP, true, "")
+		log.Println("[LOGIN SUCCESS] Role:", peran.Role, ", Nama:", peran.Nama, ", OPD:", peran.NamaOPD)
+		generateTokenAndRespond(c, akun, peran)
+		return
+	}
+
+	// Lebih dari satu peran: user memilih peran lewat POST /api/login/pilih-peran.
+	tiket, err := terbitkanTiket(akun.ID, tujuanPilihPeran, "", 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat tiket login"})
+		return
+	}
+	catatPercobaanLogin(c, akun.NIP, true, "pilih_peran")
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"pilih_peran": true,
+		"tiket":       tiket,
+		"peran":       daftar,
+	})
+}
+
+// tolakWajibGantiPassword menolak login user yang password-nya direset admin
+// sampai user mengatur password baru lewat token reset.
+func tolakWajibGantiPassword(c *gin.Context, nip string) {
+	log.Println("[LOGIN DITOLAK] Wajib ganti password, NIP:", nip)
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":                "Password Anda telah direset oleh admin. Silakan atur password baru menggunakan token reset.",
+		"wajib_ganti_password": true,
+	})
 }
 
+// LogoutHandler mencabut sesi saat ini (jika ada) lalu menghapus cookie otentikasi.
 func LogoutHandler(c *gin.Context) {
-	// Atur cookie opd_token dengan MaxAge negatif untuk menghapusnya
-	c.SetCookie(
-		"opd_token",
-		"",
-		-1, // MaxAge = -1 akan langsung menghapus cookie
-		"/",
-		"localhost",
-		false, // false untuk HTTP di development
-		true,  // httpOnly
-	)
-
-	// Atur juga cookie pemda_token untuk memastikan semua bersih
-	c.SetCookie(
-		"pemda_token",
-		"",
-		-1,
-		"/",
-		"localhost",
-		false,
-		true,
-	)
+	if refreshToken, err := c.Cookie(cookieRefresh); err == nil && refreshToken != "" {
+		if sesi, cocok, _, err := sesiDariRefreshToken(refreshToken); err == nil && cocok {
+			if err := cabutSesi(DB, sesi, "Logout"); err != nil {
+				log.Println("[LOGOUT] Gagal mencabut sesi:", err)
+			}
+		}
+	}
+
+	// Hapus cookie opd_token, pemda_token, dan refresh_token
+	hapusCookieAuth(c)
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Logout berhasil"})
 }
 
-// generateTokenAndRespond membuat JWT dan mengirimkannya sebagai respons JSON.
-func generateTokenAndRespond(c *gin.Context, id uint, idOPD uint, nip, nama, jabatan, role string,opdName string) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-
-	claims := &Claims{
-		ID:      id,
-		IDOPD:   idOPD,
-		NIP:     nip,
-		Nama:    nama,
-		Jabatan: jabatan,
-		Role:    role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+// generateTokenAndRespond membuat sesi baru untuk peran yang dipilih, menerbitkan access token
+// (JWT berumur pendek) dan refresh token sebagai cookie, lalu mengirimkan data user sebagai respons JSON.
+func generateTokenAndRespond(c *gin.Context, akun *Akun, peran PeranAkun) {
+	claims, err := mulaiSesi(c, akun, peran)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	daftar, _ := daftarPeranAkun(akun.ID)
+	responLogin(c, claims, peran, daftar)
+}
+
+// mulaiSesi membuat sesi login, lalu menyimpan access token, refresh token, dan token CSRF
+// sebagai cookie. Dipakai login NIP/password maupun login SSO.
+func mulaiSesi(c *gin.Context, akun *Akun, peran PeranAkun) (*Claims, error) {
+	claims := peran.claims(akun.ID)
+
+	sesi, refreshToken, err := buatSesi(c, akun.ID, peran.Role, peran.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Gagal membuat token"})
-		return
+		return nil, errors.New("Gagal membuat sesi")
+	}
+	tokenString, err := terbitkanAccessToken(claims, sesi)
+	if err != nil {
+		return nil, errors.New("Gagal membuat token")
 	}
 
-	// **[BARU]** Tentukan nama cookie dan path redirect berdasarkan role.
-	var cookieName string
-	var redirectPath string
-		domain := os.Getenv("APP_DOMAIN")
-		if domain == "" {
-			domain = "localhost"
-		}
-		isSecure := os.Getenv("GIN_MODE") == "release"
+	// Set access token & refresh token sebagai HttpOnly cookie di browser client.
+	// Ini adalah langkah kunci untuk Next.js middleware.
+	setCookieAuth(c, peran.Role, tokenString, refreshToken)
+	// Token CSRF baru setiap login; tidak dirotasi saat refresh agar tab lain tetap berfungsi
+	if _, err := setCookieCSRF(c); err != nil {
+		return nil, errors.New("Gagal membuat token CSRF")
+	}
+
+	now := time.Now()
+	if err := DB.Model(akun).Updates(map[string]interface{}{"terakhir_login_pada": now, "terakhir_login_ip": c.ClientIP()}).Error; err != nil {
+		log.Println("[LOGIN] Gagal mencatat waktu login terakhir:", err)
+	}
+	return claims, nil
+}
+
+// pathDashboard mengembalikan path halaman awal frontend untuk role.
+func pathDashboard(role string) string {
 	if role == "pemda" {
-		cookieName = "pemda_token"
-		redirectPath = "/pemda/dashboard"
-	} else { // Asumsi jika bukan pemda, pasti opd
-		cookieName = "opd_token"
-		redirectPath = "/opd/dashboard"
+		return "/pemda/dashboard"
 	}
+	return "/opd/dashboard"
+}
+
+// responLogin mengirim URL redirect, data user, dan daftar peran akun.
+// Token tidak dikirim di body JSON karena sudah ada di cookie.
+func responLogin(c *gin.Context, claims *Claims, peran PeranAkun, daftar []PeranAkun) {
+	// Tentukan path redirect berdasarkan role.
+	redirectPath := pathDashboard(peran.Role)
 
-	// **[BARU]** Set token sebagai HttpOnly cookie di browser client.
-	// Ini adalah langkah kunci untuk Next.js middleware.
-	c.SetCookie(
-		cookieName,
-		tokenString,
-		3600*24,      // maxAge
-		"/",          // path
-		domain,   // <-- Menjadi dinamis
-        isSecure,    // secure (false untuk development HTTP di localhost)
-		true,         // httpOnly
-	)
-
-	// **[BARU]** Kirim respons ke client berisi URL redirect dan data user.
-	// Kita tidak perlu lagi mengirim token di body JSON karena sudah ada di cookie.
 	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"redirect": redirectPath, // Frontend akan menggunakan ini untuk navigasi
-		"user": gin.H{
-			"id":      id,
-			"id_opd":  idOPD,
-			"nip":     nip,
-			"nama":    nama,
-			"jabatan": jabatan,
-			"role":    role,
-			"opd":     opdName, // <-- TAMBAHKAN NAMA OPD DI SINI
-		},
+		"success":            true,
+		"redirect":           redirectPath, // Frontend akan menggunakan ini untuk navigasi
+		"kedaluwarsa_pada":   claims.ExpiresAt.Time,
+		"user":               dataUser(claims, peran),
+		"wajib_aktifkan_2fa": claims.Terbatas, // Frontend mengarahkan user ke halaman pendaftaran 2FA
+		"csrf_token":         tokenCSRF(c),    // Dikirim ulang di header X-CSRF-Token untuk POST/PUT/DELETE
+		"peran":              daftar,          // Untuk menu ganti peran di frontend
 	})
 }
 
+// dataUser menyusun data user yang sedang login untuk respons login dan GET /api/me.
+func dataUser(claims *Claims, peran PeranAkun) gin.H {
+	return gin.H{
+		"id":      peran.ID,
+		"id_akun": claims.IDAkun,
+		"id_opd":  peran.IDOPD,
+		"nip":     peran.NIP,
+		"nama":    peran.Nama,
+		"jabatan": peran.Jabatan,
+		"role":    peran.Role,
+		"opd":     peran.NamaOPD,
+		"peran":   peran.KodePeran,
+		"izin":    izinPeran(peran.KodePeran), // Untuk menampilkan / menyembunyikan menu di frontend
+	}
+}
+
+// bacaAccessToken memverifikasi access token dan memastikan sesinya belum dicabut.
+func bacaAccessToken(tokenString string) (*Claims, bool) {
+	claims := &Claims{}
+	token, err := parseJWT(tokenString, claims, audAksesToken)
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	// Token tanpa sesi (format lama) atau yang sudah dicabut (logout / reset password) ditolak.
+	if claims.IDSesi == 0 || claims.IDAkun == 0 || claims.RegisteredClaims.ID == "" || tokenDicabut(claims.RegisteredClaims.ID) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// bacaHeaderBearer membaca token dari header "Authorization: Bearer <token>".
+// ada bernilai true jika header Authorization dikirim (valid atau tidak).
+func bacaHeaderBearer(c *gin.Context) (token string, ada bool) {
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	if header == "" {
+		return "", false
+	}
+	jenis, token, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(jenis, "Bearer") {
+		return "", true
+	}
+	return strings.TrimSpace(token), true
+}
+
 // AuthMiddleware adalah middleware untuk memverifikasi JWT dan hak akses (role).
+// Tanpa argumen, semua user yang login diterima; otorisasi per aksi dilakukan RequirePermission.
+// Klien mesin mengirim "Authorization: Bearer <access token | API key>"; jika header ini ada,
+// cookie tidak dibaca. Untuk browser, jika masih menyimpan cookie kedua role, dipakai cookie
+// pertama yang valid dan role-nya diizinkan, sehingga cookie usang tidak menutupi cookie yang benar.
 func AuthMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		  tokenString, err := c.Cookie("opd_token")
-        if err != nil {
-            tokenString, err = c.Cookie("pemda_token")
-            if err != nil {
-                c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token otentikasi tidak ditemukan di cookie"})
-                return
-            }
-        }
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
-
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid atau kedaluwarsa"})
+		if tokenString, ada := bacaHeaderBearer(c); ada {
+			var claims *Claims
+			ok := false
+			switch {
+			case tokenString == "":
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Format header Authorization harus \"Bearer <token>\""})
+				return
+			case strings.HasPrefix(tokenString, prefixAPIKey):
+				claims, ok = bacaAPIKey(tokenString, c.ClientIP())
+			default:
+				claims, ok = bacaAccessToken(tokenString)
+			}
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid, kedaluwarsa, atau sudah dicabut"})
+				return
+			}
+			if len(allowedRoles) > 0 && !mengandung(allowedRoles, claims.Role) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Anda tidak memiliki hak akses untuk sumber daya ini"})
+				return
+			}
+			c.Set("user", claims)
+			c.Next()
 			return
 		}
 
-		// Cek apakah role pengguna diizinkan mengakses endpoint ini.
-		isAllowed := false
-		for _, role := range allowedRoles {
-			if claims.Role == role {
-				isAllowed = true
-				break
+		adaToken, adaValid := false, false
+		for _, namaCookie := range []string{"opd_token", "pemda_token"} {
+			tokenString, err := c.Cookie(namaCookie)
+			if err != nil || tokenString == "" {
+				continue
+			}
+			adaToken = true
+
+			claims, ok := bacaAccessToken(tokenString)
+			if !ok {
+				continue
+			}
+			adaValid = true
+
+			// Cek apakah role pengguna diizinkan mengakses endpoint ini.
+			if len(allowedRoles) == 0 || mengandung(allowedRoles, claims.Role) {
+				// Simpan data user dari token ke dalam context untuk digunakan di handler selanjutnya.
+				c.Set("user", claims)
+				c.Next()
+				return
 			}
 		}
 
-		if !isAllowed {
+		switch {
+		case !adaToken:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token otentikasi tidak ditemukan di cookie"})
+		case !adaValid:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid, kedaluwarsa, atau sesi sudah berakhir"})
+		default:
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Anda tidak memiliki hak akses untuk sumber daya ini"})
-			return
 		}
+	}
+}
 
-		// Simpan data user dari token ke dalam context untuk digunakan di handler selanjutnya.
-		c.Set("user", claims)
+// WajibSesiLogin menolak API key pada endpoint yang mengelola akun / sesi milik user sendiri.
+func WajibSesiLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims := ambilClaims(c); claims == nil || claims.IDAPIKey != 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Endpoint ini hanya dapat diakses dengan sesi login, bukan API key"})
+			return
+		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -10,46 +10,117 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ========= HANDLERS REGISTRASI PENGGUNA (OPD & PEMDA) =========
 
+// CreateUserOPD: Mendaftarkan user OPD baru dengan password yang di-hash
 func CreateUserOPD(c *gin.Context) {
-	var user UserOPD
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req RegisterUserOPDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
 		return
 	}
 
-	// Di aplikasi nyata, HASHING PASSWORD WAJIB DILAKUKAN DI SINI!
-	// hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	// if err != nil { ... }
-	// user.Password = string(hashedPassword)
+	// Pastikan OPD tujuan ada
+	var opd OPD
+	if err := DB.First(&opd, req.IDOPD).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID OPD tidak valid"})
+		return
+	}
 
-	if err := DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var jumlah int64
+	DB.Model(&UserOPD{}).Where("nip = ?", req.NIP).Count(&jumlah)
+	if jumlah > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "NIP sudah terdaftar sebagai user OPD"})
+		return
+	}
+
+	if req.KodePeran == "" {
+		req.KodePeran = PeranOperatorOPD
+	}
+	// Memilih peran selain peran bawaan sama dengan mengubah peran user
+	if req.KodePeran != PeranOperatorOPD && !cekIzin(c, ambilClaims(c), "user.peran") {
+		return
+	}
+	if err := cekPeranUntukProfil(req.KodePeran, "opd"); err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Akun dibuat jika NIP belum punya akun; jika sudah ada, peran OPD ditambahkan ke akun tersebut
+	user := UserOPD{
+		IDOPD:     req.IDOPD,
+		Nama:      req.Nama,
+		NIP:       req.NIP,
+		Jabatan:   req.Jabatan,
+		KodePeran: req.KodePeran,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		akun, _, err := siapkanAkun(tx, req.NIP, req.Password)
+		if err != nil {
+			return err
+		}
+		user.IDAkun = akun.ID
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// Jangan pernah kirim password kembali ke client
-	user.Password = ""
+	// Password tersimpan di akun, tidak ikut terkirim
+	DB.Preload("OPD").First(&user, user.ID)
 	c.JSON(http.StatusCreated, user)
 }
 
+// CreateUserPemda: Mendaftarkan user Pemda baru (akun dibuat atau dipakai ulang berdasarkan NIP)
 func CreateUserPemda(c *gin.Context) {
-	var user UserPemda
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req RegisterUserPemdaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
 		return
 	}
 
-	// HASHING PASSWORD WAJIB
-	if err := DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var jumlah int64
+	DB.Model(&UserPemda{}).Where("nip = ?", req.NIP).Count(&jumlah)
+	if jumlah > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "NIP sudah terdaftar sebagai user Pemda"})
+		return
+	}
+
+	if req.KodePeran == "" {
+		req.KodePeran = PeranValidator
+	}
+	// Memilih peran selain peran bawaan sama dengan mengubah peran user
+	if req.KodePeran != PeranValidator && !cekIzin(c, ambilClaims(c), "user.peran") {
+		return
+	}
+	if err := cekPeranUntukProfil(req.KodePeran, "pemda"); err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	user := UserPemda{
+		Nama:      req.Nama,
+		NIP:       req.NIP,
+		Jabatan:   req.Jabatan,
+		KodePeran: req.KodePeran,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		akun, _, err := siapkanAkun(tx, req.NIP, req.Password)
+		if err != nil {
+			return err
+		}
+		user.IDAkun = akun.ID
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	user.Password = ""
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -68,13 +139,26 @@ func CreateOPD(c *gin.Context) {
 	c.JSON(http.StatusCreated, opd)
 }
 
+// kolomSortOPD: nilai ?sort= yang diizinkan untuk list OPD
+var kolomSortOPD = map[string]string{
+	"nama_opd": "nama_opd",
+	"id_opd":   "id_opd",
+}
+
 func GetAllOPD(c *gin.Context) {
-	var opds []OPD
-	if err := DB.Find(&opds).Error; err != nil {
+	opds := []OPD{}
+	p, err := bindPaginasi(c, kolomSortOPD, "nama_opd", "ASC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := ambilHalaman(DB.Model(&OPD{}), p, "id_opd", nil, &opds)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, opds)
+	responList(c, opds, meta)
 }
 
 // ========= CRUD HANDLERS: JENIS PELAYANAN (STANDAR PELAYANAN) =========
@@ -86,17 +170,55 @@ func CreateJenisPelayanan(c *gin.Context) {
 		return
 	}
 
-	// Otorisasi sederhana: Pastikan IDOPD valid
+	// Standar selalu dibuat untuk OPD user yang login
+	if standar.IDOPD != 0 && !cekMilikOPD(c, standar.IDOPD) {
+		return
+	}
+	standar.IDOPD = ambilClaims(c).IDOPD
 	var opd OPD
 	if err := DB.First(&opd, standar.IDOPD).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID OPD tidak valid"})
 		return
 	}
 
-	// Set default status validasi
-	standar.StatusValidasi = "Menunggu Validasi"
+	// Lengkapi durasi SLA terstruktur dari teks WaktuPelayanan (jika belum diisi)
+	normalisasiWaktuPelayanan(&standar)
+	if !satuanWaktuValid(standar.WaktuPelayananSatuan) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Satuan waktu pelayanan harus hari_kerja, hari_kalender, atau jam"})
+		return
+	}
 
-	if err := DB.Create(&standar).Error; err != nil {
+	// Persyaratan terstruktur (opsional); teks Persyaratan disusun ulang dari item
+	if len(standar.DaftarPersyaratan) > 0 {
+		items, err := normalisasiDaftarPersyaratan(standar.DaftarPersyaratan)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		standar.DaftarPersyaratan = items
+		standar.Persyaratan = teksPersyaratan(items)
+	}
+
+	// Set default status validasi
+	standar.StatusValidasi = StatusValidasiMenunggu
+	standar.JumlahRevisi, standar.DiajukanUlangPada, standar.KomentarRevisi = 0, nil, nil
+	standar.VersiAktif, standar.VersiTerbaru = 0, 0
+
+	claims := ambilClaims(c)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&standar).Error; err != nil {
+			return err
+		}
+		// Isi awal tercatat sebagai versi 1
+		if err := buatVersiPertama(tx, &standar, &claims.ID); err != nil {
+			return err
+		}
+		return beritahuPemegangIzin(tx, "pemda", "standar.validate", 0,
+			"standar.diajukan", "Standar pelayanan baru menunggu validasi",
+			"Standar '"+standar.NamaStandar+"' diajukan oleh "+claims.Nama+" ("+opd.NamaOPD+")",
+			targetStandar(standar.ID))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -105,30 +227,80 @@ func CreateJenisPelayanan(c *gin.Context) {
 	c.JSON(http.StatusCreated, standar)
 }
 
+// kolomSortJenisPelayanan: nilai ?sort= yang diizinkan untuk list standar pelayanan
+var kolomSortJenisPelayanan = map[string]string{
+	"created_at":   "created_at",
+	"nama_standar": "nama_standar",
+	"status":       "status_validasi",
+}
+
+// GetAllJenisPelayanan: ?status=&id_opd=&created_from=&created_to=&termasuk_diarsipkan= + paginasi
 func GetAllJenisPelayanan(c *gin.Context) {
-	var standar []JenisPelayanan
+	standar := []JenisPelayanan{}
+
+	query := DB.Model(&JenisPelayanan{})
+	if c.Query("termasuk_diarsipkan") != "true" {
+		query = query.Where("diarsipkan_pada IS NULL")
+	}
+	if status := c.Query("status"); status != "" {
+		if !statusValidasiSah(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parameter status tidak dikenal"})
+			return
+		}
+		query = query.Where("status_validasi = ?", status)
+	}
+	query, err := filterID(c, query, "id_opd", "id_opd")
+	if err == nil {
+		query, err = filterRentangTanggal(c, query, "created_at")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := bindPaginasi(c, kolomSortJenisPelayanan, "created_at", "DESC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Preload OPD dan ValidatorPemda agar informasi ikut terambil
-	if err := DB.Preload("OPD").Preload("ValidatorPemda").Find(&standar).Error; err != nil {
+	meta, err := ambilHalaman(query, p, "id_jenis_pelayanan", func(q *gorm.DB) *gorm.DB {
+		return q.Preload("OPD").Preload("ValidatorPemda")
+	}, &standar)
+	if err == nil {
+		err = isiDaftarPersyaratan(DB, standar)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, standar)
+	responList(c, standar, meta)
 }
 
-// GetStandarPelayananByOPD: Mendapatkan semua standar pelayanan berdasarkan ID OPD
+// GetStandarPelayananByOPD: Mendapatkan semua standar pelayanan berdasarkan ID OPD (?termasuk_diarsipkan=true)
 func GetStandarPelayananByOPD(c *gin.Context) {
-	idOpdStr := c.Param("id_opd")
-	if idOpdStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID OPD tidak boleh kosong"})
+	idOpd, err := strconv.ParseUint(c.Param("id_opd"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID OPD tidak valid"})
+		return
+	}
+	if !cekMilikOPD(c, uint(idOpd)) {
 		return
 	}
 
-	var standarPelayanan []JenisPelayanan
+	standarPelayanan := []JenisPelayanan{}
 
-	err := DB.Where("id_opd = ?", idOpdStr).
+	query := DB.Where("id_opd = ?", idOpd)
+	if c.Query("termasuk_diarsipkan") != "true" {
+		query = query.Where("diarsipkan_pada IS NULL")
+	}
+	err = query.
 		Preload("OPD").
 		Preload("ValidatorPemda").
 		Find(&standarPelayanan).Error
+	if err == nil {
+		err = isiDaftarPersyaratan(DB, standarPelayanan)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dari database"})
@@ -138,59 +310,144 @@ func GetStandarPelayananByOPD(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": standarPelayanan})
 }
 
-// ValidateJenisPelayanan: Memvalidasi standar pelayanan (Hanya oleh User Pemda)
-func ValidateJenisPelayanan(c *gin.Context) {
-	id := c.Param("id")
+// UpdateJenisPelayanan: PUT /api/standar-pelayanan/:id (body berisi field yang diubah)
+// Menunggu Validasi: versi yang sedang menunggu diubah langsung karena belum diputuskan validator.
+// Perlu Revisi: perubahan disimpan sebagai versi baru dan otomatis diajukan ulang.
+// Standar Disetujui direvisi lewat POST /versions; Ditolak / Ditarik lewat ajukan-ulang.
+func UpdateJenisPelayanan(c *gin.Context) {
 	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) || !cekBelumDiarsipkan(c, &standar) {
+		return
+	}
 
-	// 1. Cari standar
-	if err := DB.First(&standar, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Standar Pelayanan tidak ditemukan"})
+	switch standar.StatusValidasi {
+	case StatusValidasiMenunggu:
+		versi, err := cariVersi(DB, standar.ID, standar.VersiTerbaru)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Versi terbaru standar tidak ditemukan"})
+			return
+		}
+		konten, items, ok := bindKontenStandar(c, &standar, versi)
+		if !ok {
+			return
+		}
+		versi.KontenStandar = konten
+		if standar.VersiAktif == 0 {
+			terapkanKonten(&standar, konten)
+		}
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit(clause.Associations).Save(&versi).Error; err != nil {
+				return err
+			}
+			if err := gantiItemPersyaratan(tx, versi.ID, items); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Save(&standar).Error; err != nil {
+				return err
+			}
+			return catatAudit(tx, c, "standar.ubah", targetStandar(standar.ID), "Versi "+strconv.Itoa(versi.Nomor)+" diubah sebelum divalidasi")
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan standar: " + err.Error()})
+			return
+		}
+
+	case StatusValidasiPerluRevisi:
+		if !ajukanUlang(c, &standar) {
+			return
+		}
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Standar berstatus '" + standar.StatusValidasi + "' tidak dapat diubah langsung. " +
+				"Gunakan POST /versions untuk standar Disetujui atau ajukan-ulang untuk standar Ditolak / Ditarik.",
+		})
 		return
 	}
 
-	// 2. Cek status
-	if standar.StatusValidasi != "Menunggu Validasi" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar ini sudah divalidasi sebelumnya"})
+	muatStandarLengkap(&standar)
+	c.JSON(http.StatusOK, standar)
+}
+
+// DeleteJenisPelayanan: DELETE /api/standar-pelayanan/:id
+// Mengarsipkan standar (soft delete) agar tidak muncul di daftar dan tidak dapat dipakai untuk
+// pengajuan baru. Ditolak selama masih ada pengajuan yang belum Selesai / Ditolak; pengajuan yang
+// sudah selesai tetap mereferensikan standar dan versinya.
+func DeleteJenisPelayanan(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) {
+		return
+	}
+	if standar.DiarsipkanPada != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar pelayanan sudah diarsipkan"})
 		return
 	}
 
-	// 3. Bind request body validasi
-	var req ValidasiRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci baris standar; pembuatan pengajuan mengunci baris yang sama FOR SHARE (lihat
+		// kunciStandarPengajuan) sehingga tidak ada pengajuan baru yang lolos di antara cek dan update
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&standar, standar.ID).Error; err != nil {
+			return err
+		}
+		var aktif int64
+		if err := tx.Model(&FormPengajuan{}).
+			Where("id_jenis_pelayanan = ? AND status_proses NOT IN ?", standar.ID, []string{StatusSelesai, StatusDitolak}).
+			Where(kondisiPengajuanAktif).Count(&aktif).Error; err != nil {
+			return err
+		}
+		if aktif > 0 {
+			return &pengajuanAktifError{jumlah: aktif}
+		}
+		if err := tx.Model(&standar).Update("diarsipkan_pada", time.Now()).Error; err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "standar.arsip", targetStandar(standar.ID), "Standar diarsipkan")
+	})
+	var errAktif *pengajuanAktifError
+	if errors.As(err, &errAktif) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                  "Standar masih dipakai " + strconv.FormatInt(errAktif.jumlah, 10) + " pengajuan yang belum selesai",
+			"jumlah_pengajuan_aktif": errAktif.jumlah,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengarsipkan standar"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Standar pelayanan diarsipkan"})
+}
 
-	// 4. Ambil ID Validator dari token
-	userClaims, _ := c.Get("user")
-	claims := userClaims.(*Claims)
-	validatorIDFromToken := claims.ID
-	now := time.Now()
+// pengajuanAktifError: standar tidak dapat diarsipkan karena masih dipakai pengajuan berjalan.
+type pengajuanAktifError struct{ jumlah int64 }
 
-	// 5. Update field
-	standar.StatusValidasi = req.StatusValidasi
+func (e *pengajuanAktifError) Error() string { return "standar masih dipakai pengajuan aktif" }
 
-	if req.KeteranganValidasi != "" {
-		standar.KeteranganValidasi = &req.KeteranganValidasi
-	} else {
-		standar.KeteranganValidasi = nil
+// PulihkanJenisPelayanan: POST /api/standar-pelayanan/:id/pulihkan, membatalkan pengarsipan.
+func PulihkanJenisPelayanan(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) {
+		return
+	}
+	if standar.DiarsipkanPada == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar pelayanan tidak diarsipkan"})
+		return
 	}
 
-	standar.IDValidatorPemda = &validatorIDFromToken
-	standar.TanggalValidasi = &now
-
-	// 6. Simpan
-	DB.Save(&standar)
-
-	// 7. Response
-	DB.Preload("ValidatorPemda").Preload("OPD").First(&standar, standar.ID)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&standar).Update("diarsipkan_pada", nil).Error; err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "standar.pulihkan", targetStandar(standar.ID), "Standar dipulihkan dari arsip")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulihkan standar"})
+		return
+	}
+	muatStandarLengkap(&standar)
 	c.JSON(http.StatusOK, standar)
 }
 
-
-
-
 // ========= CRUD HANDLERS: FORM PEMOHON (MASTER DATA) =========
 
 
@@ -203,10 +460,10 @@ func CreateFormPemohon(c *gin.Context) {
 	}
 
 	// Ambil ID User OPD dan IDOPD dari token
-	userClaims, _ := c.Get("user")
-	claims := userClaims.(*Claims)
+	claims := ambilClaims(c)
+	form.ID = 0
 	form.IDUserOPDInput = claims.ID // Set petugas yang menginput
-	form.IDOPD = claims.IDOPD 	 // <-- PERUBAHAN: Set OPD tempat mendaftar
+	form.IDOPD = claims.IDOPD       // OPD tempat mendaftar selalu dari token
 
 	if err := DB.Create(&form).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -218,16 +475,42 @@ func CreateFormPemohon(c *gin.Context) {
 	c.JSON(http.StatusCreated, form)
 }
 
+// kolomSortFormPemohon: nilai ?sort= yang diizinkan untuk list pemohon
+var kolomSortFormPemohon = map[string]string{
+	"created_at":   "created_at",
+	"nama_lengkap": "nama_lengkap",
+	"nik":          "nik",
+}
+
 // GetAllFormPemohon: Mendapatkan semua data master pemohon
+// (?id_opd=&created_from=&created_to= + paginasi)
 func GetAllFormPemohon(c *gin.Context) {
-	var forms []FormPemohon
+	forms := []FormPemohon{}
+
+	// User OPD hanya melihat pemohon OPD-nya sendiri
+	query, err := filterID(c, cakupanOPD(c, DB.Model(&FormPemohon{}), "id_opd"), "id_opd", "id_opd")
+	if err == nil {
+		query, err = filterRentangTanggal(c, query, "created_at")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := bindPaginasi(c, kolomSortFormPemohon, "created_at", "DESC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Tambahkan Preload("OPD")
-	if err := DB.Preload("UserOPDInput").Preload("OPD").Find(&forms).Error; err != nil {
+	meta, err := ambilHalaman(query, p, "id_form_pemohon", func(q *gorm.DB) *gorm.DB {
+		return q.Preload("UserOPDInput").Preload("OPD")
+	}, &forms)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, forms)
+	responList(c, forms, meta)
 }
 
 // GetFormPemohonByID: Mendapatkan detail satu pemohon
@@ -244,6 +527,9 @@ func GetFormPemohonByID(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
 	c.JSON(http.StatusOK, form)
 }
 
@@ -257,8 +543,10 @@ func UpdateFormPemohon(c *gin.Context) {
 		return
 	}
 
-	// Otorisasi: (Opsional) Cek apakah user yang mengedit adalah yang menginput
-	// ... (logika otorisasi Anda tetap sama)
+	// Otorisasi: hanya OPD pemilik data yang boleh mengubah
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
 
 	var input FormPemohon
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -288,7 +576,16 @@ func UpdateFormPemohon(c *gin.Context) {
 // DeleteFormPemohon: Menghapus data master pemohon
 func DeleteFormPemohon(c *gin.Context) {
 	id := c.Param("id")
-	if err := DB.Delete(&FormPemohon{}, id).Error; err != nil {
+	var form FormPemohon
+	if err := DB.First(&form, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Data pemohon tidak ditemukan"})
+		return
+	}
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
+
+	if err := DB.Delete(&form).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus data"})
 		return
 	}
@@ -298,38 +595,18 @@ func DeleteFormPemohon(c *gin.Context) {
 
 // ========= HELPER UNTUK AMBIL FILE & FORM VALUE (FORM PENGAJUAN) =========
 
-// BindFormPengajuanFromMultipartForm diperbarui untuk ERD V8
+// BindFormPengajuanFromMultipartForm diperbarui untuk ERD V8.
+// File dokumen_pengajuan tidak disimpan di sini: pemanggil mencatatnya sebagai LampiranPengajuan
+// (lihat ambilDokumenPengajuan) agar dokumen lama tidak tertimpa.
 func BindFormPengajuanFromMultipartForm(c *gin.Context, form *FormPengajuan) error {
 	// Parsing form
 	if err := c.Request.ParseMultipartForm(10 << 20); err != nil { // 10 MB limit
 		return errors.New("Gagal parsing form: " + err.Error())
 	}
 
-	// Handle file upload
-	var uploadedFilename *string
-	_, handler, err := c.Request.FormFile("dokumen_pengajuan") // <-- Nama field file baru
-	if err == nil {
-		os.MkdirAll("./uploads", os.ModePerm)
-		// Tambahkan timestamp unik untuk menghindari nama file yang sama
-		filePath := "./uploads/" + strconv.FormatInt(time.Now().Unix(), 10) + "_" + handler.Filename
-		if err := c.SaveUploadedFile(handler, filePath); err != nil {
-			return errors.New("Gagal menyimpan file: " + err.Error())
-		}
-		uploadedFilename = &filePath
-	} else if form.DokumenPengajuanPath != nil {
-		// Jika tidak ada file baru diupload, pertahankan path yang lama (untuk update)
-		uploadedFilename = form.DokumenPengajuanPath
-	}
-
 	// --- BIND FIELD WAJIB ---
 
-	// Konversi ID OPD (Baru)
-	idOpdStr := c.PostForm("id_opd")
-	idOpd, err := strconv.ParseUint(idOpdStr, 10, 64)
-	if err != nil {
-		return errors.New("ID OPD tidak valid atau kosong")
-	}
-	form.IDOPD = uint(idOpd)
+	// ID OPD tidak dibaca dari form: ditentukan oleh pemanggil dari token user
 
 	// Konversi ID Jenis Pelayanan
 	idJenisStr := c.PostForm("id_jenis_pelayanan")
@@ -378,8 +655,6 @@ func BindFormPengajuanFromMultipartForm(c *gin.Context, form *FormPengajuan) err
 		form.PeriodeSelesai = nil
 	}
 
-	form.DokumenPengajuanPath = uploadedFilename // <-- Nama field struct baru
-
 	// Validasi Sederhana
 	if form.NamaPemohonLengkap == "" || form.NIKPemohon == "" || form.JudulPengajuan == "" {
 		return errors.New("nama Pemohon, NIK Pemohon, dan Judul Pengajuan tidak boleh kosong")
@@ -388,8 +663,26 @@ func BindFormPengajuanFromMultipartForm(c *gin.Context, form *FormPengajuan) err
 	return nil
 }
 
+// ambilDokumenPengajuan menyimpan file dokumen_pengajuan (jika diunggah) ke disk.
+// Nil berarti tidak ada file baru; baris lampirannya dibuat di transaksi pemanggil.
+func ambilDokumenPengajuan(c *gin.Context) (*LampiranPengajuan, error) {
+	berkas, err := c.FormFile("dokumen_pengajuan")
+	if err != nil {
+		return nil, nil
+	}
+	lampiran, err := simpanBerkasLampiran(berkas, batasUnggahByte)
+	if err != nil {
+		return nil, err
+	}
+	return &lampiran, nil
+}
+
 // =========== FORM PENGAJUAN (TRANSAKSI) KHUSUS OPD =================
 
+// kondisiPengajuanAktif mengecualikan pengajuan yang sudah dihapus (soft delete).
+// Setiap query form_pengajuan untuk user harus menyertakannya.
+const kondisiPengajuanAktif = "dihapus_pada IS NULL"
+
 func CreateFormPengajuan(c *gin.Context) {
 	// Ambil ID User OPD dari token
 	userClaims, _ := c.Get("user")
@@ -397,62 +690,209 @@ func CreateFormPengajuan(c *gin.Context) {
 
 	var form FormPengajuan
 	form.IDUserOPD = claims.ID // Ambil ID dari user yang login
+	form.IDOPD = claims.IDOPD  // Pengajuan selalu milik OPD user yang login
 
 	if err := BindFormPengajuanFromMultipartForm(c, &form); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Ambil standar pelayanan untuk menghitung jatuh tempo (SLA)
+	var jenis JenisPelayanan
+	if err := DB.First(&jenis, form.IDJenisPelayanan).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID Jenis Pelayanan tidak valid"})
+		return
+	}
+	if !cekMilikOPD(c, jenis.IDOPD) || !cekBelumDiarsipkan(c, &jenis) {
+		return
+	}
+
 	// StatusProses sudah memiliki default di models.go
-	form.StatusProses = "Baru"
+	form.StatusProses = StatusBaru
 	// StatusValidasi DIHAPUS
 
-	if err := DB.Create(&form).Error; err != nil {
+	// CreatedAt diisi di sini agar jatuh tempo dihitung dari waktu yang sama
+	form.CreatedAt = time.Now()
+	form.TanggalJatuhTempo = hitungJatuhTempo(form.CreatedAt, jenis.WaktuPelayananNilai, jenis.WaktuPelayananSatuan)
+
+	dokumen, err := ambilDokumenPengajuan(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		idVersi, err := kunciStandarPengajuan(tx, form.IDJenisPelayanan)
+		if err != nil {
+			return err
+		}
+		form.IDVersiStandar = idVersi
+		if err := tx.Create(&form).Error; err != nil {
+			return err
+		}
+		// Satu slot dokumen untuk setiap item persyaratan versi standar yang berlaku
+		if err := buatSlotPersyaratan(tx, form.ID, form.IDVersiStandar); err != nil {
+			return err
+		}
+		if err := catatRiwayatPengajuan(tx, claims, form.ID, nil, form.StatusProses, "Pengajuan dibuat"); err != nil {
+			return err
+		}
+		if dokumen == nil {
+			return nil
+		}
+		return catatLampiranBaru(tx, claims, &form, dokumen, JenisLampiranDokumen, nil, nil)
+	})
+	if err != nil {
+		if dokumen != nil {
+			os.Remove(dokumen.Path)
+		}
+		if statusUntukError(err) != http.StatusInternalServerError {
+			c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan data: " + err.Error()})
 		return
 	}
 
 	// Ambil kembali data dengan relasi untuk response
-	DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").First(&form, form.ID)
+	DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").Preload("Persyaratan", urutPersyaratan).
+		Preload("Lampiran", preloadLampiranBerlaku).First(&form, form.ID)
 	c.JSON(http.StatusCreated, form)
 }
 
-// GetAllFormPengajuan: Mendapatkan semua pengajuan (untuk Pemda/Admin)
-func GetAllFormPengajuan(c *gin.Context) {
-	var forms []FormPengajuan
+// kolomSortPengajuan: nilai ?sort= yang diizinkan untuk list pengajuan
+var kolomSortPengajuan = map[string]string{
+	"created_at":          "created_at",
+	"tanggal_jatuh_tempo": "tanggal_jatuh_tempo",
+	"status":              "status_proses",
+	"judul":               "judul_pengajuan",
+	"nama_pemohon":        "nama_pemohon_lengkap",
+}
 
-	tx := DB.Preload("UserOPD.OPD").
-		Preload("JenisPelayanan.OPD").
-		Preload("OPD"). // Preload OPD yang dituju
-		Find(&forms)
+// filterFormPengajuan menerapkan filter list pengajuan:
+// ?status=&id_opd=&id_jenis_pelayanan=&created_from=&created_to=&terlambat=
+func filterFormPengajuan(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if status := c.Query("status"); status != "" {
+		if !statusProsesSah(status) {
+			return query, errParameter("parameter status tidak dikenal")
+		}
+		query = query.Where("status_proses = ?", status)
+	}
+
+	var err error
+	if query, err = filterID(c, query, "id_opd", "id_opd"); err != nil {
+		return query, err
+	}
+	if query, err = filterID(c, query, "id_jenis_pelayanan", "id_jenis_pelayanan"); err != nil {
+		return query, err
+	}
+	if query, err = filterRentangTanggal(c, query, "created_at"); err != nil {
+		return query, err
+	}
+
+	// Filter SLA: ?terlambat=true / ?terlambat=false
+	if terlambatStr := c.Query("terlambat"); terlambatStr != "" {
+		terlambat, err := strconv.ParseBool(terlambatStr)
+		if err != nil {
+			return query, errParameter("parameter terlambat harus true atau false")
+		}
+		if terlambat {
+			query = query.Where("tanggal_jatuh_tempo IS NOT NULL AND COALESCE(tanggal_selesai, NOW()) > tanggal_jatuh_tempo")
+		} else {
+			query = query.Where("(tanggal_jatuh_tempo IS NULL OR COALESCE(tanggal_selesai, NOW()) <= tanggal_jatuh_tempo)")
+		}
+	}
+	return query, nil
+}
+
+// daftarFormPengajuan menjalankan query list pengajuan dengan filter, sorting, dan paginasi.
+// Jika parameter ?cursor= dikirim (boleh kosong untuk halaman pertama), dipakai paginasi
+// berbasis cursor (urut dari yang terbaru) yang cocok untuk infinite scroll.
+func daftarFormPengajuan(c *gin.Context, query *gorm.DB) ([]FormPengajuan, MetaPaginasi, error) {
+	forms := []FormPengajuan{}
+
+	query, err := filterFormPengajuan(c, query.Where(kondisiPengajuanAktif))
+	if err != nil {
+		return nil, MetaPaginasi{}, err
+	}
+	p, err := bindPaginasi(c, kolomSortPengajuan, "created_at", "DESC")
+	if err != nil {
+		return nil, MetaPaginasi{}, err
+	}
+
+	preload := func(q *gorm.DB) *gorm.DB {
+		return q.Preload("UserOPD.OPD").
+			Preload("JenisPelayanan.OPD").
+			Preload("OPD") // Preload OPD yang dituju
+	}
 
-	if tx.Error != nil {
-		log.Println("!!! ERROR SAAT QUERY DATABASE:", tx.Error.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": tx.Error.Error()})
+	cursor, pakaiCursor := c.GetQuery("cursor")
+	if !pakaiCursor {
+		meta, err := ambilHalaman(query, p, "id_form_pengajuan", preload, &forms)
+		return forms, meta, err
+	}
+
+	// Session agar query yang sama aman dipakai untuk Count dan Find
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, MetaPaginasi{}, err
+	}
+
+	// Mode cursor: cursor = ID pengajuan terakhir dari halaman sebelumnya
+	if cursor != "" {
+		idCursor, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return nil, MetaPaginasi{}, errParameter("parameter cursor tidak valid")
+		}
+		query = query.Where("id_form_pengajuan < ?", idCursor)
+	}
+	if err := preload(query).Order("id_form_pengajuan DESC").Limit(p.PerPage + 1).Find(&forms).Error; err != nil {
+		return nil, MetaPaginasi{}, err
+	}
+
+	meta := MetaPaginasi{Total: total, PerPage: p.PerPage}
+	if len(forms) > p.PerPage {
+		forms = forms[:p.PerPage]
+		next := strconv.FormatUint(uint64(forms[len(forms)-1].ID), 10)
+		meta.NextCursor = &next
+	}
+	return forms, meta, nil
+}
+
+// GetAllFormPengajuan: Mendapatkan semua pengajuan (untuk Pemda/Admin; user OPD dibatasi OPD-nya)
+func GetAllFormPengajuan(c *gin.Context) {
+	forms, meta, err := daftarFormPengajuan(c, cakupanOPD(c, DB.Model(&FormPengajuan{}), "id_opd"))
+	if err != nil {
+		log.Println("!!! ERROR SAAT QUERY DATABASE:", err.Error())
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Println("--- Query GetAllFormPengajuan BERHASIL. Jumlah data:", tx.RowsAffected, "---")
-	c.JSON(http.StatusOK, forms)
+	log.Println("--- Query GetAllFormPengajuan BERHASIL. Jumlah data:", len(forms), "dari", meta.Total, "---")
+	responList(c, forms, meta)
 }
 
 // GetFormPengajuanByUserOPD: Mendapatkan pengajuan berdasarkan user OPD ID
 func GetFormPengajuanByUserOPD(c *gin.Context) {
-	userOpdID := c.Param("id")
-	var forms []FormPengajuan
-
-	err := DB.Where("id_user_opd = ?", userOpdID).
-		Preload("UserOPD.OPD").
-		Preload("JenisPelayanan.OPD").
-		Preload("OPD").
-		Find(&forms).Error
+	var userOPD UserOPD
+	if err := DB.First(&userOPD, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User OPD tidak ditemukan"})
+		return
+	}
+	if !cekMilikOPD(c, userOPD.IDOPD) {
+		return
+	}
 
+	query := cakupanOPD(c, DB.Model(&FormPengajuan{}).Where("id_user_opd = ?", userOPD.ID), "id_opd")
+	forms, meta, err := daftarFormPengajuan(c, query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, forms)
+	responList(c, forms, meta)
 }
 
 // GetFormPengajuanByID: Mendapatkan detail pengajuan
@@ -461,7 +901,8 @@ func GetFormPengajuanByID(c *gin.Context) {
 	var form FormPengajuan
 
 	// Preload semua relasi (ValidatorPemda DIHAPUS)
-	if err := DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").First(&form, formID).Error; err != nil {
+	if err := DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").Preload("Persyaratan", urutPersyaratan).
+		Preload("Lampiran", preloadLampiranBerlaku).Where(kondisiPengajuanAktif).First(&form, formID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Data pengajuan tidak ditemukan"})
 			return
@@ -470,60 +911,169 @@ func GetFormPengajuanByID(c *gin.Context) {
 		return
 	}
 
-	// Otorisasi: (Opsional) Cek apakah user OPD yang login adalah pemilik data
-	userClaims, exists := c.Get("user")
-	if exists {
-		claims := userClaims.(*Claims)
-		// Jika role-nya OPD dan bukan pemilik data, tolak
-		if claims.Role == "opd" && form.IDUserOPD != claims.ID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Anda tidak memiliki hak akses untuk melihat data ini"})
-			return
-		}
+	// Otorisasi: user OPD hanya boleh melihat pengajuan milik OPD-nya
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
 	}
+	form.StatusBerikutnya = StatusBerikutnya(form.StatusProses, ambilClaims(c).Role)
 
 	c.JSON(http.StatusOK, form)
 }
 
+// kolomIsianPengajuan adalah kolom form_pengajuan yang dapat diubah lewat UpdateFormPengajuan
+// (isian BindFormPengajuanFromMultipartForm beserta SLA & versi standar yang mengikuti jenis pelayanan).
+var kolomIsianPengajuan = []string{
+	"id_jenis_pelayanan", "id_versi_standar", "tanggal_jatuh_tempo",
+	"nama_pemohon_lengkap", "nik_pemohon", "alamat_pemohon", "nomor_hp_pemohon", "email_pemohon",
+	"judul_pengajuan", "deskripsi_singkat", "is_agreed", "periode_mulai", "periode_selesai",
+}
+
 // UpdateFormPengajuan: Memperbarui data pengajuan (hanya oleh User OPD)
 func UpdateFormPengajuan(c *gin.Context) {
 	id := c.Param("id")
 	var form FormPengajuan
 
 	// 1. Cari data lama
-	if err := DB.First(&form, id).Error; err != nil {
+	if err := DB.Where(kondisiPengajuanAktif).First(&form, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Data tidak ditemukan"})
 		return
 	}
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
 
-	// 2. Cek status (Opsional: Cek StatusProses)
-	if form.StatusProses == "Selesai" {
-		 c.JSON(http.StatusForbidden, gin.H{"error": "Data ini sudah Selesai dan tidak dapat diubah"})
-		 return
+	// 2. Cek status: hanya pengajuan Baru / Dikembalikan yang boleh diubah
+	if !statusDapatDiubah(form.StatusProses) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Data dengan status '" + form.StatusProses + "' tidak dapat diubah"})
+		return
 	}
 
 	// 3. Bind data baru dari form ke struct lama
+	idJenisLama := form.IDJenisPelayanan
+	gantiJenis := false
 	if err := BindFormPengajuanFromMultipartForm(c, &form); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 4. Simpan perubahan
-	if err := DB.Save(&form).Error; err != nil {
+	// Jika jenis pelayanan diganti, hitung ulang jatuh tempo dari tanggal pengajuan
+	if form.IDJenisPelayanan != idJenisLama {
+		var jenis JenisPelayanan
+		if err := DB.First(&jenis, form.IDJenisPelayanan).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID Jenis Pelayanan tidak valid"})
+			return
+		}
+		if !cekMilikOPD(c, jenis.IDOPD) || !cekBelumDiarsipkan(c, &jenis) {
+			return
+		}
+		// Versi standar ditetapkan di dalam transaksi oleh kunciStandarPengajuan
+		form.TanggalJatuhTempo = hitungJatuhTempo(form.CreatedAt, jenis.WaktuPelayananNilai, jenis.WaktuPelayananSatuan)
+		gantiJenis = true
+	}
+
+	// Dokumen baru menjadi versi berikutnya; dokumen lama tetap tersimpan sebagai lampiran
+	dokumen, err := ambilDokumenPengajuan(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 4. Simpan perubahan beserta riwayatnya
+	userClaims, _ := c.Get("user")
+	claims := userClaims.(*Claims)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if gantiJenis {
+			idVersi, err := kunciStandarPengajuan(tx, form.IDJenisPelayanan)
+			if err != nil {
+				return err
+			}
+			form.IDVersiStandar = idVersi
+		}
+		// Hanya kolom isian form yang ditulis, dan hanya jika status belum berubah sejak dibaca;
+		// status, SLA selesai, dan penghapusan dari request lain tidak boleh tertimpa
+		hasil := tx.Model(&FormPengajuan{}).
+			Where("id_form_pengajuan = ? AND status_proses = ?", form.ID, form.StatusProses).
+			Where(kondisiPengajuanAktif).
+			Select(kolomIsianPengajuan).
+			Updates(&form)
+		if hasil.Error != nil {
+			return hasil.Error
+		}
+		if hasil.RowsAffected == 0 {
+			return errKonflik("Pengajuan baru saja diubah atau dihapus pengguna lain; muat ulang data")
+		}
+		// Checklist mengikuti standar yang baru; hasil pemeriksaan sebelumnya tidak berlaku lagi
+		if gantiJenis {
+			if err := gantiSlotPersyaratan(tx, form.ID, form.IDVersiStandar); err != nil {
+				return err
+			}
+		}
+		if err := catatRiwayatPengajuan(tx, claims, form.ID, &form.StatusProses, form.StatusProses, "Data pengajuan diperbarui"); err != nil {
+			return err
+		}
+		if dokumen == nil {
+			return nil
+		}
+		pendahulu, err := cariLampiranTunggal(tx, form.ID, JenisLampiranDokumen, nil)
+		if err != nil {
+			return err
+		}
+		return catatLampiranBaru(tx, claims, &form, dokumen, JenisLampiranDokumen, nil, pendahulu)
+	})
+	if err != nil {
+		if dokumen != nil {
+			os.Remove(dokumen.Path)
+		}
+		if statusUntukError(err) != http.StatusInternalServerError {
+			c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui data"})
 		return
 	}
 
 	// 5. Response
-	DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").First(&form, form.ID)
+	DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").Preload("Lampiran", preloadLampiranBerlaku).First(&form, form.ID)
 	c.JSON(http.StatusOK, form)
 }
 
 // DeleteFormPengajuan: Menghapus data pengajuan
 func DeleteFormPengajuan(c *gin.Context) {
 	id := c.Param("id")
-	// Di dunia nyata, Anda mungkin ingin memeriksa status sebelum menghapus
-	if err := DB.Delete(&FormPengajuan{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus data"})
+	var form FormPengajuan
+
+	if err := DB.Where(kondisiPengajuanAktif).First(&form, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Data tidak ditemukan"})
+		return
+	}
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
+
+	// Pengajuan yang sudah diverifikasi/diproses tidak boleh dihapus
+	if !statusDapatDiubah(form.StatusProses) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Data dengan status '" + form.StatusProses + "' tidak dapat dihapus"})
+		return
+	}
+
+	// Soft delete: riwayat, slot persyaratan dan lampiran tetap disimpan sebagai jejak audit,
+	// ditutup dengan entri riwayat penghapusan
+	claims := ambilClaims(c)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		hasil := tx.Model(&FormPengajuan{}).
+			Where("id_form_pengajuan = ? AND status_proses = ?", form.ID, form.StatusProses).
+			Where(kondisiPengajuanAktif).
+			Update("dihapus_pada", time.Now())
+		if hasil.Error != nil {
+			return hasil.Error
+		}
+		if hasil.RowsAffected == 0 {
+			return errKonflik("Status pengajuan baru saja berubah; muat ulang data")
+		}
+		return catatRiwayatPengajuan(tx, claims, form.ID, &form.StatusProses, form.StatusProses, "Pengajuan dihapus")
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Data berhasil dihapus"})
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========= PROTEKSI CSRF (DOUBLE-SUBMIT COOKIE) =========
+//
+// Token CSRF disimpan di cookie "csrf_token" (bukan HttpOnly) dan juga dikirim di body
+// respons login / GET /api/csrf. Untuk request POST/PUT/PATCH/DELETE yang membawa cookie
+// otentikasi, frontend wajib mengirim token yang sama di header X-CSRF-Token. Situs lain
+// tidak dapat membaca cookie maupun mengirim header kustom, sehingga request palsu ditolak.
+// Klien Bearer / API key tidak memakai cookie sehingga dikecualikan.
+
+const (
+	cookieCSRF = "csrf_token"
+	headerCSRF = "X-CSRF-Token"
+)
+
+// cookieOtentikasi adalah cookie yang membuat browser ikut mengirim kredensial otomatis.
+var cookieOtentikasi = []string{"opd_token", "pemda_token", cookieRefresh}
+
+// setCookieCSRF menerbitkan token CSRF baru. Dipanggil saat login agar token dari
+// sebelum login (yang mungkin ditanam pihak lain) tidak terpakai.
+func setCookieCSRF(c *gin.Context) (string, error) {
+	token, err := tokenAcak(32)
+	if err != nil {
+		return "", err
+	}
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie(cookieCSRF, token, int(ttlRefreshToken().Seconds()), "/", domain, isSecure, false)
+	c.Set(cookieCSRF, token) // Dibaca tokenCSRF pada respons yang sama
+	return token, nil
+}
+
+// tokenCSRF mengembalikan token CSRF yang berlaku untuk request ini.
+func tokenCSRF(c *gin.Context) string {
+	if token := c.GetString(cookieCSRF); token != "" {
+		return token
+	}
+	token, _ := c.Cookie(cookieCSRF)
+	return token
+}
+
+// hapusCookieCSRF menghapus cookie token CSRF.
+func hapusCookieCSRF(c *gin.Context) {
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie(cookieCSRF, "", -1, "/", domain, isSecure, false)
+}
+
+// adaCookieOtentikasi memeriksa apakah request membawa cookie otentikasi.
+func adaCookieOtentikasi(c *gin.Context) bool {
+	for _, nama := range cookieOtentikasi {
+		if nilai, err := c.Cookie(nama); err == nil && nilai != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFMiddleware memvalidasi token CSRF pada request yang mengubah data dan diautentikasi lewat cookie.
+// kecuali berisi path route (mis. "/api/login") yang tidak memakai cookie untuk otentikasi,
+// agar cookie usang dari sesi lama tidak menghalangi login.
+func CSRFMiddleware(kecuali ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if mengandung(kecuali, c.FullPath()) {
+			c.Next()
+			return
+		}
+		// Klien Bearer / API key tidak mengandalkan cookie
+		if _, ada := bacaHeaderBearer(c); ada || !adaCookieOtentikasi(c) {
+			c.Next()
+			return
+		}
+
+		cookie, _ := c.Cookie(cookieCSRF)
+		header := c.GetHeader(headerCSRF)
+		if cookie == "" || header == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "Token CSRF tidak valid atau tidak dikirim. Ambil token baru lewat GET /api/csrf.",
+				"csrf_gagal": true,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetCSRFToken: GET /api/csrf
+// Mengembalikan token CSRF saat ini, atau menerbitkan token baru jika belum ada.
+func GetCSRFToken(c *gin.Context) {
+	token := tokenCSRF(c)
+	if token == "" {
+		var err error
+		if token, err = setCookieCSRF(c); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token CSRF"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"csrf_token": token, "header": headerCSRF})
+}
@@ -34,16 +34,63 @@ func InitDB() {
 	DB = db
 	fmt.Println("✅ Database connected")
 
+	// Dicatat sebelum AutoMigrate: user dari versi sebelum RBAC mendapat peran penuh (lihat siapkanPeran)
+	userOPDLama, userPemdaLama := kolomPeranBelumAda()
+
 		err = DB.AutoMigrate(
 		&OPD{},
+		&Akun{},
 		&JenisPelayanan{},
 		&UserOPD{},
 		&UserPemda{},
 		&FormPemohon{},
 		&FormPengajuan{},
+		&RiwayatPengajuan{},
+		&HariLibur{},
+		&TokenResetPassword{},
+		&LogAudit{},
+		&Sesi{},
+		&TokenDicabut{},
+		&LoginAttempt{},
+		&PenguncianLogin{},
+		&Peran{},
+		&IzinPeran{},
+		&KodePemulihan{},
+		&APIKey{},
+		&IzinAPIKey{},
+		&KomentarRevisi{},
+		&Notifikasi{},
+		&VersiStandar{},
+		&ItemPersyaratan{},
+		&PersyaratanPengajuan{},
+		&LampiranPengajuan{},
+		&MigrasiIzinPeran{},
 	)
 	if err != nil {
 		log.Fatal("❌ Migration failed: ", err)
 	}
 	fmt.Println("✅ AutoMigration finished")
+
+	// Pindahkan password dari user_opd / user_pemda ke tabel akun
+	migrasiAkun()
+
+	// Peran & izin bawaan (RBAC)
+	siapkanPeran(userOPDLama, userPemdaLama)
+
+	// Kalender hari kerja dibutuhkan untuk menghitung jatuh tempo SLA
+	muatKalenderKerja()
+	migrasiSLA()
+
+	// Versi 1 untuk standar yang dibuat sebelum ada versi standar, lalu item & slot persyaratan dari teks lama
+	migrasiVersiStandar()
+	migrasiPersyaratan()
+
+	// Dokumen pengajuan lama dicatat sebagai lampiran versi 1
+	migrasiLampiran()
+
+	// Penyimpan penghitung kegagalan login (memory / db)
+	siapkanPembatasLogin()
+
+	// Index full-text & trigram untuk endpoint /search
+	siapkanPencarian()
 }
\ No newline at end of file
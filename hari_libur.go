@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ========= CRUD HANDLERS: HARI LIBUR & KALENDER KERJA (KHUSUS PEMDA) =========
+
+// Jenis hari libur yang dikenali.
+const (
+	JenisLiburNasional    = "nasional"
+	JenisLiburCutiBersama = "cuti_bersama"
+	JenisLiburDaerah      = "daerah"
+)
+
+// jenisLiburValid memeriksa jenis hari libur; string kosong dianggap "nasional".
+func jenisLiburValid(jenis string) bool {
+	return jenis == JenisLiburNasional || jenis == JenisLiburCutiBersama || jenis == JenisLiburDaerah
+}
+
+// bindHariLibur mengubah HariLiburRequest menjadi HariLibur.
+func bindHariLibur(req HariLiburRequest, libur *HariLibur) error {
+	tanggal, err := time.Parse("2006-01-02", req.Tanggal)
+	if err != nil {
+		return errors.New("format tanggal harus YYYY-MM-DD")
+	}
+	if req.Jenis == "" {
+		req.Jenis = JenisLiburNasional
+	}
+	if !jenisLiburValid(req.Jenis) {
+		return errors.New("jenis harus nasional, cuti_bersama, atau daerah")
+	}
+	libur.Tanggal = tanggal
+	libur.Nama = strings.TrimSpace(req.Nama)
+	libur.Jenis = req.Jenis
+	return nil
+}
+
+// GetAllHariLibur: Mendapatkan daftar hari libur, bisa difilter ?tahun=2025
+func GetAllHariLibur(c *gin.Context) {
+	var daftar []HariLibur
+	query := DB.Order("tanggal ASC")
+	if tahun := c.Query("tahun"); tahun != "" {
+		query = query.Where("EXTRACT(YEAR FROM tanggal) = ?", tahun)
+	}
+	if err := query.Find(&daftar).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": daftar})
+}
+
+// CreateHariLibur: Menambahkan satu hari libur
+func CreateHariLibur(c *gin.Context) {
+	var req HariLiburRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+
+	var libur HariLibur
+	if err := bindHariLibur(req, &libur); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DB.Create(&libur).Error; err != nil {
+		if pelanggaranUnik(err) {
+			err = errKonflik("Hari libur pada tanggal " + req.Tanggal + " sudah terdaftar")
+		}
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	muatKalenderKerja()
+	c.JSON(http.StatusCreated, libur)
+}
+
+// UpdateHariLibur: Mengubah data hari libur
+func UpdateHariLibur(c *gin.Context) {
+	id := c.Param("id")
+	var libur HariLibur
+
+	if err := DB.First(&libur, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Data hari libur tidak ditemukan"})
+		return
+	}
+
+	var req HariLiburRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+	if err := bindHariLibur(req, &libur); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DB.Save(&libur).Error; err != nil {
+		if pelanggaranUnik(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Hari libur pada tanggal " + req.Tanggal + " sudah terdaftar"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui data"})
+		return
+	}
+
+	muatKalenderKerja()
+	c.JSON(http.StatusOK, libur)
+}
+
+// DeleteHariLibur: Menghapus hari libur
+func DeleteHariLibur(c *gin.Context) {
+	id := c.Param("id")
+	hasil := DB.Delete(&HariLibur{}, id)
+	if hasil.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus data"})
+		return
+	}
+	if hasil.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Data hari libur tidak ditemukan"})
+		return
+	}
+
+	muatKalenderKerja()
+	c.JSON(http.StatusOK, gin.H{"message": "Data hari libur berhasil dihapus"})
+}
+
+// ImportHariLibur: Import massal hari libur dari file .ics atau .csv (field "file").
+// Tanggal yang sudah ada akan diperbarui nama dan jenisnya.
+func ImportHariLibur(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File import tidak ditemukan"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal membuka file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+
+	// Jenis default untuk file ICS (ICS tidak membawa jenis libur)
+	jenis := c.DefaultPostForm("jenis", JenisLiburNasional)
+	if !jenisLiburValid(jenis) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "jenis harus nasional, cuti_bersama, atau daerah"})
+		return
+	}
+
+	var daftar []HariLibur
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".ics":
+		daftar, err = parseICSHariLibur(file, jenis)
+	case ".csv":
+		daftar, err = parseCSVHariLibur(file)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format file harus .ics atau .csv"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(daftar) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada hari libur yang dapat dibaca dari file"})
+		return
+	}
+
+	if err := simpanHariLibur(DB, daftar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan data: " + err.Error()})
+		return
+	}
+
+	muatKalenderKerja()
+	c.JSON(http.StatusOK, gin.H{"message": "Import hari libur berhasil", "jumlah": len(daftar)})
+}
+
+// GetHariKerja: Menghitung jumlah hari kerja antara ?from= dan ?to= (YYYY-MM-DD, inklusif)
+func GetHariKerja(c *gin.Context) {
+	dari, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter from wajib diisi dengan format YYYY-MM-DD"})
+		return
+	}
+	sampai, err := time.ParseInLocation("2006-01-02", c.Query("to"), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter to wajib diisi dengan format YYYY-MM-DD"})
+		return
+	}
+	if sampai.Before(dari) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tanggal to tidak boleh sebelum from"})
+		return
+	}
+
+	// HariKerjaAntara tidak menghitung tanggal awal, jadi mulai dari sehari sebelumnya
+	hariKerja := kalenderKerja.HariKerjaAntara(dari.AddDate(0, 0, -1), sampai)
+	totalHari := int(sampai.Sub(dari).Hours()/24) + 1
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":       dari.Format("2006-01-02"),
+		"to":         sampai.Format("2006-01-02"),
+		"hari_kerja": hariKerja,
+		"hari_libur": totalHari - hariKerja,
+		"total_hari": totalHari,
+	})
+}
+
+// simpanHariLibur menyimpan daftar hari libur; tanggal yang sudah ada diperbarui.
+func simpanHariLibur(tx *gorm.DB, daftar []HariLibur) error {
+	// Satu tanggal hanya boleh muncul sekali dalam satu perintah upsert
+	unik := map[string]int{}
+	var hasil []HariLibur
+	for _, h := range daftar {
+		kunci := h.Tanggal.Format("2006-01-02")
+		if i, ada := unik[kunci]; ada {
+			hasil[i] = h
+			continue
+		}
+		unik[kunci] = len(hasil)
+		hasil = append(hasil, h)
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tanggal"}},
+		DoUpdates: clause.AssignmentColumns([]string{"nama", "jenis"}),
+	}).Create(&hasil).Error
+}
+
+// parseCSVHariLibur membaca CSV dengan kolom: tanggal,nama[,jenis].
+// Baris header (diawali "tanggal") dilewati.
+func parseCSVHariLibur(r io.Reader) ([]HariLibur, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var daftar []HariLibur
+	baris := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		baris++
+		if err != nil {
+			return nil, errors.New("CSV tidak valid: " + err.Error())
+		}
+		if len(record) == 0 || strings.EqualFold(strings.TrimSpace(record[0]), "tanggal") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, errors.New("baris " + strconv.Itoa(baris) + ": kolom minimal tanggal,nama")
+		}
+
+		req := HariLiburRequest{Tanggal: strings.TrimSpace(record[0]), Nama: record[1]}
+		if len(record) > 2 {
+			req.Jenis = strings.TrimSpace(record[2])
+		}
+		var libur HariLibur
+		if err := bindHariLibur(req, &libur); err != nil {
+			return nil, errors.New("baris " + strconv.Itoa(baris) + ": " + err.Error())
+		}
+		daftar = append(daftar, libur)
+	}
+	return daftar, nil
+}
+
+// maksHariEventICS membatasi rentang DTSTART - DTEND satu event ICS agar file yang salah
+// atau sengaja dibuat tidak menghasilkan jutaan baris hari libur.
+const maksHariEventICS = 366
+
+// parseICSHariLibur membaca VEVENT dari file iCalendar (RFC 5545).
+// Event multi-hari (DTEND eksklusif) dipecah menjadi satu baris per tanggal.
+func parseICSHariLibur(r io.Reader, jenis string) ([]HariLibur, error) {
+	// Gabungkan baris yang terlipat (diawali spasi/tab) terlebih dahulu
+	var baris []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(baris) > 0 {
+			baris[len(baris)-1] += line[1:]
+			continue
+		}
+		baris = append(baris, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("Gagal membaca file ICS: " + err.Error())
+	}
+
+	var daftar []HariLibur
+	var dalamEvent bool
+	var nama string
+	var mulai, selesai *time.Time
+	for _, line := range baris {
+		switch {
+		case line == "BEGIN:VEVENT":
+			dalamEvent, nama, mulai, selesai = true, "", nil, nil
+		case line == "END:VEVENT":
+			if dalamEvent && mulai != nil {
+				akhir := mulai.AddDate(0, 0, 1)
+				if selesai != nil && selesai.After(*mulai) {
+					akhir = *selesai
+				}
+				if akhir.After(mulai.AddDate(0, 0, maksHariEventICS)) {
+					return nil, errors.New("event " + strconv.Quote(nama) + " melebihi " + strconv.Itoa(maksHariEventICS) + " hari")
+				}
+				for t := *mulai; t.Before(akhir); t = t.AddDate(0, 0, 1) {
+					daftar = append(daftar, HariLibur{Tanggal: t, Nama: nama, Jenis: jenis})
+				}
+			}
+			dalamEvent = false
+		case dalamEvent:
+			kunci, nilai, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			// Buang parameter seperti DTSTART;VALUE=DATE
+			kunci, _, _ = strings.Cut(kunci, ";")
+			switch kunci {
+			case "SUMMARY":
+				nama = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ").Replace(nilai)
+			case "DTSTART", "DTEND":
+				if len(nilai) < 8 {
+					return nil, errors.New("tanggal " + kunci + " tidak valid: " + nilai)
+				}
+				t, err := time.Parse("20060102", nilai[:8])
+				if err != nil {
+					return nil, errors.New("tanggal " + kunci + " tidak valid: " + nilai)
+				}
+				if kunci == "DTSTART" {
+					mulai = &t
+				} else {
+					selesai = &t
+				}
+			}
+		}
+	}
+	return daftar, nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ========= KUNCI PENANDATANGAN JWT (KEYSET) =========
+//
+// Semua token (access token & tiket login) ditandatangani kunci aktif dan membawa
+// header "kid". Kunci lama tetap dapat memverifikasi token sampai token-nya kedaluwarsa,
+// sehingga rotasi kunci tidak membuat semua user logout.
+//
+// Konfigurasi lewat environment:
+//   JWT_SIGNING_ALG         HS256 (default) / EdDSA / RS256
+//   JWT_SECRET_KEY          Secret HS256 aktif (wajib jika JWT_SIGNING_ALG=HS256)
+//   JWT_SECRET_KEY_LAMA     Secret HS256 lama, dipisah koma, hanya untuk verifikasi
+//   JWT_PRIVATE_KEY_FILE    File PEM kunci privat Ed25519 / RSA (wajib untuk EdDSA / RS256)
+//   JWT_PUBLIC_KEY_FILES    File PEM kunci publik lama, dipisah koma, hanya untuk verifikasi
+// Kunci publik asimetris diterbitkan di GET /.well-known/jwks.json agar layanan lain
+// dapat memverifikasi token tanpa mengetahui secret.
+
+// kunciJWT adalah satu kunci dalam keyset.
+type kunciJWT struct {
+	KID        string
+	Metode     jwt.SigningMethod
+	Tanda      interface{} // Kunci penanda; nil untuk kunci yang hanya memverifikasi
+	Verifikasi interface{} // []byte / ed25519.PublicKey / *rsa.PublicKey
+}
+
+// setKunciJWT berisi kunci aktif dan seluruh kunci verifikasi (termasuk kunci aktif).
+type setKunciJWT struct {
+	aktif  *kunciJWT
+	semua  map[string]*kunciJWT
+	metode []string
+}
+
+// keysetJWT diisi siapkanKunciJWT() saat startup.
+var keysetJWT *setKunciJWT
+
+// panjangSecretMinimal adalah panjang secret HS256 yang disarankan (256 bit).
+const panjangSecretMinimal = 32
+
+// siapkanKunciJWT memuat keyset dari environment. Server menolak berjalan jika
+// tidak ada kunci penanda yang valid.
+func siapkanKunciJWT() {
+	set, err := muatKunciJWT()
+	if err != nil {
+		log.Fatal("❌ Konfigurasi kunci JWT tidak valid: ", err)
+	}
+	keysetJWT = set
+	log.Printf("🔑 Kunci JWT aktif: %s (kid %s), %d kunci verifikasi", set.aktif.Metode.Alg(), set.aktif.KID, len(set.semua))
+}
+
+func muatKunciJWT() (*setKunciJWT, error) {
+	set := &setKunciJWT{semua: map[string]*kunciJWT{}}
+	tambah := func(k *kunciJWT) {
+		if _, ada := set.semua[k.KID]; ada {
+			return
+		}
+		set.semua[k.KID] = k
+		if !mengandung(set.metode, k.Metode.Alg()) {
+			set.metode = append(set.metode, k.Metode.Alg())
+		}
+	}
+
+	alg := strings.TrimSpace(os.Getenv("JWT_SIGNING_ALG"))
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := os.Getenv("JWT_SECRET_KEY")
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET_KEY kosong")
+		}
+		set.aktif = kunciHMAC(secret)
+	case jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg():
+		berkas := os.Getenv("JWT_PRIVATE_KEY_FILE")
+		if berkas == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE wajib diisi untuk %s", alg)
+		}
+		k, err := bacaKunciPrivat(berkas)
+		if err != nil {
+			return nil, err
+		}
+		if k.Metode.Alg() != alg {
+			return nil, fmt.Errorf("kunci di %s adalah kunci %s, bukan %s", berkas, k.Metode.Alg(), alg)
+		}
+		set.aktif = k
+	default:
+		return nil, fmt.Errorf("JWT_SIGNING_ALG tidak didukung: %s", alg)
+	}
+	tambah(set.aktif)
+
+	// Secret HS256 tetap dipakai untuk verifikasi saat beralih ke kunci asimetris,
+	// agar token yang sudah terbit tidak langsung ditolak.
+	if alg != jwt.SigningMethodHS256.Alg() {
+		if secret := os.Getenv("JWT_SECRET_KEY"); secret != "" {
+			tambah(kunciVerifikasi(kunciHMAC(secret)))
+		}
+	}
+	for _, secret := range daftarEnv("JWT_SECRET_KEY_LAMA") {
+		tambah(kunciVerifikasi(kunciHMAC(secret)))
+	}
+	for _, berkas := range daftarEnv("JWT_PUBLIC_KEY_FILES") {
+		k, err := bacaKunciPublik(berkas)
+		if err != nil {
+			return nil, err
+		}
+		tambah(k)
+	}
+	return set, nil
+}
+
+// daftarEnv membaca environment variable berisi daftar dipisah koma.
+func daftarEnv(nama string) []string {
+	var hasil []string
+	for _, s := range strings.Split(os.Getenv(nama), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			hasil = append(hasil, s)
+		}
+	}
+	return hasil
+}
+
+// kunciHMAC membuat kunci HS256. kid diturunkan dari hash secret sehingga stabil
+// di semua replika tanpa membocorkan secret.
+func kunciHMAC(secret string) *kunciJWT {
+	if len(secret) < panjangSecretMinimal {
+		log.Printf("⚠ Secret JWT kurang dari %d karakter; gunakan secret acak yang lebih panjang", panjangSecretMinimal)
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return &kunciJWT{
+		KID:        "hs-" + hex.EncodeToString(sum[:])[:12],
+		Metode:     jwt.SigningMethodHS256,
+		Tanda:      []byte(secret),
+		Verifikasi: []byte(secret),
+	}
+}
+
+// kunciVerifikasi menghapus kemampuan menandatangani dari kunci.
+func kunciVerifikasi(k *kunciJWT) *kunciJWT {
+	k.Tanda = nil
+	return k
+}
+
+// kunciAsimetris membuat kunci dari kunci publik Ed25519 / RSA; kid adalah hash kunci publik.
+func kunciAsimetris(publik crypto.PublicKey, privat crypto.PrivateKey) (*kunciJWT, error) {
+	der, err := x509.MarshalPKIXPublicKey(publik)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	k := &kunciJWT{KID: base64.RawURLEncoding.EncodeToString(sum[:12]), Verifikasi: publik, Tanda: privat}
+	switch p := publik.(type) {
+	case ed25519.PublicKey:
+		k.Metode = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if p.N.BitLen() < 2048 {
+			return nil, errors.New("kunci RSA minimal 2048 bit")
+		}
+		k.Metode = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("jenis kunci %T tidak didukung (gunakan Ed25519 atau RSA)", publik)
+	}
+	return k, nil
+}
+
+// bacaPEM membaca blok PEM pertama dari berkas.
+func bacaPEM(berkas string) (*pem.Block, error) {
+	data, err := os.ReadFile(berkas)
+	if err != nil {
+		return nil, err
+	}
+	blok, _ := pem.Decode(data)
+	if blok == nil {
+		return nil, fmt.Errorf("%s bukan berkas PEM", berkas)
+	}
+	return blok, nil
+}
+
+// bacaKunciPrivat membaca kunci privat PKCS#8 (Ed25519 / RSA) atau PKCS#1 (RSA).
+func bacaKunciPrivat(berkas string) (*kunciJWT, error) {
+	blok, err := bacaPEM(berkas)
+	if err != nil {
+		return nil, err
+	}
+	var privat crypto.Signer
+	if kunci, err := x509.ParsePKCS8PrivateKey(blok.Bytes); err == nil {
+		s, ok := kunci.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("jenis kunci di %s tidak didukung", berkas)
+		}
+		privat = s
+	} else if kunci, errRSA := x509.ParsePKCS1PrivateKey(blok.Bytes); errRSA == nil {
+		privat = kunci
+	} else {
+		return nil, fmt.Errorf("gagal membaca kunci privat %s: %v", berkas, err)
+	}
+	return kunciAsimetris(privat.Public(), privat)
+}
+
+// bacaKunciPublik membaca kunci publik PKIX (Ed25519 / RSA) untuk verifikasi saja.
+func bacaKunciPublik(berkas string) (*kunciJWT, error) {
+	blok, err := bacaPEM(berkas)
+	if err != nil {
+		return nil, err
+	}
+	publik, err := x509.ParsePKIXPublicKey(blok.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca kunci publik %s: %v", berkas, err)
+	}
+	return kunciAsimetris(publik, nil)
+}
+
+// ----- Tanda tangan & verifikasi -----
+
+// Audience (klaim "aud") untuk setiap jenis JWT. Semua jenis ditandatangani dengan kunci yang
+// sama, sehingga parseJWT mewajibkan aud yang sesuai agar tiket login atau state OIDC tidak
+// dapat dipakai sebagai access token (dan sebaliknya).
+const (
+	audAksesToken = "db-bappeda:akses"
+	audTiketLogin = "db-bappeda:tiket-login"
+	audStateOIDC  = "db-bappeda:state-oidc"
+)
+
+// tandatanganiJWT menandatangani claims dengan kunci aktif dan menambahkan header kid.
+func tandatanganiJWT(claims jwt.Claims) (string, error) {
+	k := keysetJWT.aktif
+	token := jwt.NewWithClaims(k.Metode, claims)
+	token.Header["kid"] = k.KID
+	return token.SignedString(k.Tanda)
+}
+
+// parseJWT memverifikasi token dengan kunci sesuai header kid dan mewajibkan klaim aud
+// sama dengan aud. Token tanpa kid (terbit sebelum keyset dipakai) diverifikasi dengan kunci aktif.
+func parseJWT(tokenString string, claims jwt.Claims, aud string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		k := keysetJWT.aktif
+		if kid, ada := token.Header["kid"].(string); ada {
+			if k, ada = keysetJWT.semua[kid]; !ada {
+				return nil, fmt.Errorf("kid tidak dikenal: %s", kid)
+			}
+		}
+		// Cegah algorithm confusion: algoritma token harus sama dengan algoritma kuncinya
+		if token.Method.Alg() != k.Metode.Alg() {
+			return nil, fmt.Errorf("algoritma %s tidak cocok dengan kunci %s", token.Method.Alg(), k.KID)
+		}
+		return k.Verifikasi, nil
+	}, jwt.WithValidMethods(keysetJWT.metode), jwt.WithAudience(aud))
+}
+
+// ----- JWKS -----
+
+// jwk adalah representasi JSON Web Key (RFC 7517) untuk kunci publik.
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	KID string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// GetJWKS: GET /.well-known/jwks.json
+// Hanya kunci publik asimetris yang diterbitkan; secret HS256 tidak pernah dibuka.
+func GetJWKS(c *gin.Context) {
+	keys := []jwk{}
+	for _, k := range keysetJWT.semua {
+		switch p := k.Verifikasi.(type) {
+		case ed25519.PublicKey:
+			keys = append(keys, jwk{Kty: "OKP", Use: "sig", Alg: k.Metode.Alg(), KID: k.KID, Crv: "Ed25519",
+				X: base64.RawURLEncoding.EncodeToString(p)})
+		case *rsa.PublicKey:
+			keys = append(keys, jwk{Kty: "RSA", Use: "sig", Alg: k.Metode.Alg(), KID: k.KID,
+				N: base64.RawURLEncoding.EncodeToString(p.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.E)).Bytes())})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KID < keys[j].KID })
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
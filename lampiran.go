@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= LAMPIRAN PENGAJUAN =========
+//
+// Satu pengajuan dapat memiliki banyak lampiran bertipe. File di ./uploads tidak pernah
+// ditimpa atau dihapus selama pengajuannya ada: unggahan pengganti menjadi versi baru
+// (versi lama diberi DigantikanPada) dan penghapusan hanya mengisi DihapusPada.
+
+// Jenis lampiran pengajuan.
+const (
+	JenisLampiranDokumen     = "dokumen_pengajuan" // Dokumen utama (field dokumen_pengajuan pada form pengajuan)
+	JenisLampiranPersyaratan = "persyaratan"       // Dokumen untuk slot persyaratan
+	JenisLampiranPendukung   = "pendukung"
+	JenisLampiranLainnya     = "lainnya"
+)
+
+var jenisLampiranSah = []string{JenisLampiranDokumen, JenisLampiranPersyaratan, JenisLampiranPendukung, JenisLampiranLainnya}
+
+// lampiranTunggal: jenis yang hanya memiliki satu lampiran berlaku (per slot untuk persyaratan),
+// sehingga unggahan baru otomatis menggantikan versi sebelumnya.
+func lampiranTunggal(jenis string) bool {
+	return jenis == JenisLampiranDokumen || jenis == JenisLampiranPersyaratan
+}
+
+// kondisiLampiranBerlaku: versi terbaru yang belum dihapus
+const kondisiLampiranBerlaku = "digantikan_pada IS NULL AND dihapus_pada IS NULL"
+
+// preloadLampiranBerlaku membatasi preload Lampiran pada versi yang berlaku.
+func preloadLampiranBerlaku(db *gorm.DB) *gorm.DB {
+	return db.Where(kondisiLampiranBerlaku).Order("id_lampiran_pengajuan")
+}
+
+// simpanBerkasLampiran menyimpan file upload ke ./uploads dengan nama unik, lalu mengisi
+// ukuran, MIME (dideteksi dari isi) dan SHA-256. Baris lampiran belum dibuat.
+func simpanBerkasLampiran(berkas *multipart.FileHeader, batas int64) (LampiranPengajuan, error) {
+	var lampiran LampiranPengajuan
+	if berkas.Size > batas {
+		return lampiran, errors.New("Ukuran file '" + berkas.Filename + "' melebihi " + strconv.FormatInt(batas/1024, 10) + " KB")
+	}
+	src, err := berkas.Open()
+	if err != nil {
+		return lampiran, errors.New("Gagal membaca file: " + err.Error())
+	}
+	defer src.Close()
+
+	namaFile := filepath.Base(berkas.Filename)
+	os.MkdirAll("./uploads", os.ModePerm)
+	// Nanodetik agar dua unggahan bernama sama tidak saling menimpa
+	path := "./uploads/" + strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + namaFile
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return lampiran, errors.New("Gagal menyimpan file: " + err.Error())
+	}
+	defer dst.Close()
+
+	// 512 byte pertama dipakai untuk deteksi MIME, lalu seluruh isi ditulis sambil di-hash
+	awal := make([]byte, 512)
+	n, err := io.ReadFull(src, awal)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		os.Remove(path)
+		return lampiran, errors.New("Gagal membaca file: " + err.Error())
+	}
+	hash := sha256.New()
+	tulis := io.MultiWriter(dst, hash)
+	ukuran, err := io.Copy(tulis, io.MultiReader(bytes.NewReader(awal[:n]), src))
+	if err != nil {
+		os.Remove(path)
+		return lampiran, errors.New("Gagal menyimpan file: " + err.Error())
+	}
+
+	lampiran.NamaFile = namaFile
+	lampiran.Path = path
+	lampiran.UkuranByte = ukuran
+	lampiran.MIME = http.DetectContentType(awal[:n])
+	lampiran.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return lampiran, nil
+}
+
+// cariLampiranTunggal mengambil lampiran berlaku untuk jenis tunggal (dan slot persyaratannya).
+func cariLampiranTunggal(tx *gorm.DB, idForm uint, jenis string, slot *PersyaratanPengajuan) (*LampiranPengajuan, error) {
+	query := tx.Where("id_form_pengajuan = ? AND jenis = ?", idForm, jenis).Where(kondisiLampiranBerlaku)
+	if slot != nil {
+		query = query.Where("id_persyaratan_pengajuan = ?", slot.ID)
+	}
+	var lampiran LampiranPengajuan
+	if err := query.First(&lampiran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lampiran, nil
+}
+
+// catatLampiranBaru menyimpan baris lampiran (versi berikut dari pendahulu jika ada) dan
+// menyelaraskan kolom lama: DokumenPengajuanPath pada form dan dokumen pada slot persyaratan.
+func catatLampiranBaru(tx *gorm.DB, claims *Claims, form *FormPengajuan, lampiran *LampiranPengajuan, jenis string, slot *PersyaratanPengajuan, pendahulu *LampiranPengajuan) error {
+	now := time.Now()
+	lampiran.IDFormPengajuan = form.ID
+	lampiran.Jenis = jenis
+	lampiran.Versi = 1
+	lampiran.RoleUploader = claims.Role
+	lampiran.IDUploader = &claims.ID
+	lampiran.NamaUploader = claims.Nama
+	lampiran.DiunggahPada = now
+	if slot != nil {
+		lampiran.IDPersyaratanPengajuan = &slot.ID
+	}
+	catatan := "Lampiran '" + lampiran.NamaFile + "' (" + jenis + ") ditambahkan"
+	if pendahulu != nil {
+		lampiran.Versi = pendahulu.Versi + 1
+		lampiran.IDLampiranSebelumnya = &pendahulu.ID
+		if err := tx.Model(pendahulu).Update("digantikan_pada", now).Error; err != nil {
+			return err
+		}
+		catatan = "Lampiran '" + lampiran.NamaFile + "' (" + jenis + ") menggantikan versi " + strconv.Itoa(pendahulu.Versi)
+	}
+	if err := tx.Create(lampiran).Error; err != nil {
+		return err
+	}
+
+	switch jenis {
+	case JenisLampiranDokumen:
+		form.DokumenPengajuanPath = &lampiran.Path
+		if err := tx.Model(&FormPengajuan{}).Where("id_form_pengajuan = ?", form.ID).
+			Update("dokumen_pengajuan_path", lampiran.Path).Error; err != nil {
+			return err
+		}
+	case JenisLampiranPersyaratan:
+		// Dokumen baru harus diperiksa ulang
+		slot.DokumenPath = &lampiran.Path
+		slot.IDLampiranPengajuan = &lampiran.ID
+		slot.NamaFile = lampiran.NamaFile
+		slot.UkuranByte = lampiran.UkuranByte
+		slot.DiunggahPada = &now
+		slot.StatusVerifikasi = StatusVerifBelum
+		slot.CatatanVerifikasi = ""
+		slot.IDVerifikator = nil
+		slot.DiverifikasiPada = nil
+		if err := tx.Save(slot).Error; err != nil {
+			return err
+		}
+	}
+	return catatRiwayatPengajuan(tx, claims, form.ID, &form.StatusProses, form.StatusProses, catatan)
+}
+
+// unggahLampiran menyimpan file lalu mencatatnya sebagai lampiran dalam satu transaksi.
+// Jika gagal, respons error sudah dikirim dan fungsi mengembalikan nil.
+func unggahLampiran(c *gin.Context, form *FormPengajuan, berkas *multipart.FileHeader, batas int64, jenis string, slot *PersyaratanPengajuan, pendahulu *LampiranPengajuan) *LampiranPengajuan {
+	lampiran, err := simpanBerkasLampiran(berkas, batas)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	claims := ambilClaims(c)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		return catatLampiranBaru(tx, claims, form, &lampiran, jenis, slot, pendahulu)
+	})
+	if err != nil {
+		os.Remove(lampiran.Path)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan lampiran: " + err.Error()})
+		return nil
+	}
+	return &lampiran
+}
+
+// muatLampiranMilik mengambil lampiran :id_lampiran milik pengajuan (tidak termasuk yang dihapus).
+// Jika gagal, respons error sudah dikirim dan fungsi mengembalikan false.
+func muatLampiranMilik(c *gin.Context, idForm uint, lampiran *LampiranPengajuan) bool {
+	if err := DB.Where("id_lampiran_pengajuan = ? AND id_form_pengajuan = ? AND dihapus_pada IS NULL", c.Param("id_lampiran"), idForm).
+		First(lampiran).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lampiran tidak ditemukan"})
+		return false
+	}
+	return true
+}
+
+// cekPengajuanDapatDiubah menolak perubahan lampiran setelah pengajuan diverifikasi.
+func cekPengajuanDapatDiubah(c *gin.Context, form *FormPengajuan) bool {
+	if !statusDapatDiubah(form.StatusProses) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lampiran tidak dapat diubah pada pengajuan berstatus '" + form.StatusProses + "'"})
+		return false
+	}
+	return true
+}
+
+// GetLampiranPengajuan: GET /api/pengajuan/:id/lampiran (?jenis=&semua_versi=true)
+// Default hanya versi yang berlaku; semua_versi=true menyertakan versi lama dan lampiran yang dihapus.
+func GetLampiranPengajuan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) {
+		return
+	}
+
+	query := DB.Where("id_form_pengajuan = ?", form.ID)
+	if c.Query("semua_versi") != "true" {
+		query = query.Where(kondisiLampiranBerlaku)
+	}
+	if jenis := c.Query("jenis"); jenis != "" {
+		if !mengandung(jenisLampiranSah, jenis) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parameter jenis tidak dikenal"})
+			return
+		}
+		query = query.Where("jenis = ?", jenis)
+	}
+	var lampiran []LampiranPengajuan
+	if err := query.Order("jenis, id_persyaratan_pengajuan, versi DESC").Find(&lampiran).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil lampiran"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": lampiran})
+}
+
+// TambahLampiranPengajuan: POST /api/pengajuan/:id/lampiran (multipart)
+// Field: berkas (file), jenis, id_persyaratan_pengajuan (wajib untuk jenis persyaratan),
+// menggantikan (opsional, ID lampiran pendukung/lainnya yang diganti versi baru).
+// Dokumen pengajuan & persyaratan selalu menggantikan versi berlaku sebelumnya.
+func TambahLampiranPengajuan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) || !cekPengajuanDapatDiubah(c, &form) {
+		return
+	}
+
+	jenis := c.PostForm("jenis")
+	if !mengandung(jenisLampiranSah, jenis) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Jenis lampiran tidak dikenal"})
+		return
+	}
+	berkas, err := c.FormFile("berkas")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File lampiran wajib diunggah (field 'berkas')"})
+		return
+	}
+
+	batas := int64(batasUnggahByte)
+	var slot *PersyaratanPengajuan
+	if jenis == JenisLampiranPersyaratan {
+		slot = &PersyaratanPengajuan{}
+		if err := DB.Where("id_persyaratan_pengajuan = ? AND id_form_pengajuan = ?", c.PostForm("id_persyaratan_pengajuan"), form.ID).
+			First(slot).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id_persyaratan_pengajuan tidak valid untuk pengajuan ini"})
+			return
+		}
+		if batas, err = batasBerkasPersyaratan(slot, berkas); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var pendahulu *LampiranPengajuan
+	if lampiranTunggal(jenis) {
+		if pendahulu, err = cariLampiranTunggal(DB, form.ID, jenis, slot); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	} else if id := c.PostForm("menggantikan"); id != "" {
+		pendahulu = &LampiranPengajuan{}
+		if err := DB.Where("id_lampiran_pengajuan = ? AND id_form_pengajuan = ? AND jenis = ?", id, form.ID, jenis).
+			Where(kondisiLampiranBerlaku).First(pendahulu).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lampiran yang digantikan tidak ditemukan atau bukan versi terbaru"})
+			return
+		}
+	}
+
+	lampiran := unggahLampiran(c, &form, berkas, batas, jenis, slot, pendahulu)
+	if lampiran == nil {
+		return
+	}
+	c.JSON(http.StatusCreated, lampiran)
+}
+
+// UnduhLampiranPengajuan: GET /api/pengajuan/:id/lampiran/:id_lampiran/unduh
+// Versi lama tetap dapat diunduh; lampiran yang dihapus tidak.
+func UnduhLampiranPengajuan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) {
+		return
+	}
+	var lampiran LampiranPengajuan
+	if !muatLampiranMilik(c, form.ID, &lampiran) {
+		return
+	}
+	if _, err := os.Stat(lampiran.Path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File lampiran tidak ditemukan di server"})
+		return
+	}
+	c.FileAttachment(lampiran.Path, lampiran.NamaFile)
+}
+
+// HapusLampiranPengajuan: DELETE /api/pengajuan/:id/lampiran/:id_lampiran
+// Hanya versi berlaku yang dapat dihapus. Baris dan file tetap disimpan (DihapusPada terisi).
+func HapusLampiranPengajuan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) || !cekPengajuanDapatDiubah(c, &form) {
+		return
+	}
+	var lampiran LampiranPengajuan
+	if !muatLampiranMilik(c, form.ID, &lampiran) {
+		return
+	}
+	if lampiran.DigantikanPada != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Versi lama lampiran tidak dapat dihapus"})
+		return
+	}
+
+	claims := ambilClaims(c)
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&lampiran).Update("dihapus_pada", now).Error; err != nil {
+			return err
+		}
+		switch lampiran.Jenis {
+		case JenisLampiranDokumen:
+			if err := tx.Model(&FormPengajuan{}).Where("id_form_pengajuan = ?", form.ID).
+				Update("dokumen_pengajuan_path", nil).Error; err != nil {
+				return err
+			}
+		case JenisLampiranPersyaratan:
+			if err := tx.Model(&PersyaratanPengajuan{}).Where("id_persyaratan_pengajuan = ?", lampiran.IDPersyaratanPengajuan).
+				Updates(map[string]interface{}{
+					"dokumen_path": nil, "id_lampiran_pengajuan": nil, "nama_file": "", "ukuran_byte": 0, "diunggah_pada": nil,
+					"status_verifikasi": StatusVerifBelum, "catatan_verifikasi": "", "id_verifikator": nil, "diverifikasi_pada": nil,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return catatRiwayatPengajuan(tx, claims, form.ID, &form.StatusProses, form.StatusProses,
+			"Lampiran '"+lampiran.NamaFile+"' ("+lampiran.Jenis+") dihapus")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus lampiran"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lampiran berhasil dihapus"})
+}
+
+// lampiranDariFile membuat baris lampiran hasil migrasi dari file yang sudah ada di disk.
+// File yang hilang tetap dicatat (ukuran & hash kosong) agar path lamanya tidak hilang.
+func lampiranDariFile(path string, diunggah time.Time) LampiranPengajuan {
+	lampiran := LampiranPengajuan{NamaFile: filepath.Base(path), Path: path, Versi: 1, DiunggahPada: diunggah}
+	f, err := os.Open(path)
+	if err != nil {
+		return lampiran
+	}
+	defer f.Close()
+	awal := make([]byte, 512)
+	n, _ := io.ReadFull(f, awal)
+	hash := sha256.New()
+	hash.Write(awal[:n])
+	sisa, _ := io.Copy(hash, f)
+	lampiran.UkuranByte = int64(n) + sisa
+	lampiran.MIME = http.DetectContentType(awal[:n])
+	lampiran.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return lampiran
+}
+
+// migrasiLampiran mencatat DokumenPengajuanPath dan dokumen slot persyaratan yang belum
+// memiliki baris lampiran sebagai lampiran versi 1.
+func migrasiLampiran() {
+	var form []FormPengajuan
+	DB.Where("dokumen_pengajuan_path IS NOT NULL AND dokumen_pengajuan_path <> ''").Where(kondisiPengajuanAktif).
+		Where("NOT EXISTS (SELECT 1 FROM lampiran_pengajuan l WHERE l.id_form_pengajuan = form_pengajuan.id_form_pengajuan AND l.jenis = ?)", JenisLampiranDokumen).
+		Find(&form)
+	for _, f := range form {
+		lampiran := lampiranDariFile(*f.DokumenPengajuanPath, f.CreatedAt)
+		lampiran.IDFormPengajuan, lampiran.Jenis = f.ID, JenisLampiranDokumen
+		if err := DB.Create(&lampiran).Error; err != nil {
+			log.Println("⚠ Gagal memigrasikan dokumen pengajuan", f.ID, ":", err)
+		}
+	}
+
+	var slot []PersyaratanPengajuan
+	DB.Where("dokumen_path IS NOT NULL").
+		Where("NOT EXISTS (SELECT 1 FROM lampiran_pengajuan l WHERE l.id_persyaratan_pengajuan = persyaratan_pengajuan.id_persyaratan_pengajuan)").
+		Find(&slot)
+	for _, s := range slot {
+		diunggah := time.Now()
+		if s.DiunggahPada != nil {
+			diunggah = *s.DiunggahPada
+		}
+		lampiran := lampiranDariFile(*s.DokumenPath, diunggah)
+		lampiran.IDFormPengajuan, lampiran.Jenis = s.IDFormPengajuan, JenisLampiranPersyaratan
+		lampiran.IDPersyaratanPengajuan = &s.ID
+		if s.NamaFile != "" {
+			lampiran.NamaFile = s.NamaFile
+		}
+		if err := DB.Create(&lampiran).Error; err != nil {
+			log.Println("⚠ Gagal memigrasikan dokumen persyaratan", s.ID, ":", err)
+			continue
+		}
+		DB.Model(&PersyaratanPengajuan{}).Where("id_persyaratan_pengajuan = ?", s.ID).Update("id_lampiran_pengajuan", lampiran.ID)
+	}
+}
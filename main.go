@@ -29,96 +29,185 @@ func main() {
 		return
 	}
 
+	// Muat kunci penandatangan JWT; server tidak dijalankan tanpa kunci yang valid
+	siapkanKunciJWT()
+
+	r := siapkanRouter()
+
+	// Start server
+	port := ":8080"
+	log.Println("🚀 Server running on http://localhost" + port)
+	r.Run(port) // Cara menjalankan server di Gin
+}
+
+// siapkanRouter menyusun router Gin beserta seluruh route dan middleware-nya.
+func siapkanRouter() *gin.Engine {
 	// Router Gin. gin.Default() sudah termasuk logger dan recovery middleware.
 	r := gin.Default()
 
+	// c.ClientIP() dipakai untuk pembatasan login, audit, dan sesi. Header X-Forwarded-For
+	// hanya dipercaya dari reverse proxy di TRUSTED_PROXIES (IP/CIDR dipisah koma);
+	// jika kosong, IP koneksi langsung yang dipakai.
+	if err := r.SetTrustedProxies(daftarEnv("TRUSTED_PROXIES")); err != nil {
+		log.Fatal("❌ TRUSTED_PROXIES tidak valid: ", err)
+	}
+
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", headerCSRF},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Static file server untuk folder "uploads"
-	r.Static("/uploads", "./uploads")
+	// Kunci publik JWT untuk verifikasi token oleh layanan lain
+	r.GET("/.well-known/jwks.json", GetJWKS)
+
+	// Folder "uploads" tidak disajikan statis: lampiran diunduh lewat
+	// /api/pengajuan/:id/lampiran/:id_lampiran/unduh yang memeriksa izin & OPD
 
 	// Grup utama untuk semua endpoint di bawah /api
 	api := r.Group("/api")
+	// Wajib header X-CSRF-Token untuk request mengubah data yang memakai cookie.
+	// Login & reset password tidak diautentikasi dengan cookie sehingga dikecualikan.
+	api.Use(CSRFMiddleware("/api/login", "/api/login/2fa", "/api/login/pilih-peran", "/api/reset-password"))
 
 	// =======================================================
 	// --- ROUTE PUBLIK (Tidak Perlu Login) ---
 	// =======================================================
+	api.GET("/csrf", GetCSRFToken) // Token CSRF untuk header X-CSRF-Token
 	api.POST("/login", LoginHandler)
+	api.POST("/login/2fa", Login2FAHandler)           // Langkah kedua login untuk akun dengan 2FA aktif
+	api.POST("/login/pilih-peran", PilihPeranHandler) // Langkah kedua login untuk akun dengan beberapa peran
 	api.POST("/logout", LogoutHandler)
+	api.GET("/oidc/status", OIDCStatus)                   // Apakah login SSO diaktifkan
+	api.GET("/oidc/login", OIDCLogin)                     // Mengarahkan ke identity provider
+	api.GET("/oidc/callback", OIDCCallback)               // Kembali dari identity provider
+	api.POST("/refresh", RefreshHandler)                  // Menukar refresh token (cookie) dengan access token baru
+	api.POST("/reset-password", ResetPasswordDenganToken) // Menukar token reset dari admin dengan password baru
 
 	// Rute GET /standar-pelayanan tetap publik agar semua user bisa melihat standar yang tersedia
 	api.GET("/standar-pelayanan", GetAllJenisPelayanan) // Tampilan publik / daftar master
 
 	// =======================================================
-	// --- ROUTE KHUSUS ADMIN / SUPERUSER (PEMDA) ---
+	// --- ROUTE YANG MEMBUTUHKAN LOGIN ---
+	// Setiap route mendeklarasikan izin yang dibutuhkan (lihat katalogIzin di rbac.go).
+	// Izin dipetakan ke peran lewat tabel izin_peran dan dapat diatur admin.
 	// =======================================================
-	adminRoutes := api.Group("/")
-	adminRoutes.Use(AuthMiddleware("pemda")) // Hanya pemda/admin yang boleh
+	auth := api.Group("/")
+	auth.Use(AuthMiddleware()) // Semua user yang login; otorisasi per route lewat RequirePermission
 	{
-		// 1. Route untuk mengelola data master OPD
-		adminRoutes.POST("/opd", CreateOPD)
-		adminRoutes.GET("/opd", GetAllOPD)
-
-		// 2. Route untuk mendaftarkan user baru
-		register := adminRoutes.Group("/register")
+		// 1. Akun milik sendiri (cukup login, tidak untuk API key)
+		me := auth.Group("/me", WajibSesiLogin())
 		{
-			register.POST("/opd", CreateUserOPD)
-			register.POST("/pemda", CreateUserPemda)
+			me.GET("", GetProfilSaya)
+			me.PUT("", UpdateProfilSaya)
+			me.POST("/password", GantiPassword)
+			me.POST("/logout-all", LogoutSemuaPerangkat)
+			me.POST("/switch-role", SwitchRole)
+			me.GET("/2fa", GetStatus2FA)
+			me.POST("/2fa/enroll", Enroll2FA)
+			me.POST("/2fa/confirm", Konfirmasi2FA)
+			me.POST("/2fa/disable", Nonaktifkan2FA)
+			me.POST("/2fa/recovery-codes", BuatUlangKodePemulihan)
+			me.GET("/notifikasi", GetNotifikasiSaya)
+			me.POST("/notifikasi/baca-semua", BacaSemuaNotifikasi)
+			me.POST("/notifikasi/:id/baca", BacaNotifikasi)
 		}
 
-		// 3. Route Validasi Standar Pelayanan (BARU)
-		adminRoutes.POST("/standar-pelayanan/:id/validate", ValidateJenisPelayanan)
+		// 2. Data master OPD
+		auth.POST("/opd", RequirePermission("opd.create"), CreateOPD)
+		auth.GET("/opd", RequirePermission("opd.read"), GetAllOPD)
 
-		// 4. Route Pemda untuk melihat SEMUA Form Pengajuan
-		adminRoutes.GET("/pengajuan", GetAllFormPengajuan)
-	}
-
-	// =======================================================
-	// --- ROUTE KHUSUS OPD ---
-	// =======================================================
-	opdRoutes := api.Group("/")
-	opdRoutes.Use(AuthMiddleware("opd"))
-	{
-		// 1. ROUTE MASTER: OPD membuat standar pelayanan mereka sendiri
-		opdRoutes.POST("/standar-pelayanan", CreateJenisPelayanan)
-		opdRoutes.GET("/standar-pelayanan/opd/:id_opd", GetStandarPelayananByOPD)
-		opdRoutes.GET("/user/:id/pengajuan", GetFormPengajuanByUserOPD)
-
-		// 2. ROUTE TRANSAKSI: Create/Update Form Pengajuan
-		opdRoutes.POST("/pengajuan", CreateFormPengajuan)
-		opdRoutes.PUT("/pengajuan/:id", UpdateFormPengajuan)
-		opdRoutes.DELETE("/pengajuan/:id", DeleteFormPengajuan)
-
-		// 3. ROUTE MASTER PEMOHON: OPD mengelola data master pemohon (BARU)
-		pemohonRoutes := opdRoutes.Group("/form-pemohon")
+		// 3. Manajemen user, peran & sesi
+		register := auth.Group("/register")
 		{
-			pemohonRoutes.POST("/", CreateFormPemohon)
-			pemohonRoutes.GET("/", GetAllFormPemohon)
-			pemohonRoutes.GET("/:id", GetFormPemohonByID)
-			pemohonRoutes.PUT("/:id", UpdateFormPemohon)
-			pemohonRoutes.DELETE("/:id", DeleteFormPemohon)
+			register.POST("/opd", RequirePermission("user.create"), CreateUserOPD)
+			register.POST("/pemda", RequirePermission("user.create"), CreateUserPemda)
+		}
+		auth.GET("/users", RequirePermission("user.read"), GetAllUser)
+		auth.GET("/users/:role/:id", RequirePermission("user.read"), GetUserByID)
+		auth.PUT("/users/:role/:id", RequirePermission("user.update"), UpdateUser)
+		auth.POST("/users/:role/:id/deactivate", RequirePermission("user.delete"), NonaktifkanUser)
+		auth.POST("/users/:role/:id/activate", RequirePermission("user.delete"), AktifkanUser)
+		auth.DELETE("/users/:role/:id", RequirePermission("user.delete"), DeleteUser)
+		auth.POST("/users/:role/:id/reset-password", RequirePermission("user.reset_password"), TerbitkanTokenResetPassword)
+		auth.PUT("/users/:role/:id/peran", RequirePermission("user.peran"), UbahPeranUser)
+		auth.POST("/users/:role/:id/2fa/reset", RequirePermission("user.reset_password"), Reset2FAUser)
+		auth.GET("/users/:role/:id/sesi", RequirePermission("sesi.manage"), GetSesiUser)
+		auth.DELETE("/sesi/:id", RequirePermission("sesi.manage"), CabutSesiAdmin)
+		auth.GET("/api-keys", RequirePermission("api_key.manage"), GetAllAPIKey)
+		auth.POST("/api-keys", RequirePermission("api_key.manage"), CreateAPIKey)
+		auth.DELETE("/api-keys/:id", RequirePermission("api_key.manage"), CabutAPIKey)
+
+		auth.GET("/izin", RequirePermission("peran.read"), GetKatalogIzin)
+		auth.GET("/peran", RequirePermission("peran.read"), GetAllPeran)
+		auth.POST("/peran", RequirePermission("peran.manage"), CreatePeran)
+		auth.PUT("/peran/:kode/izin", RequirePermission("peran.manage"), UpdateIzinPeran)
+		auth.PUT("/peran/:kode/wajib-2fa", RequirePermission("peran.manage"), SetWajib2FA)
+
+		// 4. Log audit & percobaan login
+		auth.GET("/audit", RequirePermission("audit.read"), GetAllLogAudit)
+		auth.GET("/login-attempts", RequirePermission("login_attempt.read"), GetAllLoginAttempt)
+		auth.POST("/login-attempts/unlock", RequirePermission("login_attempt.unlock"), BukaKunciLogin)
+
+		// 5. Kalender hari libur (dasar perhitungan Hari Kerja)
+		hariLibur := auth.Group("/hari-libur")
+		{
+			hariLibur.GET("", RequirePermission("hari_libur.read"), GetAllHariLibur)
+			hariLibur.POST("", RequirePermission("hari_libur.manage"), CreateHariLibur)
+			hariLibur.POST("/import", RequirePermission("hari_libur.manage"), ImportHariLibur)
+			hariLibur.PUT("/:id", RequirePermission("hari_libur.manage"), UpdateHariLibur)
+			hariLibur.DELETE("/:id", RequirePermission("hari_libur.manage"), DeleteHariLibur)
+		}
+		auth.GET("/kalender/hari-kerja", RequirePermission("hari_libur.read"), GetHariKerja) // Helper kalender: jumlah hari kerja antara dua tanggal
+
+		// 6. Standar pelayanan: dibuat OPD, divalidasi Pemda (setuju / tolak / perlu revisi), diajukan ulang atau ditarik OPD
+		auth.POST("/standar-pelayanan", RequirePermission("standar.create"), CreateJenisPelayanan)
+		auth.GET("/standar-pelayanan/opd/:id_opd", RequirePermission("standar.read"), GetStandarPelayananByOPD)
+		auth.PUT("/standar-pelayanan/:id", RequirePermission("standar.create"), UpdateJenisPelayanan)    // Hanya Menunggu Validasi / Perlu Revisi
+		auth.DELETE("/standar-pelayanan/:id", RequirePermission("standar.delete"), DeleteJenisPelayanan) // Arsip (soft delete)
+		auth.POST("/standar-pelayanan/:id/pulihkan", RequirePermission("standar.delete"), PulihkanJenisPelayanan)
+		auth.POST("/standar-pelayanan/:id/tarik", RequirePermission("standar.create"), TarikJenisPelayanan)
+		auth.POST("/standar-pelayanan/:id/validate", RequirePermission("standar.validate"), ValidateJenisPelayanan)
+		auth.POST("/standar-pelayanan/:id/ajukan-ulang", RequirePermission("standar.create"), AjukanUlangJenisPelayanan) // Setelah Perlu Revisi / Ditolak
+		auth.GET("/standar-pelayanan/:id/komentar-revisi", RequirePermission("standar.read"), GetKomentarRevisi)
+		auth.GET("/standar-pelayanan/:id/versions", RequirePermission("standar.read"), GetAllVersiStandar)
+		auth.POST("/standar-pelayanan/:id/versions", RequirePermission("standar.create"), BuatVersiStandar) // Revisi standar yang sudah Disetujui
+		auth.GET("/standar-pelayanan/:id/versions/diff", RequirePermission("standar.read"), GetDiffVersiStandar)
+		auth.GET("/standar-pelayanan/:id/versions/:nomor", RequirePermission("standar.read"), GetVersiStandar)
+
+		// 7. Form pengajuan
+		auth.GET("/pengajuan", RequirePermission("pengajuan.read_all"), GetAllFormPengajuan)
+		auth.GET("/user/:id/pengajuan", RequirePermission("pengajuan.read"), GetFormPengajuanByUserOPD)
+		auth.POST("/pengajuan", RequirePermission("pengajuan.create"), CreateFormPengajuan)
+		auth.GET("/pengajuan/:id", RequirePermission("pengajuan.read"), GetFormPengajuanByID)
+		auth.PUT("/pengajuan/:id", RequirePermission("pengajuan.update"), UpdateFormPengajuan)
+		auth.DELETE("/pengajuan/:id", RequirePermission("pengajuan.delete"), DeleteFormPengajuan)
+		auth.POST("/pengajuan/:id/transition", RequirePermission("pengajuan.transition"), TransitionFormPengajuan) // Aturan role ada di state machine
+		auth.GET("/pengajuan/:id/timeline", RequirePermission("pengajuan.read"), GetTimelinePengajuan)
+		auth.GET("/pengajuan/:id/persyaratan", RequirePermission("pengajuan.read"), GetPersyaratanPengajuan)
+		auth.POST("/pengajuan/:id/persyaratan/:id_slot/dokumen", RequirePermission("pengajuan.update"), UnggahDokumenPersyaratan)
+		auth.PUT("/pengajuan/:id/persyaratan/:id_slot/verifikasi", RequirePermission("pengajuan.verifikasi"), VerifikasiPersyaratan)
+		auth.GET("/pengajuan/:id/lampiran", RequirePermission("pengajuan.read"), GetLampiranPengajuan)
+		auth.POST("/pengajuan/:id/lampiran", RequirePermission("pengajuan.update"), TambahLampiranPengajuan)
+		auth.GET("/pengajuan/:id/lampiran/:id_lampiran/unduh", RequirePermission("pengajuan.read"), UnduhLampiranPengajuan)
+		auth.DELETE("/pengajuan/:id/lampiran/:id_lampiran", RequirePermission("pengajuan.update"), HapusLampiranPengajuan) // Soft delete, file tetap disimpan
+
+		// 8. Master pemohon milik OPD
+		pemohonRoutes := auth.Group("/form-pemohon")
+		{
+			pemohonRoutes.POST("/", RequirePermission("pemohon.write"), CreateFormPemohon)
+			pemohonRoutes.GET("/", RequirePermission("pemohon.read"), GetAllFormPemohon)
+			pemohonRoutes.GET("/:id", RequirePermission("pemohon.read"), GetFormPemohonByID)
+			pemohonRoutes.PUT("/:id", RequirePermission("pemohon.write"), UpdateFormPemohon)
+			pemohonRoutes.DELETE("/:id", RequirePermission("pemohon.write"), DeleteFormPemohon)
 		}
-	}
 
-	// =======================================================
-	// --- ROUTE YANG BISA DIAKSES KEDUA ROLE (Get Detail) ---
-	// =======================================================
-	sharedRoutes := api.Group("/") // <-- Grup ini tidak lagi "/pengajuan"
-	sharedRoutes.Use(AuthMiddleware("opd", "pemda")) // OPD & Pemda boleh
-	{
-		// Keduanya bisa lihat detail pengajuan
-		sharedRoutes.GET("/pengajuan/:id", GetFormPengajuanByID)
+		// 9. Pencarian full-text (hasil dibatasi sesuai role & OPD)
+		auth.GET("/search", RequirePermission("pengajuan.read"), Search)
 	}
 
-	// Start server
-	port := ":8080"
-	log.Println("🚀 Server running on http://localhost" + port)
-	r.Run(port) // Cara menjalankan server di Gin
-}
\ No newline at end of file
+	return r
+}
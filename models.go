@@ -1,6 +1,8 @@
 package main
 
-import "time"
+import (
+	"time"
+)
 
 //================================================================================
 // VALIDASI REQUEST STRUCT
@@ -8,9 +10,172 @@ import "time"
 
 // ValidasiRequest adalah struct untuk menampung body request
 // saat Pemda melakukan validasi standar pelayanan.
+// StatusValidasi: "Disetujui", "Ditolak", atau "Perlu Revisi".
+// KomentarRevisi memetakan nama field standar (mis. "dasar_hukum") ke komentar validator.
 type ValidasiRequest struct {
 	StatusValidasi  string `json:"status_validasi" binding:"required"`
 	KeteranganValidasi string `json:"keterangan_validasi"`
+	KomentarRevisi map[string]string `json:"komentar_revisi"`
+}
+
+// TarikStandarRequest adalah body (opsional) saat OPD menarik standar yang belum disetujui.
+type TarikStandarRequest struct {
+	Alasan string `json:"alasan"`
+}
+
+// VerifikasiPersyaratanRequest adalah body saat petugas OPD memeriksa satu slot persyaratan pengajuan.
+// Status: "Terverifikasi" atau "Tidak Sesuai" (catatan wajib).
+type VerifikasiPersyaratanRequest struct {
+	Status  string `json:"status" binding:"required"`
+	Catatan string `json:"catatan"`
+}
+
+// HariLiburRequest adalah struct untuk menampung body request
+// saat Pemda menambah atau mengubah hari libur.
+type HariLiburRequest struct {
+	Tanggal string `json:"tanggal" binding:"required"` // Format YYYY-MM-DD
+	Nama    string `json:"nama" binding:"required"`
+	Jenis   string `json:"jenis"`
+}
+
+// TransisiStatusRequest adalah struct untuk menampung body request
+// saat status proses sebuah Form Pengajuan dipindahkan.
+type TransisiStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Alasan string `json:"alasan"`
+}
+
+//================================================================================
+// REGISTRASI USER REQUEST STRUCT
+//================================================================================
+
+// RegisterUserOPDRequest adalah body request saat Pemda mendaftarkan user OPD.
+// Password hanya wajib jika NIP belum memiliki akun; jika akun sudah ada
+// (mis. sudah terdaftar sebagai user Pemda), peran OPD ditambahkan ke akun tersebut.
+type RegisterUserOPDRequest struct {
+	IDOPD    uint   `json:"id_opd" binding:"required"`
+	Nama     string `json:"nama" binding:"required"`
+	NIP       string `json:"nip" binding:"required"`
+	Password  string `json:"password"`
+	Jabatan   string `json:"jabatan"`
+	KodePeran string `json:"kode_peran"` // Opsional, default "operator_opd"
+}
+
+// RegisterUserPemdaRequest adalah body request saat Pemda mendaftarkan user Pemda.
+// Aturan password sama dengan RegisterUserOPDRequest.
+type RegisterUserPemdaRequest struct {
+	Nama      string `json:"nama" binding:"required"`
+	NIP       string `json:"nip" binding:"required"`
+	Password  string `json:"password"`
+	Jabatan   string `json:"jabatan"`
+	KodePeran string `json:"kode_peran"` // Opsional, default "validator"
+}
+
+// UpdateUserRequest adalah body request PUT /api/users/:role/:id.
+// Field yang tidak dikirim tidak diubah. NIP tidak dapat diubah karena menjadi identitas akun.
+type UpdateUserRequest struct {
+	Nama    *string `json:"nama"`
+	Jabatan *string `json:"jabatan"`
+	IDOPD   *uint   `json:"id_opd"` // Hanya untuk user OPD: memindahkan user ke OPD lain
+}
+
+// UpdateProfilSayaRequest adalah body request PUT /api/me.
+// Field yang tidak dikirim tidak diubah.
+type UpdateProfilSayaRequest struct {
+	Nama    *string `json:"nama"`
+	Jabatan *string `json:"jabatan"`
+}
+
+//================================================================================
+// PERAN & IZIN REQUEST STRUCT
+//================================================================================
+
+// PeranRequest adalah body request POST /api/peran (membuat peran baru).
+type PeranRequest struct {
+	Kode      string   `json:"kode" binding:"required"`
+	Nama      string   `json:"nama" binding:"required"`
+	Jenis     string   `json:"jenis" binding:"required"` // "opd" / "pemda"
+	Deskripsi string   `json:"deskripsi"`
+	Izin      []string `json:"izin"`
+}
+
+// IzinPeranRequest adalah body request PUT /api/peran/:kode/izin (mengganti seluruh izin peran).
+type IzinPeranRequest struct {
+	Izin []string `json:"izin"`
+}
+
+// UbahPeranUserRequest adalah body request PUT /api/users/:role/:id/peran.
+type UbahPeranUserRequest struct {
+	KodePeran string `json:"kode_peran" binding:"required"`
+}
+
+// Wajib2FARequest adalah body request PUT /api/peran/:kode/wajib-2fa.
+type Wajib2FARequest struct {
+	Wajib *bool `json:"wajib" binding:"required"`
+}
+
+//================================================================================
+// 2FA REQUEST STRUCT
+//================================================================================
+
+// Kode2FARequest adalah body request konfirmasi 2FA dan pembuatan ulang kode pemulihan.
+type Kode2FARequest struct {
+	Kode string `json:"kode" binding:"required"` // Kode TOTP 6 digit atau kode pemulihan
+}
+
+// Login2FARequest adalah body request POST /api/login/2fa.
+type Login2FARequest struct {
+	Tiket string `json:"tiket" binding:"required"`
+	Kode  string `json:"kode" binding:"required"`
+}
+
+// Nonaktifkan2FARequest adalah body request POST /api/me/2fa/disable.
+type Nonaktifkan2FARequest struct {
+	Password string `json:"password" binding:"required"`
+	Kode     string `json:"kode" binding:"required"`
+}
+
+//================================================================================
+// API KEY REQUEST STRUCT
+//================================================================================
+
+// APIKeyRequest adalah body request POST /api/api-keys.
+type APIKeyRequest struct {
+	Nama            string     `json:"nama" binding:"required"`
+	Role            string     `json:"role" binding:"required"`    // Profil akun layanan: "opd" / "pemda"
+	IDUser          uint       `json:"id_user" binding:"required"` // ID UserOPD / UserPemda akun layanan
+	IDOPD           *uint      `json:"id_opd"`                     // Opsional untuk akun Pemda: batasi ke satu OPD
+	Izin            []string   `json:"izin" binding:"required"`
+	KedaluwarsaPada *time.Time `json:"kedaluwarsa_pada"`
+}
+
+// PilihPeranRequest adalah body request POST /api/login/pilih-peran dan /api/me/switch-role.
+// Tiket hanya dipakai pada langkah login; ID diperlukan jika akun memiliki lebih
+// dari satu peran dengan role yang sama.
+type PilihPeranRequest struct {
+	Tiket string `json:"tiket"`
+	Role  string `json:"role" binding:"required"`
+	ID    uint   `json:"id"`
+}
+
+// GantiPasswordRequest adalah body request POST /api/me/password.
+type GantiPasswordRequest struct {
+	PasswordLama string `json:"password_lama" binding:"required"`
+	PasswordBaru string `json:"password_baru" binding:"required"`
+}
+
+// ResetPasswordRequest adalah body request POST /api/reset-password
+// (menukar token reset dari admin dengan password baru).
+type ResetPasswordRequest struct {
+	Token        string `json:"token" binding:"required"`
+	PasswordBaru string `json:"password_baru" binding:"required"`
+}
+
+// BukaKunciLoginRequest adalah body request POST /api/login-attempts/unlock.
+// Minimal salah satu dari NIP atau AlamatIP wajib diisi.
+type BukaKunciLoginRequest struct {
+	NIP      string `json:"nip"`
+	AlamatIP string `json:"alamat_ip"`
 }
 
 //================================================================================
@@ -45,7 +210,9 @@ type JenisPelayanan struct {
 	DasarHukum string `gorm:"column:dasar_hukum;type:text" json:"dasar_hukum"`
 	Persyaratan string `gorm:"column:persyaratan;type:text" json:"persyaratan"`
 	SistemMekanismeProsedurPath string `gorm:"column:sistem_mekanisme_prosedur_path;type:varchar(255)" json:"sistem_mekanisme_prosedur_path"`
-	WaktuPelayanan string `gorm:"column:waktu_pelayanan;type:varchar(255)" json:"waktu_pelayanan"`
+	WaktuPelayanan string `gorm:"column:waktu_pelayanan;type:varchar(255)" json:"waktu_pelayanan"` // Teks tampilan, mis. "14 Hari Kerja"
+	WaktuPelayananNilai int `gorm:"column:waktu_pelayanan_nilai;not null;default:0" json:"waktu_pelayanan_nilai"` // Angka durasi SLA
+	WaktuPelayananSatuan string `gorm:"column:waktu_pelayanan_satuan;not null;default:'hari_kerja';type:varchar(20)" json:"waktu_pelayanan_satuan"` // hari_kerja / hari_kalender / jam
 	BiayaTarif string`gorm:"column:biaya_tarif;type:varchar(255)" json:"biaya_tarif"`
 	ProdukPelayanan string `gorm:"column:produk_pelayanan;type:varchar(255)" json:"produk_pelayanan"`
 	Fasilitas string `gorm:"column:fasilitas;type:text" json:"fasilitas"`
@@ -61,15 +228,57 @@ type JenisPelayanan struct {
 	StatusValidasi  string `gorm:"column:status_validasi;not null;default:'Menunggu Validasi';type:varchar(255)" json:"status_validasi"`
 	KeteranganValidasi *string `gorm:"column:keterangan_validasi;type:text" json:"keterangan_validasi"`
 	TanggalValidasi *time.Time `gorm:"column:tanggal_validasi" json:"tanggal_validasi"`
+	JumlahRevisi int `gorm:"column:jumlah_revisi;not null;default:0" json:"jumlah_revisi"` // Berapa kali diajukan ulang setelah Perlu Revisi / Ditolak
+
+	// --- VERSI ---
+	// Kolom isi standar di atas selalu berisi versi aktif (versi terakhir yang Disetujui), atau versi
+	// terbaru jika belum ada yang disetujui. Kolom status validasi mengikuti versi terbaru.
+	VersiAktif int `gorm:"column:versi_aktif;not null;default:0" json:"versi_aktif"` // Nomor versi yang berlaku; 0 = belum ada yang disetujui
+	VersiTerbaru int `gorm:"column:versi_terbaru;not null;default:0" json:"versi_terbaru"`
+
+	DiarsipkanPada *time.Time `gorm:"column:diarsipkan_pada" json:"diarsipkan_pada"` // Soft delete; baris tetap ada untuk pengajuan lama
+	DaftarPersyaratan []ItemPersyaratan `gorm:"-" json:"daftar_persyaratan,omitempty"` // Item persyaratan versi yang berlaku; juga dibaca saat membuat standar
+	DiajukanUlangPada *time.Time `gorm:"column:diajukan_ulang_pada" json:"diajukan_ulang_pada"`
 
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
 
 	// Relasi
 	OPD OPD `gorm:"foreignKey:IDOPD" json:"opd"`
 	ValidatorPemda *UserPemda  `gorm:"foreignKey:IDValidatorPemda" json:"validator_pemda"` // Pointer karena bisa NULL
+	KomentarRevisi []KomentarRevisi `gorm:"foreignKey:IDJenisPelayanan" json:"komentar_revisi,omitempty"` // Hanya komentar putaran terakhir yang dimuat di response
 	FormPengajuans []FormPengajuan `gorm:"foreignKey:IDJenisPelayanan" json:"-"`
 }
 
+//================================================================================
+// TABEL AKUN
+//================================================================================
+
+// Akun adalah identitas login satu orang (satu NIP, satu password).
+// UserOPD dan UserPemda merupakan peran yang melekat pada akun; satu akun
+// dapat memiliki lebih dari satu peran (mis. staf BAPPEDA yang juga validator).
+// Tabel: akun (15)
+type Akun struct {
+	ID                 uint      `gorm:"column:id_akun;primaryKey" json:"id_akun"`
+	NIP                string    `gorm:"column:nip;unique;not null;type:varchar(255)" json:"nip"`
+	Password           string    `gorm:"column:password;not null;type:varchar(255)" json:"-"`
+	WajibGantiPassword bool      `gorm:"column:wajib_ganti_password;not null;default:false" json:"wajib_ganti_password"` // true setelah password direset admin
+	CreatedAt          time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt          time.Time `gorm:"column:updated_at" json:"updated_at"`
+
+	TerakhirLoginPada *time.Time `gorm:"column:terakhir_login_pada" json:"terakhir_login_pada"`
+	TerakhirLoginIP   string     `gorm:"column:terakhir_login_ip;type:varchar(64)" json:"terakhir_login_ip"`
+
+	// --- SSO (OIDC) ---
+	OIDCSubject     *string    `gorm:"column:oidc_subject;uniqueIndex;type:varchar(255)" json:"-"` // Klaim "sub" dari identity provider; terisi saat login SSO pertama
+	OIDCTertautPada *time.Time `gorm:"column:oidc_tertaut_pada" json:"oidc_tertaut_pada"`
+
+	// --- 2FA (TOTP) ---
+	TOTPRahasia         string     `gorm:"column:totp_rahasia;type:varchar(64)" json:"-"` // Base32; terisi sejak enroll, berlaku setelah dikonfirmasi
+	TOTPAktif           bool       `gorm:"column:totp_aktif;not null;default:false" json:"totp_aktif"`
+	TOTPAktifPada       *time.Time `gorm:"column:totp_aktif_pada" json:"totp_aktif_pada"`
+	TOTPLangkahTerakhir int64      `gorm:"column:totp_langkah_terakhir;not null;default:0" json:"-"` // Mencegah kode yang sama dipakai ulang
+}
+
 //================================================================================
 // TABEL USER OPD & USER PEMDA
 //================================================================================
@@ -81,8 +290,11 @@ type UserOPD struct {
 	IDOPD uint  `gorm:"column:id_opd;not null" json:"id_opd"` // Foreign Key ke OPD
 	Nama string `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
 	NIP  string `gorm:"column:nip;unique;not null;type:varchar(255)" json:"nip"`
-	Password string `gorm:"column:password;not null;type:varchar(255)" json:"-"`
+	IDAkun uint `gorm:"column:id_akun;index" json:"id_akun"` // Akun login pemilik peran ini
+	KodePeran string `gorm:"column:kode_peran;not null;type:varchar(50);default:operator_opd" json:"kode_peran"` // Peran RBAC, lihat tabel peran
 	Jabatan string `gorm:"column:jabatan;type:varchar(255)" json:"jabatan"`
+	Aktif bool `gorm:"column:aktif;not null;default:true" json:"aktif"` // false: dinonaktifkan admin, tidak dapat login
+	DihapusPada *time.Time `gorm:"column:dihapus_pada" json:"dihapus_pada"` // Soft delete; baris tetap ada untuk riwayat pengajuan
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
 
 	// Relasi (sebagai Child dan Parent)
@@ -97,8 +309,11 @@ type UserPemda struct {
 	ID  uint `gorm:"column:id_user_pemda;primaryKey" json:"id_user_pemda"`
 	Nama  string `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
 	NIP   string `gorm:"column:nip;unique;not null;type:varchar(255)" json:"nip"`
-	Password string `gorm:"column:password;not null;type:varchar(255)" json:"-"`
+	IDAkun uint `gorm:"column:id_akun;index" json:"id_akun"` // Akun login pemilik peran ini
+	KodePeran string `gorm:"column:kode_peran;not null;type:varchar(50);default:validator" json:"kode_peran"` // Peran RBAC, lihat tabel peran
 	Jabatan  string `gorm:"column:jabatan;type:varchar(255)" json:"jabatan"`
+	Aktif bool `gorm:"column:aktif;not null;default:true" json:"aktif"` // false: dinonaktifkan admin, tidak dapat login
+	DihapusPada *time.Time `gorm:"column:dihapus_pada" json:"dihapus_pada"` // Soft delete; baris tetap ada untuk riwayat validasi standar
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
 
 	// Relasi (sebagai Parent)
@@ -144,6 +359,7 @@ type FormPengajuan struct {
 	IDOPD uint `gorm:"column:id_opd;not null" json:"id_opd"`
 	IDJenisPelayanan  uint `gorm:"column:id_jenis_pelayanan;not null" json:"id_jenis_pelayanan"`
 	IDUserOPD uint `gorm:"column:id_user_opd;not null" json:"id_user_opd"` // Petugas OPD yang memproses
+	IDVersiStandar *uint `gorm:"column:id_versi_standar;index" json:"id_versi_standar"` // Versi standar yang berlaku saat pengajuan dibuat
 
 	// --- DATA PEMOHON (EKSPLISIT DALAM TRANSAKSI) ---
 	NamaPemohonLengkap string `gorm:"column:nama_pemohon_lengkap;not null;type:varchar(255)" json:"nama_pemohon_lengkap"`
@@ -155,7 +371,7 @@ type FormPengajuan struct {
 	// --- DETAIL PENGAJUAN ---
 	JudulPengajuan string `gorm:"column:judul_pengajuan;not null;type:varchar(255)" json:"judul_pengajuan"`
 	DeskripsiSingkat string `gorm:"column:deskripsi_singkat;type:text" json:"deskripsi_singkat"`
-	DokumenPengajuanPath *string `gorm:"column:dokumen_pengajuan_path;type:varchar(255)" json:"dokumen_pengajuan_path"`
+	DokumenPengajuanPath *string `gorm:"column:dokumen_pengajuan_path;type:varchar(255)" json:"-"` // Path lampiran dokumen_pengajuan terbaru; diunduh lewat endpoint lampiran
 	IsAgreed bool  `gorm:"column:is_agreed;not null;default:false" json:"is_agreed"`
 
 	// --- ATRIBUT TAMBAHAN TRANSAKSI ---
@@ -164,10 +380,411 @@ type FormPengajuan struct {
 
 	// --- KOLOM STATUS & WAKTU ---
 	StatusProses string `gorm:"column:status_proses;not null;default:'Baru';type:varchar(255)" json:"status_proses"`
+	KeteranganStatus *string `gorm:"column:keterangan_status;type:text" json:"keterangan_status"` // Alasan penolakan / pengembalian
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+	DihapusPada *time.Time `gorm:"column:dihapus_pada;index" json:"-"` // Soft delete (lihat kondisiPengajuanAktif); riwayat, slot & lampiran tetap disimpan
+
+	// --- SLA (dihitung dari JenisPelayanan saat pengajuan dibuat) ---
+	TanggalJatuhTempo *time.Time `gorm:"column:tanggal_jatuh_tempo" json:"tanggal_jatuh_tempo"`
+	TanggalSelesai *time.Time `gorm:"column:tanggal_selesai" json:"tanggal_selesai"` // Diisi saat status Selesai / Ditolak
+	SisaHari *int `gorm:"-" json:"sisa_hari"` // Hari kalender tersisa (negatif jika lewat)
+	SisaHariKerja *int `gorm:"-" json:"sisa_hari_kerja"` // Hari kerja tersisa (negatif jika lewat)
+	Terlambat bool `gorm:"-" json:"terlambat"`
 
 	// Relasi
 	OPD OPD  `gorm:"foreignKey:IDOPD" json:"opd"`
 	JenisPelayanan JenisPelayanan `gorm:"foreignKey:IDJenisPelayanan" json:"jenis_pelayanan"`
 	UserOPD UserOPD `gorm:"foreignKey:IDUserOPD" json:"user_opd"`
-}
\ No newline at end of file
+	Riwayat []RiwayatPengajuan `gorm:"foreignKey:IDFormPengajuan" json:"-"`
+	Persyaratan []PersyaratanPengajuan `gorm:"foreignKey:IDFormPengajuan" json:"persyaratan,omitempty"` // Slot dokumen per item persyaratan
+	Lampiran []LampiranPengajuan `gorm:"foreignKey:IDFormPengajuan" json:"lampiran,omitempty"` // Hanya versi terbaru yang dimuat di response
+
+	// Status yang dapat dituju oleh user yang sedang login (tidak disimpan di DB)
+	StatusBerikutnya []string `gorm:"-" json:"status_berikutnya,omitempty"`
+}
+
+//================================================================================
+// TABEL RIWAYAT PENGAJUAN
+//================================================================================
+
+// RiwayatPengajuan mencatat setiap perubahan pada FormPengajuan (dibuat, diperbarui, pindah status).
+// Tabel: riwayat_pengajuan (7)
+type RiwayatPengajuan struct {
+	ID              uint `gorm:"column:id_riwayat_pengajuan;primaryKey" json:"id_riwayat_pengajuan"`
+	IDFormPengajuan uint `gorm:"column:id_form_pengajuan;not null;index" json:"id_form_pengajuan"`
+
+	// --- AKTOR (diambil dari Claims) ---
+	RoleAktor string `gorm:"column:role_aktor;not null;type:varchar(50)" json:"role_aktor"` // "opd" / "pemda"
+	IDAktor   uint   `gorm:"column:id_aktor;not null" json:"id_aktor"`                      // ID UserOPD atau UserPemda
+	NamaAktor string `gorm:"column:nama_aktor;type:varchar(255)" json:"nama_aktor"`
+
+	// --- PERUBAHAN ---
+	StatusLama *string `gorm:"column:status_lama;type:varchar(255)" json:"status_lama"` // NULL saat pengajuan baru dibuat
+	StatusBaru string  `gorm:"column:status_baru;not null;type:varchar(255)" json:"status_baru"`
+	Catatan    string  `gorm:"column:catatan;type:text" json:"catatan"`
+
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL HARI LIBUR
+//================================================================================
+
+// HariLibur merepresentasikan hari libur nasional, cuti bersama, atau libur daerah
+// yang dikecualikan dari perhitungan "Hari Kerja".
+// Tabel: hari_libur (8)
+type HariLibur struct {
+	ID        uint      `gorm:"column:id_hari_libur;primaryKey" json:"id_hari_libur"`
+	Tanggal   time.Time `gorm:"column:tanggal;unique;not null;type:date" json:"tanggal"`
+	Nama      string    `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
+	Jenis     string    `gorm:"column:jenis;not null;default:'nasional';type:varchar(50)" json:"jenis"` // nasional / cuti_bersama / daerah
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL TOKEN RESET PASSWORD
+//================================================================================
+
+// TokenResetPassword adalah token sekali pakai yang diterbitkan admin Pemda
+// untuk mereset password user OPD / Pemda. Hanya hash token yang disimpan.
+// Tabel: token_reset_password (9)
+type TokenResetPassword struct {
+	ID              uint       `gorm:"column:id_token_reset_password;primaryKey" json:"id_token_reset_password"`
+	Role            string     `gorm:"column:role;not null;type:varchar(50)" json:"role"` // "opd" / "pemda"
+	IDUser          uint       `gorm:"column:id_user;not null;index" json:"id_user"`
+	TokenHash       string     `gorm:"column:token_hash;unique;not null;type:varchar(64)" json:"-"`
+	KedaluwarsaPada time.Time  `gorm:"column:kedaluwarsa_pada;not null" json:"kedaluwarsa_pada"`
+	DipakaiPada     *time.Time `gorm:"column:dipakai_pada" json:"dipakai_pada"`
+	IDPembuat       uint       `gorm:"column:id_pembuat;not null" json:"id_pembuat"` // UserPemda yang menerbitkan
+	CreatedAt       time.Time  `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL LOG AUDIT
+//================================================================================
+
+// LogAudit mencatat aksi sensitif (keamanan akun, administrasi) untuk keperluan audit.
+// Tabel: log_audit (10)
+type LogAudit struct {
+	ID         uint      `gorm:"column:id_log_audit;primaryKey" json:"id_log_audit"`
+	Aksi       string    `gorm:"column:aksi;not null;index;type:varchar(100)" json:"aksi"` // mis. "password.reset_token"
+	RoleAktor  string    `gorm:"column:role_aktor;type:varchar(50)" json:"role_aktor"`
+	IDAktor    uint      `gorm:"column:id_aktor" json:"id_aktor"`
+	NamaAktor  string    `gorm:"column:nama_aktor;type:varchar(255)" json:"nama_aktor"`
+	Target     string    `gorm:"column:target;index;type:varchar(255)" json:"target"` // mis. "opd:12"
+	Keterangan string    `gorm:"column:keterangan;type:text" json:"keterangan"`
+	AlamatIP   string    `gorm:"column:alamat_ip;type:varchar(64)" json:"alamat_ip"`
+	CreatedAt  time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL SESI & TOKEN DICABUT
+//================================================================================
+
+// Sesi merepresentasikan satu sesi login (satu perangkat / browser).
+// Refresh token dirotasi setiap dipakai; hanya hash-nya yang disimpan.
+// Tabel: sesi (11)
+type Sesi struct {
+	ID                   uint       `gorm:"column:id_sesi;primaryKey" json:"id_sesi"`
+	IDAkun               uint       `gorm:"column:id_akun;index" json:"id_akun"`
+	Role                 string     `gorm:"column:role;not null;type:varchar(50);index:idx_sesi_user" json:"role"` // Peran aktif sesi
+	IDUser               uint       `gorm:"column:id_user;not null;index:idx_sesi_user" json:"id_user"`
+	RefreshTokenHash     string     `gorm:"column:refresh_token_hash;not null;type:varchar(64)" json:"-"`
+	RefreshTokenHashLama string     `gorm:"column:refresh_token_hash_lama;type:text" json:"-"` // Hash refresh token yang sudah dirotasi (dipisah koma), untuk deteksi pemakaian ulang
+	JTIAkses             string     `gorm:"column:jti_akses;type:varchar(64)" json:"-"` // jti access token terakhir
+	AksesKedaluwarsaPada time.Time  `gorm:"column:akses_kedaluwarsa_pada" json:"-"`
+	UserAgent            string     `gorm:"column:user_agent;type:text" json:"user_agent"`
+	AlamatIP             string     `gorm:"column:alamat_ip;type:varchar(64)" json:"alamat_ip"`
+	KedaluwarsaPada      time.Time  `gorm:"column:kedaluwarsa_pada;not null" json:"kedaluwarsa_pada"`
+	TerakhirDipakai      time.Time  `gorm:"column:terakhir_dipakai" json:"terakhir_dipakai"`
+	DicabutPada          *time.Time `gorm:"column:dicabut_pada" json:"dicabut_pada"`
+	AlasanCabut          string     `gorm:"column:alasan_cabut;type:varchar(255)" json:"alasan_cabut,omitempty"`
+	CreatedAt            time.Time  `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TokenDicabut adalah daftar jti access token yang sudah dicabut sebelum kedaluwarsa.
+// Baris dapat dihapus setelah KedaluwarsaPada lewat.
+// Tabel: token_dicabut (12)
+type TokenDicabut struct {
+	JTI             string    `gorm:"column:jti;primaryKey;type:varchar(64)" json:"jti"`
+	KedaluwarsaPada time.Time `gorm:"column:kedaluwarsa_pada;not null;index" json:"kedaluwarsa_pada"`
+	CreatedAt       time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL PERCOBAAN LOGIN & PENGUNCIAN LOGIN
+//================================================================================
+
+// LoginAttempt mencatat setiap percobaan login (berhasil maupun gagal).
+// Tabel: login_attempt (13)
+type LoginAttempt struct {
+	ID        uint      `gorm:"column:id_login_attempt;primaryKey" json:"id_login_attempt"`
+	NIP       string    `gorm:"column:nip;index;type:varchar(50)" json:"nip"`
+	AlamatIP  string    `gorm:"column:alamat_ip;index;type:varchar(64)" json:"alamat_ip"`
+	UserAgent string    `gorm:"column:user_agent;type:text" json:"user_agent"`
+	Berhasil  bool      `gorm:"column:berhasil;not null;default:false" json:"berhasil"`
+	Alasan    string    `gorm:"column:alasan;type:varchar(100)" json:"alasan"` // mis. "password_salah", "terkunci"
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;index" json:"created_at"`
+}
+
+// PenguncianLogin adalah penghitung kegagalan login bersama (LOGIN_STORE=db),
+// dipakai agar penguncian berlaku di semua replika aplikasi.
+// Tabel: penguncian_login (14)
+type PenguncianLogin struct {
+	Kunci          string     `gorm:"column:kunci;primaryKey;type:varchar(150)" json:"kunci"` // "nip:<nip>" / "ip:<alamat>"
+	JumlahGagal    int        `gorm:"column:jumlah_gagal;not null;default:0" json:"jumlah_gagal"`
+	TerakhirGagal  time.Time  `gorm:"column:terakhir_gagal" json:"terakhir_gagal"`
+	TerkunciSampai *time.Time `gorm:"column:terkunci_sampai" json:"terkunci_sampai"`
+}
+
+//================================================================================
+// TABEL PERAN & IZIN PERAN (RBAC)
+//================================================================================
+
+// Peran adalah peran RBAC (mis. superadmin, validator, operator_opd).
+// Jenis menentukan profil yang boleh memakai peran ini: "opd" untuk UserOPD, "pemda" untuk UserPemda.
+// Tabel: peran (16)
+type Peran struct {
+	Kode      string    `gorm:"column:kode;primaryKey;type:varchar(50)" json:"kode"`
+	Nama      string    `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
+	Jenis     string    `gorm:"column:jenis;not null;type:varchar(50)" json:"jenis"`
+	Deskripsi string    `gorm:"column:deskripsi;type:text" json:"deskripsi"`
+	Wajib2FA  bool      `gorm:"column:wajib_2fa;not null;default:false" json:"wajib_2fa"` // User dengan peran ini wajib mengaktifkan 2FA
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+
+	// Relasi
+	Izin       []IzinPeran `gorm:"foreignKey:KodePeran;references:Kode" json:"-"`
+	DaftarIzin []string    `gorm:"-" json:"izin"` // Diisi dari Izin untuk response
+}
+
+// IzinPeran memetakan peran ke izin aksi (mis. "standar.validate"). Izin "*" berarti semua izin.
+// Tabel: izin_peran (17)
+type IzinPeran struct {
+	KodePeran string `gorm:"column:kode_peran;primaryKey;type:varchar(50)" json:"kode_peran"`
+	Izin      string `gorm:"column:izin;primaryKey;type:varchar(100)" json:"izin"`
+}
+
+// MigrasiIzinPeran mencatat tambahan izin peran bawaan yang sudah diterapkan ke database,
+// agar setiap tambahan hanya diberikan sekali (lihat tambahanIzinBawaan di rbac.go).
+// Tabel: migrasi_izin_peran (27)
+type MigrasiIzinPeran struct {
+	Kode      string    `gorm:"column:kode;primaryKey;type:varchar(100)" json:"kode"`
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL KODE PEMULIHAN 2FA
+//================================================================================
+
+// KodePemulihan adalah kode sekali pakai pengganti kode TOTP jika perangkat authenticator hilang.
+// Hanya hash kode yang disimpan.
+// Tabel: kode_pemulihan (18)
+type KodePemulihan struct {
+	ID          uint       `gorm:"column:id_kode_pemulihan;primaryKey" json:"id_kode_pemulihan"`
+	IDAkun      uint       `gorm:"column:id_akun;not null;index" json:"id_akun"`
+	KodeHash    string     `gorm:"column:kode_hash;not null;type:varchar(64)" json:"-"`
+	DipakaiPada *time.Time `gorm:"column:dipakai_pada" json:"dipakai_pada"`
+	CreatedAt   time.Time  `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL API KEY & IZIN API KEY
+//================================================================================
+
+// APIKey adalah kunci akses jangka panjang untuk klien mesin (integrasi, aplikasi mobile).
+// Setiap kunci bertindak atas nama satu profil akun layanan (Role + IDUser), dibatasi izin
+// miliknya sendiri dan, untuk profil Pemda, opsional dibatasi ke satu OPD.
+// Hanya hash kunci yang disimpan.
+// Tabel: api_key (19)
+type APIKey struct {
+	ID                  uint       `gorm:"column:id_api_key;primaryKey" json:"id_api_key"`
+	Nama                string     `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
+	Prefix              string     `gorm:"column:prefix;not null;type:varchar(20)" json:"prefix"` // Awal kunci untuk identifikasi, mis. "bpk_AbCd1234"
+	KunciHash           string     `gorm:"column:kunci_hash;not null;uniqueIndex;type:varchar(64)" json:"-"`
+	Role                string     `gorm:"column:role;not null;type:varchar(10)" json:"role"`
+	IDUser              uint       `gorm:"column:id_user;not null" json:"id_user"`
+	IDOPD               *uint      `gorm:"column:id_opd" json:"id_opd"`
+	IDAkunPembuat       uint       `gorm:"column:id_akun_pembuat;not null" json:"id_akun_pembuat"`
+	KedaluwarsaPada     *time.Time `gorm:"column:kedaluwarsa_pada" json:"kedaluwarsa_pada"`
+	TerakhirDipakaiPada *time.Time `gorm:"column:terakhir_dipakai_pada" json:"terakhir_dipakai_pada"`
+	TerakhirDipakaiIP   string     `gorm:"column:terakhir_dipakai_ip;type:varchar(45)" json:"terakhir_dipakai_ip"`
+	DicabutPada         *time.Time `gorm:"column:dicabut_pada" json:"dicabut_pada"`
+	CreatedAt           time.Time  `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+
+	// Relasi
+	Izin       []IzinAPIKey `gorm:"foreignKey:IDAPIKey" json:"-"`
+	DaftarIzin []string     `gorm:"-" json:"izin"` // Diisi dari Izin untuk response
+}
+
+// IzinAPIKey memetakan API key ke izin aksi yang boleh dipakai.
+// Tabel: izin_api_key (20)
+type IzinAPIKey struct {
+	IDAPIKey uint   `gorm:"column:id_api_key;primaryKey" json:"id_api_key"`
+	Izin     string `gorm:"column:izin;primaryKey;type:varchar(100)" json:"izin"`
+}
+
+//================================================================================
+// TABEL KOMENTAR REVISI STANDAR
+//================================================================================
+
+// KomentarRevisi adalah komentar validator untuk satu field standar pelayanan
+// saat standar dikembalikan dengan status "Perlu Revisi" atau "Ditolak".
+// Putaran = JumlahRevisi standar saat komentar dibuat, sehingga riwayat antar pengajuan ulang tetap terbaca.
+// Tabel: komentar_revisi (21)
+type KomentarRevisi struct {
+	ID               uint      `gorm:"column:id_komentar_revisi;primaryKey" json:"id_komentar_revisi"`
+	IDJenisPelayanan uint      `gorm:"column:id_jenis_pelayanan;not null;index" json:"id_jenis_pelayanan"`
+	IDVersiStandar   *uint     `gorm:"column:id_versi_standar;index" json:"id_versi_standar"` // Versi yang dikomentari
+	Putaran          int       `gorm:"column:putaran;not null;default:0" json:"putaran"`
+	Field            string    `gorm:"column:field;not null;type:varchar(100)" json:"field"` // Nama field JSON, mis. "dasar_hukum"
+	Komentar         string    `gorm:"column:komentar;not null;type:text" json:"komentar"`
+	IDValidatorPemda uint      `gorm:"column:id_validator_pemda;not null" json:"id_validator_pemda"`
+	CreatedAt        time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL NOTIFIKASI
+//================================================================================
+
+// Notifikasi adalah pemberitahuan dalam aplikasi untuk satu profil user (Role + IDUser).
+// Tabel: notifikasi (22)
+type Notifikasi struct {
+	ID         uint       `gorm:"column:id_notifikasi;primaryKey" json:"id_notifikasi"`
+	Role       string     `gorm:"column:role;not null;type:varchar(10);index:idx_notifikasi_penerima" json:"role"`
+	IDUser     uint       `gorm:"column:id_user;not null;index:idx_notifikasi_penerima" json:"id_user"`
+	Jenis      string     `gorm:"column:jenis;not null;type:varchar(100)" json:"jenis"` // mis. "standar.diajukan_ulang"
+	Judul      string     `gorm:"column:judul;not null;type:varchar(255)" json:"judul"`
+	Pesan      string     `gorm:"column:pesan;type:text" json:"pesan"`
+	Target     string     `gorm:"column:target;type:varchar(255)" json:"target"` // Format sama dengan LogAudit.Target, mis. "standar:12"
+	DibacaPada *time.Time `gorm:"column:dibaca_pada" json:"dibaca_pada"`
+	CreatedAt  time.Time  `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+//================================================================================
+// TABEL VERSI STANDAR PELAYANAN
+//================================================================================
+
+// KontenStandar adalah isi sebuah standar pelayanan yang diberi versi.
+// Field dan tag JSON sama dengan JenisPelayanan agar body request yang sama dapat dipakai.
+type KontenStandar struct {
+	NamaStandar                 string `gorm:"column:nama_standar;not null;type:varchar(255)" json:"nama_standar"`
+	DasarHukum                  string `gorm:"column:dasar_hukum;type:text" json:"dasar_hukum"`
+	Persyaratan                 string `gorm:"column:persyaratan;type:text" json:"persyaratan"`
+	SistemMekanismeProsedurPath string `gorm:"column:sistem_mekanisme_prosedur_path;type:varchar(255)" json:"sistem_mekanisme_prosedur_path"`
+	WaktuPelayanan              string `gorm:"column:waktu_pelayanan;type:varchar(255)" json:"waktu_pelayanan"`
+	WaktuPelayananNilai         int    `gorm:"column:waktu_pelayanan_nilai;not null;default:0" json:"waktu_pelayanan_nilai"`
+	WaktuPelayananSatuan        string `gorm:"column:waktu_pelayanan_satuan;not null;default:'hari_kerja';type:varchar(20)" json:"waktu_pelayanan_satuan"`
+	BiayaTarif                  string `gorm:"column:biaya_tarif;type:varchar(255)" json:"biaya_tarif"`
+	ProdukPelayanan             string `gorm:"column:produk_pelayanan;type:varchar(255)" json:"produk_pelayanan"`
+	Fasilitas                   string `gorm:"column:fasilitas;type:text" json:"fasilitas"`
+	KompetensiPelaksana         string `gorm:"column:kompetensi_pelaksana;type:text" json:"kompetensi_pelaksana"`
+	PengawasanInternal          string `gorm:"column:pengawasan_internal;type:text" json:"pengawasan_internal"`
+	JumlahPelaksana             int    `gorm:"column:jumlah_pelaksana" json:"jumlah_pelaksana"`
+	JaminanPelayanan            string `gorm:"column:jaminan_pelayanan;type:text" json:"jaminan_pelayanan"`
+	SaranDanMasukan             string `gorm:"column:saran_dan_masukan;type:text" json:"saran_dan_masukan"`
+	JaminanKeamanan             string `gorm:"column:jaminan_keamanan;type:text" json:"jaminan_keamanan"`
+	EvaluasiKinerja             string `gorm:"column:evaluasi_kinerja;type:text" json:"evaluasi_kinerja"`
+}
+
+// VersiStandar adalah salinan isi standar pelayanan pada satu versi. Versi tidak diubah
+// setelah diputuskan validator; perubahan berikutnya selalu membuat versi baru.
+// Tabel: versi_standar (23)
+type VersiStandar struct {
+	ID               uint `gorm:"column:id_versi_standar;primaryKey" json:"id_versi_standar"`
+	IDJenisPelayanan uint `gorm:"column:id_jenis_pelayanan;not null;uniqueIndex:idx_versi_standar_nomor" json:"id_jenis_pelayanan"`
+	Nomor            int  `gorm:"column:nomor;not null;uniqueIndex:idx_versi_standar_nomor" json:"nomor"`
+
+	KontenStandar `gorm:"embedded"`
+
+	StatusValidasi     string     `gorm:"column:status_validasi;not null;default:'Menunggu Validasi';type:varchar(255)" json:"status_validasi"`
+	KeteranganValidasi *string    `gorm:"column:keterangan_validasi;type:text" json:"keterangan_validasi"`
+	IDValidatorPemda   *uint      `gorm:"column:id_validator_pemda" json:"id_validator_pemda"`
+	TanggalValidasi    *time.Time `gorm:"column:tanggal_validasi" json:"tanggal_validasi"`
+	BerlakuSejak       *time.Time `gorm:"column:berlaku_sejak" json:"berlaku_sejak"`     // Saat versi disetujui dan menjadi versi aktif
+	DigantikanPada     *time.Time `gorm:"column:digantikan_pada" json:"digantikan_pada"` // Saat versi berikutnya disetujui
+	IDUserOPDPembuat   *uint      `gorm:"column:id_user_opd_pembuat" json:"id_user_opd_pembuat"` // NULL untuk versi hasil migrasi
+	CreatedAt          time.Time  `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+
+	// Relasi
+	ValidatorPemda    *UserPemda        `gorm:"foreignKey:IDValidatorPemda" json:"validator_pemda,omitempty"`
+	DaftarPersyaratan []ItemPersyaratan `gorm:"foreignKey:IDVersiStandar" json:"daftar_persyaratan"`
+}
+
+//================================================================================
+// TABEL ITEM PERSYARATAN & PERSYARATAN PENGAJUAN
+//================================================================================
+
+// ItemPersyaratan adalah satu butir persyaratan pada sebuah versi standar pelayanan.
+// Tabel: item_persyaratan (24)
+type ItemPersyaratan struct {
+	ID             uint   `gorm:"column:id_item_persyaratan;primaryKey" json:"id_item_persyaratan"`
+	IDVersiStandar uint   `gorm:"column:id_versi_standar;not null;index" json:"id_versi_standar"`
+	Urutan         int    `gorm:"column:urutan;not null;default:0" json:"urutan"`
+	Nama           string `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
+	Wajib          bool   `gorm:"column:wajib;not null" json:"wajib"`
+	TipeFile       string `gorm:"column:tipe_file;type:varchar(255)" json:"tipe_file"`     // Ekstensi dipisah koma, mis. "pdf,jpg"; kosong = semua tipe
+	UkuranMaksKB   int    `gorm:"column:ukuran_maks_kb;not null;default:0" json:"ukuran_maks_kb"` // 0 = mengikuti batas upload umum
+	Keterangan     string `gorm:"column:keterangan;type:text" json:"keterangan"`
+}
+
+// PersyaratanPengajuan adalah slot dokumen untuk satu item persyaratan pada sebuah pengajuan,
+// beserta hasil pemeriksaan petugas OPD. Data item disalin agar slot tetap utuh walau item berubah.
+// Tabel: persyaratan_pengajuan (25)
+type PersyaratanPengajuan struct {
+	ID                uint  `gorm:"column:id_persyaratan_pengajuan;primaryKey" json:"id_persyaratan_pengajuan"`
+	IDFormPengajuan   uint  `gorm:"column:id_form_pengajuan;not null;index" json:"id_form_pengajuan"`
+	IDItemPersyaratan *uint `gorm:"column:id_item_persyaratan" json:"id_item_persyaratan"`
+
+	// --- SALINAN ITEM PERSYARATAN ---
+	Urutan       int    `gorm:"column:urutan;not null;default:0" json:"urutan"`
+	Nama         string `gorm:"column:nama;not null;type:varchar(255)" json:"nama"`
+	Wajib        bool   `gorm:"column:wajib;not null" json:"wajib"`
+	TipeFile     string `gorm:"column:tipe_file;type:varchar(255)" json:"tipe_file"`
+	UkuranMaksKB int    `gorm:"column:ukuran_maks_kb;not null;default:0" json:"ukuran_maks_kb"`
+
+	// --- DOKUMEN ---
+	DokumenPath         *string    `gorm:"column:dokumen_path;type:varchar(255)" json:"-"`           // Diunduh lewat endpoint lampiran (IDLampiranPengajuan)
+	IDLampiranPengajuan *uint      `gorm:"column:id_lampiran_pengajuan" json:"id_lampiran_pengajuan"` // Lampiran berlaku untuk slot ini
+	NamaFile            string     `gorm:"column:nama_file;type:varchar(255)" json:"nama_file"`
+	UkuranByte          int64      `gorm:"column:ukuran_byte;not null;default:0" json:"ukuran_byte"`
+	DiunggahPada        *time.Time `gorm:"column:diunggah_pada" json:"diunggah_pada"`
+
+	// --- VERIFIKASI ---
+	StatusVerifikasi  string     `gorm:"column:status_verifikasi;not null;default:'Belum Diperiksa';type:varchar(50)" json:"status_verifikasi"`
+	CatatanVerifikasi string     `gorm:"column:catatan_verifikasi;type:text" json:"catatan_verifikasi"`
+	IDVerifikator     *uint      `gorm:"column:id_verifikator" json:"id_verifikator"` // UserOPD yang memeriksa
+	DiverifikasiPada  *time.Time `gorm:"column:diverifikasi_pada" json:"diverifikasi_pada"`
+}
+
+//================================================================================
+// TABEL LAMPIRAN PENGAJUAN
+//================================================================================
+
+// LampiranPengajuan adalah satu file yang dilampirkan pada pengajuan. File tidak pernah ditimpa:
+// unggahan pengganti disimpan sebagai versi baru dan versi lama tetap ada (DigantikanPada terisi).
+// Tabel: lampiran_pengajuan (26)
+type LampiranPengajuan struct {
+	ID                     uint  `gorm:"column:id_lampiran_pengajuan;primaryKey" json:"id_lampiran_pengajuan"`
+	IDFormPengajuan        uint  `gorm:"column:id_form_pengajuan;not null;index" json:"id_form_pengajuan"`
+	IDPersyaratanPengajuan *uint `gorm:"column:id_persyaratan_pengajuan;index" json:"id_persyaratan_pengajuan"` // Terisi untuk jenis "persyaratan"
+	Jenis                  string `gorm:"column:jenis;not null;type:varchar(50)" json:"jenis"` // dokumen_pengajuan / persyaratan / pendukung / lainnya
+
+	// --- VERSI ---
+	Versi                int        `gorm:"column:versi;not null;default:1" json:"versi"`
+	IDLampiranSebelumnya *uint      `gorm:"column:id_lampiran_sebelumnya" json:"id_lampiran_sebelumnya"` // Versi yang digantikan oleh lampiran ini
+	DigantikanPada       *time.Time `gorm:"column:digantikan_pada" json:"digantikan_pada"`
+	DihapusPada          *time.Time `gorm:"column:dihapus_pada" json:"dihapus_pada"` // Dihapus dari pengajuan; file tetap disimpan
+
+	// --- FILE ---
+	NamaFile   string `gorm:"column:nama_file;not null;type:varchar(255)" json:"nama_file"` // Nama file asli dari pengunggah
+	Path       string `gorm:"column:path;not null;type:varchar(255)" json:"-"`              // Diunduh lewat endpoint lampiran
+	UkuranByte int64  `gorm:"column:ukuran_byte;not null;default:0" json:"ukuran_byte"`
+	MIME       string `gorm:"column:mime;type:varchar(255)" json:"mime"` // Dideteksi dari isi file
+	SHA256     string `gorm:"column:sha256;type:char(64)" json:"sha256"`
+
+	// --- PENGUNGGAH (kosong untuk lampiran hasil migrasi) ---
+	RoleUploader string     `gorm:"column:role_uploader;type:varchar(50)" json:"role_uploader"`
+	IDUploader   *uint      `gorm:"column:id_uploader" json:"id_uploader"`
+	NamaUploader string     `gorm:"column:nama_uploader;type:varchar(255)" json:"nama_uploader"`
+	DiunggahPada time.Time  `gorm:"column:diunggah_pada;not null" json:"diunggah_pada"`
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= NOTIFIKASI DALAM APLIKASI =========
+//
+// Notifikasi ditujukan ke profil (Role + IDUser), bukan ke akun, agar hanya muncul
+// ketika user login dengan peran yang relevan. Penerima ditentukan dari izin RBAC,
+// mis. semua validator (izin standar.validate) saat standar diajukan ulang.
+
+// profilDenganIzin mengembalikan ID profil aktif berjenis role yang perannya memiliki izin.
+// idOPD tidak nol membatasi penerima OPD pada satu OPD.
+func profilDenganIzin(tx *gorm.DB, role, izin string, idOPD uint) ([]uint, error) {
+	var daftarPeran []Peran
+	if err := tx.Where("jenis = ?", role).Find(&daftarPeran).Error; err != nil {
+		return nil, err
+	}
+	var kodePeran []string
+	for _, p := range daftarPeran {
+		ada, err := izinCache.punya(p.Kode, izin)
+		if err != nil {
+			return nil, err
+		}
+		if ada {
+			kodePeran = append(kodePeran, p.Kode)
+		}
+	}
+	if len(kodePeran) == 0 {
+		return nil, nil
+	}
+
+	var model interface{} = &UserOPD{}
+	if role == "pemda" {
+		model = &UserPemda{}
+	}
+	query := tx.Model(model).Where("kode_peran IN ?", kodePeran).Where(kondisiProfilAktif)
+	if role == "opd" && idOPD != 0 {
+		query = query.Where("id_opd = ?", idOPD)
+	}
+	var ids []uint
+	err := query.Pluck(kolomIDProfil(role), &ids).Error
+	return ids, err
+}
+
+// kirimNotifikasi membuat notifikasi yang sama untuk setiap profil penerima.
+func kirimNotifikasi(tx *gorm.DB, role string, ids []uint, jenis, judul, pesan, target string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	daftar := make([]Notifikasi, 0, len(ids))
+	for _, id := range ids {
+		daftar = append(daftar, Notifikasi{Role: role, IDUser: id, Jenis: jenis, Judul: judul, Pesan: pesan, Target: target})
+	}
+	return tx.Create(&daftar).Error
+}
+
+// beritahuPemegangIzin mengirim notifikasi ke seluruh profil yang memiliki izin tertentu.
+func beritahuPemegangIzin(tx *gorm.DB, role, izin string, idOPD uint, jenis, judul, pesan, target string) error {
+	ids, err := profilDenganIzin(tx, role, izin, idOPD)
+	if err != nil {
+		return err
+	}
+	return kirimNotifikasi(tx, role, ids, jenis, judul, pesan, target)
+}
+
+// notifikasiMilik membatasi query pada notifikasi profil yang sedang login.
+func notifikasiMilik(c *gin.Context) *gorm.DB {
+	claims := ambilClaims(c)
+	return DB.Model(&Notifikasi{}).Where("role = ? AND id_user = ?", claims.Role, claims.ID)
+}
+
+// kolomSortNotifikasi: nilai ?sort= yang diizinkan untuk list notifikasi
+var kolomSortNotifikasi = map[string]string{
+	"created_at": "created_at",
+}
+
+// GetNotifikasiSaya: GET /api/me/notifikasi (?belum_dibaca=true&jenis=) + paginasi.
+// meta.belum_dibaca berisi jumlah notifikasi yang belum dibaca (untuk badge).
+func GetNotifikasiSaya(c *gin.Context) {
+	var notifikasi []Notifikasi
+
+	query := notifikasiMilik(c)
+	if c.Query("belum_dibaca") == "true" {
+		query = query.Where("dibaca_pada IS NULL")
+	}
+	if jenis := c.Query("jenis"); jenis != "" {
+		query = query.Where("jenis = ?", jenis)
+	}
+	p, err := bindPaginasi(c, kolomSortNotifikasi, "created_at", "DESC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	meta, err := ambilHalaman(query, p, "id_notifikasi", nil, &notifikasi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil notifikasi"})
+		return
+	}
+	var belumDibaca int64
+	notifikasiMilik(c).Where("dibaca_pada IS NULL").Count(&belumDibaca)
+
+	c.JSON(http.StatusOK, gin.H{"data": notifikasi, "meta": meta, "belum_dibaca": belumDibaca})
+}
+
+// BacaNotifikasi: POST /api/me/notifikasi/:id/baca
+func BacaNotifikasi(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID notifikasi tidak valid"})
+		return
+	}
+	var notif Notifikasi
+	if err := notifikasiMilik(c).Where("id_notifikasi = ?", id).First(&notif).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notifikasi tidak ditemukan"})
+		return
+	}
+	if notif.DibacaPada == nil {
+		now := time.Now()
+		notif.DibacaPada = &now
+		if err := DB.Model(&notif).Update("dibaca_pada", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui notifikasi"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, notif)
+}
+
+// BacaSemuaNotifikasi: POST /api/me/notifikasi/baca-semua
+func BacaSemuaNotifikasi(c *gin.Context) {
+	hasil := notifikasiMilik(c).Where("dibaca_pada IS NULL").Update("dibaca_pada", time.Now())
+	if hasil.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui notifikasi"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "jumlah": hasil.RowsAffected})
+}
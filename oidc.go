@@ -0,0 +1,560 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// ========= LOGIN SSO (OPENID CONNECT) =========
+//
+// Login SSO opsional lewat identity provider (IdP) pusat dengan alur authorization code + PKCE.
+// Login NIP/password (POST /api/login) tetap berjalan sebagai cadangan.
+// Klaim NIP dari IdP dipetakan ke akun yang sudah terdaftar; saat login SSO pertama akun
+// ditautkan ke "sub" IdP (just-in-time linking). User baru tetap didaftarkan admin karena
+// OPD dan peran tidak diketahui dari IdP.
+//
+// Konfigurasi lewat environment (fitur aktif jika OIDC_ISSUER diisi):
+//   OIDC_ISSUER          URL issuer, mis. https://sso.provinsi.go.id/realms/asn
+//   OIDC_CLIENT_ID       Client ID aplikasi di IdP
+//   OIDC_CLIENT_SECRET   Opsional; kosong untuk public client (PKCE saja)
+//   OIDC_REDIRECT_URL    URL callback, mis. http://localhost:8080/api/oidc/callback
+//   OIDC_SCOPES          Default "openid profile"
+//   OIDC_KLAIM_NIP       Nama klaim NIP di ID token, default "nip"
+//   OIDC_FRONTEND_URL    URL frontend tujuan setelah login, default http://localhost:3000
+//   OIDC_PERCAYA_MFA     true: 2FA lokal dilewati karena IdP sudah menerapkan MFA
+// Untuk pengujian lokal tersedia mock IdP di folder test-oidc.
+
+const (
+	cookieStateOIDC = "oidc_state"
+	tujuanOIDC      = "oidc"
+)
+
+// konfigurasiOIDC adalah konfigurasi SSO dari environment.
+type konfigurasiOIDC struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       string
+	KlaimNIP     string
+	FrontendURL  string
+	PercayaMFA   bool
+}
+
+// konfigurasiOIDCDariEnv membaca konfigurasi SSO. ok bernilai false jika SSO tidak diaktifkan.
+func konfigurasiOIDCDariEnv() (cfg konfigurasiOIDC, ok bool) {
+	cfg = konfigurasiOIDC{
+		Issuer:       strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       os.Getenv("OIDC_SCOPES"),
+		KlaimNIP:     os.Getenv("OIDC_KLAIM_NIP"),
+		FrontendURL:  strings.TrimSuffix(os.Getenv("OIDC_FRONTEND_URL"), "/"),
+		PercayaMFA:   envBool("OIDC_PERCAYA_MFA", false),
+	}
+	if cfg.Scopes == "" {
+		cfg.Scopes = "openid profile"
+	}
+	if cfg.KlaimNIP == "" {
+		cfg.KlaimNIP = "nip"
+	}
+	if cfg.FrontendURL == "" {
+		cfg.FrontendURL = "http://localhost:3000"
+	}
+	return cfg, cfg.Issuer != "" && cfg.ClientID != "" && cfg.RedirectURL != ""
+}
+
+// ----- Discovery & JWKS identity provider -----
+
+// metadataOIDC adalah bagian dokumen /.well-known/openid-configuration yang dipakai.
+type metadataOIDC struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// penyediaOIDC menyimpan cache metadata dan kunci publik IdP.
+type penyediaOIDC struct {
+	mu          sync.Mutex
+	issuer      string
+	meta        *metadataOIDC
+	kunci       map[string]interface{}
+	kunciDimuat time.Time
+}
+
+var idp = &penyediaOIDC{}
+
+var klienHTTPOIDC = &http.Client{Timeout: 10 * time.Second}
+
+// ambilJSON melakukan GET dan men-decode respons JSON.
+func ambilJSON(alamat string, hasil interface{}) error {
+	resp, err := klienHTTPOIDC.Get(alamat)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", alamat, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(hasil)
+}
+
+// metadata mengambil (dan meng-cache) dokumen discovery IdP.
+func (p *penyediaOIDC) metadata(cfg konfigurasiOIDC) (*metadataOIDC, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil && p.issuer == cfg.Issuer {
+		return p.meta, nil
+	}
+	var meta metadataOIDC
+	if err := ambilJSON(cfg.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("issuer discovery (%s) tidak sama dengan OIDC_ISSUER", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("dokumen discovery IdP tidak lengkap")
+	}
+	p.issuer, p.meta, p.kunci = cfg.Issuer, &meta, nil
+	return p.meta, nil
+}
+
+// kunciVerifikasi mengembalikan kunci publik IdP untuk kid. JWKS dimuat ulang jika kid
+// belum dikenal (IdP merotasi kunci), paling sering sekali per menit.
+func (p *penyediaOIDC) kunciVerifikasi(meta *metadataOIDC, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ada := p.kunci[kid]; ada {
+		return k, nil
+	}
+	if time.Since(p.kunciDimuat) < time.Minute && p.kunci != nil {
+		return nil, fmt.Errorf("kid tidak dikenal: %s", kid)
+	}
+
+	var jwks struct {
+		Keys []jwkIdP `json:"keys"`
+	}
+	if err := ambilJSON(meta.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	p.kunci = map[string]interface{}{}
+	p.kunciDimuat = time.Now()
+	for _, k := range jwks.Keys {
+		if publik, err := k.kunciPublik(); err == nil {
+			p.kunci[k.KID] = publik
+		}
+	}
+	if k, ada := p.kunci[kid]; ada {
+		return k, nil
+	}
+	return nil, fmt.Errorf("kid tidak dikenal: %s", kid)
+}
+
+// jwkIdP adalah satu kunci dari JWKS IdP (RSA, EC P-256, atau Ed25519).
+type jwkIdP struct {
+	Kty string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func bigDariBase64(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwkIdP) kunciPublik() (interface{}, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, errors.New("kunci bukan untuk tanda tangan")
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := bigDariBase64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := bigDariBase64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("kurva %s tidak didukung", k.Crv)
+		}
+		x, err := bigDariBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := bigDariBase64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("kurva %s tidak didukung", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("kunci Ed25519 tidak valid")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("jenis kunci %s tidak didukung", k.Kty)
+}
+
+// ----- State login (cookie bertanda tangan) -----
+
+// klaimStateOIDC disimpan di cookie selama pengguna berada di halaman IdP.
+type klaimStateOIDC struct {
+	Tujuan   string `json:"tujuan"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code_verifier
+	Role     string `json:"role,omitempty"`
+	IDPeran  uint   `json:"id_peran,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// tantanganPKCE menghitung code_challenge S256 dari code_verifier.
+func tantanganPKCE(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OIDCStatus: GET /api/oidc/status, dipakai frontend untuk menampilkan tombol login SSO.
+func OIDCStatus(c *gin.Context) {
+	_, aktif := konfigurasiOIDCDariEnv()
+	c.JSON(http.StatusOK, gin.H{"aktif": aktif})
+}
+
+// OIDCLogin: GET /api/oidc/login (?role=&id_peran= opsional)
+// Mengarahkan browser ke halaman login IdP.
+func OIDCLogin(c *gin.Context) {
+	cfg, aktif := konfigurasiOIDCDariEnv()
+	if !aktif {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Login SSO tidak diaktifkan"})
+		return
+	}
+	meta, err := idp.metadata(cfg)
+	if err != nil {
+		log.Println("[OIDC] Gagal memuat discovery IdP:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider tidak dapat dihubungi"})
+		return
+	}
+
+	var acak [4]string
+	for i := range acak {
+		if acak[i], err = tokenAcak(32); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai login SSO"})
+			return
+		}
+	}
+	var idPeran uint
+	fmt.Sscan(c.Query("id_peran"), &idPeran)
+	now := time.Now()
+	ttl := 10 * time.Minute
+	klaim := klaimStateOIDC{
+		Tujuan:   tujuanOIDC,
+		State:    acak[0],
+		Nonce:    acak[1],
+		Verifier: acak[2],
+		Role:     c.Query("role"),
+		IDPeran:  idPeran,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        acak[3],
+			Audience:  jwt.ClaimStrings{audStateOIDC},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	stateCookie, err := tandatanganiJWT(klaim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai login SSO"})
+		return
+	}
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie(cookieStateOIDC, stateCookie, int(ttl.Seconds()), "/api/oidc", domain, isSecure, true)
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", cfg.RedirectURL)
+	q.Set("scope", cfg.Scopes)
+	q.Set("state", klaim.State)
+	q.Set("nonce", klaim.Nonce)
+	q.Set("code_challenge", tantanganPKCE(klaim.Verifier))
+	q.Set("code_challenge_method", "S256")
+	pemisah := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		pemisah = "&"
+	}
+	c.Redirect(http.StatusFound, meta.AuthorizationEndpoint+pemisah+q.Encode())
+}
+
+// ----- Callback -----
+
+// tukarKodeOIDC menukar authorization code dengan ID token di token endpoint IdP.
+func tukarKodeOIDC(cfg konfigurasiOIDC, meta *metadataOIDC, kode, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", kode)
+	form.Set("redirect_uri", cfg.RedirectURL)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	resp, err := klienHTTPOIDC.PostForm(meta.TokenEndpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var hasil struct {
+		IDToken   string `json:"id_token"`
+		Error     string `json:"error"`
+		Deskripsi string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&hasil); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || hasil.IDToken == "" {
+		return "", fmt.Errorf("token endpoint: status %d %s %s", resp.StatusCode, hasil.Error, hasil.Deskripsi)
+	}
+	return hasil.IDToken, nil
+}
+
+// verifikasiIDToken memverifikasi tanda tangan, issuer, audience, masa berlaku, dan nonce ID token.
+func verifikasiIDToken(cfg konfigurasiOIDC, meta *metadataOIDC, idToken, nonce string) (jwt.MapClaims, error) {
+	klaim := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, klaim, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return idp.kunciVerifikasi(meta, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+		jwt.WithJSONNumber(), // NIP 18 digit tidak boleh dibulatkan float64
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := klaim["nonce"].(string); subtle.ConstantTimeCompare([]byte(n), []byte(nonce)) != 1 {
+		return nil, errors.New("nonce tidak cocok")
+	}
+	return klaim, nil
+}
+
+// stringKlaim membaca klaim string atau angka sebagai string.
+func stringKlaim(klaim jwt.MapClaims, nama string) string {
+	switch v := klaim[nama].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// gagalSSO mengarahkan browser kembali ke halaman login frontend dengan kode kesalahan.
+func gagalSSO(c *gin.Context, cfg konfigurasiOIDC, kode string) {
+	c.Redirect(http.StatusFound, cfg.FrontendURL+"/login?sso_error="+url.QueryEscape(kode))
+}
+
+// OIDCCallback: GET /api/oidc/callback
+// Memverifikasi respons IdP, menautkan / mencari akun berdasarkan sub atau NIP,
+// lalu membuat sesi dan mengarahkan browser ke dashboard frontend.
+func OIDCCallback(c *gin.Context) {
+	cfg, aktif := konfigurasiOIDCDariEnv()
+	if !aktif {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Login SSO tidak diaktifkan"})
+		return
+	}
+
+	// State sekali pakai: cookie dihapus dan jti dicabut
+	stateCookie, _ := c.Cookie(cookieStateOIDC)
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie(cookieStateOIDC, "", -1, "/api/oidc", domain, isSecure, true)
+	state := &klaimStateOIDC{}
+	token, err := parseJWT(stateCookie, state, audStateOIDC)
+	if err != nil || !token.Valid || state.Tujuan != tujuanOIDC || tokenDicabut(state.ID) ||
+		subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		gagalSSO(c, cfg, "state_tidak_valid")
+		return
+	}
+	if err := cabutJTI(DB, state.ID, state.ExpiresAt.Time); err != nil {
+		gagalSSO(c, cfg, "server")
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		log.Println("[OIDC] IdP mengembalikan error:", e, c.Query("error_description"))
+		gagalSSO(c, cfg, "idp_"+e)
+		return
+	}
+
+	meta, err := idp.metadata(cfg)
+	if err != nil {
+		log.Println("[OIDC] Gagal memuat discovery IdP:", err)
+		gagalSSO(c, cfg, "idp_tidak_tersedia")
+		return
+	}
+	idToken, err := tukarKodeOIDC(cfg, meta, c.Query("code"), state.Verifier)
+	if err != nil {
+		log.Println("[OIDC] Gagal menukar authorization code:", err)
+		gagalSSO(c, cfg, "token_gagal")
+		return
+	}
+	klaim, err := verifikasiIDToken(cfg, meta, idToken, state.Nonce)
+	if err != nil {
+		log.Println("[OIDC] ID token tidak valid:", err)
+		gagalSSO(c, cfg, "id_token_tidak_valid")
+		return
+	}
+
+	sub := stringKlaim(klaim, "sub")
+	nip := stringKlaim(klaim, cfg.KlaimNIP)
+	if sub == "" {
+		gagalSSO(c, cfg, "id_token_tidak_valid")
+		return
+	}
+	akun, kodeGagal := tautkanAkunOIDC(c, sub, nip)
+	if akun == nil {
+		catatPercobaanLogin(c, nip, false, "sso_"+kodeGagal)
+		gagalSSO(c, cfg, kodeGagal)
+		return
+	}
+	selesaikanLoginSSO(c, cfg, akun, state.Role, state.IDPeran)
+}
+
+// tautkanAkunOIDC mencari akun berdasarkan sub IdP, atau berdasarkan NIP lalu menautkannya.
+// Akun yang sudah tertaut ke sub lain tidak dapat diambil alih hanya dengan NIP yang sama.
+func tautkanAkunOIDC(c *gin.Context, sub, nip string) (*Akun, string) {
+	var akun Akun
+	err := DB.Where("oidc_subject = ?", sub).First(&akun).Error
+	if err == nil {
+		return &akun, ""
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "server"
+	}
+	if nip == "" {
+		return nil, "nip_tidak_ada"
+	}
+	if err := DB.Where("nip = ?", nip).First(&akun).Error; err != nil {
+		return nil, "nip_tidak_terdaftar"
+	}
+	if akun.OIDCSubject != nil {
+		log.Println("[OIDC] NIP", nip, "sudah tertaut ke subject lain")
+		return nil, "akun_tertaut_lain"
+	}
+
+	now := time.Now()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Akun{}).Where("id_akun = ? AND oidc_subject IS NULL", akun.ID).
+			Updates(map[string]interface{}{"oidc_subject": sub, "oidc_tertaut_pada": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("akun sudah tertaut")
+		}
+		return catatAudit(tx, c, "akun.tautkan_sso", fmt.Sprintf("akun:%d", akun.ID), "Akun ditautkan ke identitas SSO saat login pertama")
+	})
+	if err != nil {
+		return nil, "akun_tertaut_lain"
+	}
+	akun.OIDCSubject, akun.OIDCTertautPada = &sub, &now
+	log.Println("[OIDC] Akun", akun.ID, "ditautkan ke SSO, NIP:", nip)
+	return &akun, ""
+}
+
+// selesaikanLoginSSO memilih peran lalu membuat sesi. Jika masih perlu 2FA lokal atau
+// pemilihan peran, browser diarahkan ke halaman frontend yang sesuai dengan tiket di fragment URL.
+func selesaikanLoginSSO(c *gin.Context, cfg konfigurasiOIDC, akun *Akun, role string, idPeran uint) {
+	daftar, err := daftarPeranAkun(akun.ID)
+	if err != nil {
+		gagalSSO(c, cfg, "server")
+		return
+	}
+	if len(daftar) == 0 {
+		kode := "tanpa_peran"
+		if adaProfilNonaktif(akun.ID) {
+			kode = "nonaktif"
+		}
+		catatPercobaanLogin(c, akun.NIP, false, "sso_"+kode)
+		gagalSSO(c, cfg, kode)
+		return
+	}
+
+	if akun.TOTPAktif && !cfg.PercayaMFA {
+		tiket, err := terbitkanTiket(akun.ID, tujuan2FA, role, idPeran)
+		if err != nil {
+			gagalSSO(c, cfg, "server")
+			return
+		}
+		catatPercobaanLogin(c, akun.NIP, true, "sso_menunggu_2fa")
+		c.Redirect(http.StatusFound, cfg.FrontendURL+"/login/2fa#tiket="+url.QueryEscape(tiket))
+		return
+	}
+
+	if role == "" && len(daftar) > 1 {
+		tiket, err := terbitkanTiket(akun.ID, tujuanPilihPeran, "", 0)
+		if err != nil {
+			gagalSSO(c, cfg, "server")
+			return
+		}
+		catatPercobaanLogin(c, akun.NIP, true, "sso_pilih_peran")
+		c.Redirect(http.StatusFound, cfg.FrontendURL+"/login/pilih-peran#tiket="+url.QueryEscape(tiket))
+		return
+	}
+	peran := daftar[0]
+	if role != "" {
+		if peran, err = cariPeran(daftar, role, idPeran); err != nil {
+			gagalSSO(c, cfg, "peran_tidak_dimiliki")
+			return
+		}
+	}
+
+	if _, err := mulaiSesi(c, akun, peran); err != nil {
+		gagalSSO(c, cfg, "server")
+		return
+	}
+	catatPercobaanLogin(c, akun.NIP, true, "sso")
+	log.Println("[LOGIN SSO SUCCESS] Role:", peran.Role, ", Nama:", peran.Nama, ", OPD:", peran.NamaOPD)
+	c.Redirect(http.StatusFound, cfg.FrontendURL+pathDashboard(peran.Role))
+}
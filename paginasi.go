@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= HELPER PAGINASI, SORTING & FILTER UNTUK ENDPOINT LIST =========
+
+const (
+	perPageDefault  = 20
+	perPageMaksimal = 100
+)
+
+// parameterError menandai kesalahan pada query string dari client (HTTP 400),
+// untuk dibedakan dari kesalahan database (HTTP 500).
+type parameterError struct{ pesan string }
+
+func (e *parameterError) Error() string { return e.pesan }
+
+// errParameter membuat parameterError baru.
+func errParameter(pesan string) error { return &parameterError{pesan: pesan} }
+
+// konflikError menandai data yang berubah oleh request lain di antara pembacaan dan
+// penyimpanan (HTTP 409), mis. update bersyarat yang tidak mengenai baris mana pun.
+type konflikError struct{ pesan string }
+
+func (e *konflikError) Error() string { return e.pesan }
+
+// errKonflik membuat konflikError baru.
+func errKonflik(pesan string) error { return &konflikError{pesan: pesan} }
+
+// pelanggaranUnik memeriksa apakah err berasal dari pelanggaran constraint unique di database.
+func pelanggaranUnik(err error) bool {
+	if penerjemah, ok := DB.Dialector.(gorm.ErrorTranslator); ok {
+		err = penerjemah.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// statusUntukError mengembalikan 400 untuk parameterError, 409 untuk konflikError, dan 500 untuk lainnya.
+func statusUntukError(err error) int {
+	var pe *parameterError
+	if errors.As(err, &pe) {
+		return http.StatusBadRequest
+	}
+	var ke *konflikError
+	if errors.As(err, &ke) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// Paginasi menampung parameter ?page=&per_page=&sort=&order= yang sudah divalidasi.
+type Paginasi struct {
+	Page    int
+	PerPage int
+	Sort    string // Nama kolom database (sudah melalui whitelist)
+	Order   string // "ASC" atau "DESC"
+}
+
+// MetaPaginasi adalah bagian "meta" dari envelope {data, meta}.
+type MetaPaginasi struct {
+	Total      int64   `json:"total"`
+	Page       int     `json:"page,omitempty"`
+	PerPage    int     `json:"per_page"`
+	NextCursor *string `json:"next_cursor,omitempty"` // Hanya untuk mode cursor
+}
+
+// bindPaginasi membaca parameter paginasi dari query string.
+// kolomSort memetakan nama sort yang boleh dipakai client ke nama kolom database.
+func bindPaginasi(c *gin.Context, kolomSort map[string]string, sortDefault, orderDefault string) (Paginasi, error) {
+	p := Paginasi{Page: 1, PerPage: perPageDefault, Sort: kolomSort[sortDefault], Order: orderDefault}
+
+	if s := c.Query("page"); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return p, errParameter("parameter page harus angka >= 1")
+		}
+		p.Page = page
+	}
+	if s := c.Query("per_page"); s != "" {
+		perPage, err := strconv.Atoi(s)
+		if err != nil || perPage < 1 || perPage > perPageMaksimal {
+			return p, errParameter("parameter per_page harus angka 1 - " + strconv.Itoa(perPageMaksimal))
+		}
+		p.PerPage = perPage
+	}
+	if s := c.Query("sort"); s != "" {
+		kolom, ok := kolomSort[s]
+		if !ok {
+			daftar := make([]string, 0, len(kolomSort))
+			for k := range kolomSort {
+				daftar = append(daftar, k)
+			}
+			sort.Strings(daftar)
+			return p, errParameter("parameter sort harus salah satu dari: " + strings.Join(daftar, ", "))
+		}
+		p.Sort = kolom
+	}
+	if s := c.Query("order"); s != "" {
+		switch strings.ToLower(s) {
+		case "asc":
+			p.Order = "ASC"
+		case "desc":
+			p.Order = "DESC"
+		default:
+			return p, errParameter("parameter order harus asc atau desc")
+		}
+	}
+	return p, nil
+}
+
+// Terapkan menambahkan ORDER BY, OFFSET, dan LIMIT ke query.
+// Primary key dipakai sebagai pengurut kedua agar hasil antar halaman stabil.
+func (p Paginasi) Terapkan(query *gorm.DB, primaryKey string) *gorm.DB {
+	return query.Order(p.Sort + " " + p.Order + ", " + primaryKey + " " + p.Order).
+		Offset((p.Page - 1) * p.PerPage).
+		Limit(p.PerPage)
+}
+
+// Meta membuat MetaPaginasi dari total baris.
+func (p Paginasi) Meta(total int64) MetaPaginasi {
+	return MetaPaginasi{Total: total, Page: p.Page, PerPage: p.PerPage}
+}
+
+// ambilHalaman menghitung total baris lalu mengambil satu halaman data ke hasil.
+// preload (boleh nil) dipasang setelah Count agar tidak ikut dihitung.
+func ambilHalaman(query *gorm.DB, p Paginasi, primaryKey string, preload func(*gorm.DB) *gorm.DB, hasil interface{}) (MetaPaginasi, error) {
+	// Session agar query yang sama aman dipakai untuk Count dan Find
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return MetaPaginasi{}, err
+	}
+	if preload != nil {
+		query = preload(query)
+	}
+	if err := p.Terapkan(query, primaryKey).Find(hasil).Error; err != nil {
+		return MetaPaginasi{}, err
+	}
+	return p.Meta(total), nil
+}
+
+// filterRentangTanggal menerapkan ?created_from=&created_to= (YYYY-MM-DD, inklusif) pada kolom.
+func filterRentangTanggal(c *gin.Context, query *gorm.DB, kolom string) (*gorm.DB, error) {
+	if s := c.Query("created_from"); s != "" {
+		dari, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return query, errParameter("parameter created_from harus berformat YYYY-MM-DD")
+		}
+		query = query.Where(kolom+" >= ?", dari)
+	}
+	if s := c.Query("created_to"); s != "" {
+		sampai, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return query, errParameter("parameter created_to harus berformat YYYY-MM-DD")
+		}
+		query = query.Where(kolom+" < ?", sampai.AddDate(0, 0, 1))
+	}
+	return query, nil
+}
+
+// filterID menerapkan filter kolom = ? dari query string numerik (mis. ?id_opd=3).
+func filterID(c *gin.Context, query *gorm.DB, param, kolom string) (*gorm.DB, error) {
+	s := c.Query(param)
+	if s == "" {
+		return query, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return query, errParameter("parameter " + param + " harus berupa angka")
+	}
+	return query.Where(kolom+" = ?", id), nil
+}
+
+// responList mengirim envelope standar {data, meta} untuk endpoint list.
+// Pemanggil menginisialisasi data dengan []T{} agar hasil kosong terkirim [] (bukan null).
+func responList(c *gin.Context, data interface{}, meta MetaPaginasi) {
+	c.JSON(http.StatusOK, gin.H{"data": data, "meta": meta})
+}
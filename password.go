@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ========= KEBIJAKAN & HASHING PASSWORD =========
+
+// KebijakanPassword adalah aturan kekuatan password yang dapat dikonfigurasi lewat env:
+// PASSWORD_MIN_LENGTH, PASSWORD_REQUIRE_UPPER, PASSWORD_REQUIRE_LOWER,
+// PASSWORD_REQUIRE_DIGIT, dan PASSWORD_REQUIRE_SYMBOL.
+type KebijakanPassword struct {
+	PanjangMin      int
+	WajibHurufBesar bool
+	WajibHurufKecil bool
+	WajibAngka      bool
+	WajibSimbol     bool
+}
+
+// bcrypt hanya memproses 72 byte pertama, password yang lebih panjang ditolak.
+const panjangMaksPassword = 72
+
+// kebijakanPassword membaca kebijakan dari environment (dengan nilai default yang aman).
+func kebijakanPassword() KebijakanPassword {
+	return KebijakanPassword{
+		PanjangMin:      envInt("PASSWORD_MIN_LENGTH", 8),
+		WajibHurufBesar: envBool("PASSWORD_REQUIRE_UPPER", true),
+		WajibHurufKecil: envBool("PASSWORD_REQUIRE_LOWER", true),
+		WajibAngka:      envBool("PASSWORD_REQUIRE_DIGIT", true),
+		WajibSimbol:     envBool("PASSWORD_REQUIRE_SYMBOL", false),
+	}
+}
+
+// Validasi memeriksa password terhadap kebijakan. Semua pelanggaran digabung dalam satu pesan.
+func (k KebijakanPassword) Validasi(password, nip string) error {
+	var pelanggaran []string
+
+	if len([]rune(password)) < k.PanjangMin {
+		pelanggaran = append(pelanggaran, "minimal "+strconv.Itoa(k.PanjangMin)+" karakter")
+	}
+	if len(password) > panjangMaksPassword {
+		pelanggaran = append(pelanggaran, "maksimal "+strconv.Itoa(panjangMaksPassword)+" byte")
+	}
+
+	var besar, kecil, angka, simbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			besar = true
+		case unicode.IsLower(r):
+			kecil = true
+		case unicode.IsDigit(r):
+			angka = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			simbol = true
+		}
+	}
+	if k.WajibHurufBesar && !besar {
+		pelanggaran = append(pelanggaran, "mengandung huruf besar")
+	}
+	if k.WajibHurufKecil && !kecil {
+		pelanggaran = append(pelanggaran, "mengandung huruf kecil")
+	}
+	if k.WajibAngka && !angka {
+		pelanggaran = append(pelanggaran, "mengandung angka")
+	}
+	if k.WajibSimbol && !simbol {
+		pelanggaran = append(pelanggaran, "mengandung simbol")
+	}
+	if nip != "" && strings.EqualFold(strings.TrimSpace(password), strings.TrimSpace(nip)) {
+		pelanggaran = append(pelanggaran, "tidak sama dengan NIP")
+	}
+
+	if len(pelanggaran) > 0 {
+		return errors.New("Password harus " + strings.Join(pelanggaran, ", "))
+	}
+	return nil
+}
+
+// hashSamaran adalah hash bcrypt acak yang dibandingkan saat NIP tidak ditemukan, sehingga
+// login dengan NIP tidak terdaftar memakan waktu yang sama dengan password salah.
+var hashSamaran = sync.OnceValue(func() []byte {
+	acak := make([]byte, 32)
+	rand.Read(acak)
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(acak)), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("⚠ Gagal membuat hash samaran:", err)
+	}
+	return hash
+})
+
+// cocokkanPassword memeriksa password terhadap hash bcrypt. Hash kosong (NIP tidak ditemukan)
+// tetap dibandingkan dengan hashSamaran sehingga biaya bcrypt selalu dibayar, lalu ditolak.
+func cocokkanPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(hashSamaran(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// hashPassword membuat hash bcrypt dari password.
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// envInt membaca environment variable bertipe angka, atau nilai default.
+func envInt(nama string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(nama)); err == nil {
+		return n
+	}
+	return def
+}
+
+// envBool membaca environment variable bertipe boolean, atau nilai default.
+func envBool(nama string, def bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(nama)); err == nil {
+		return b
+	}
+	return def
+}
+
+// ========= GANTI PASSWORD & RESET PASSWORD OLEH ADMIN =========
+
+// kredensialUser adalah ringkasan data login milik UserOPD atau UserPemda beserta akunnya.
+type kredensialUser struct {
+	Role               string
+	ID                 uint
+	IDAkun             uint
+	NIP                string
+	Nama               string
+	PasswordHash       string
+	WajibGantiPassword bool
+}
+
+// muatKredensial mengambil kredensial user berdasarkan role ("opd"/"pemda") dan ID profil.
+// Password disimpan di akun sehingga berlaku untuk semua peran milik akun tersebut.
+func muatKredensial(role string, id uint) (*kredensialUser, error) {
+	peran, idAkun, err := peranUntukUser(role, id)
+	if err != nil {
+		return nil, err
+	}
+	var akun Akun
+	if err := DB.First(&akun, idAkun).Error; err != nil {
+		return nil, err
+	}
+	return &kredensialUser{Role: role, ID: peran.ID, IDAkun: akun.ID, NIP: akun.NIP, Nama: peran.Nama, PasswordHash: akun.Password, WajibGantiPassword: akun.WajibGantiPassword}, nil
+}
+
+// simpanPassword memperbarui hash password dan flag wajib ganti password milik akun.
+func simpanPassword(tx *gorm.DB, idAkun uint, hash string, wajibGanti bool) error {
+	return tx.Model(&Akun{}).Where("id_akun = ?", idAkun).
+		Updates(map[string]interface{}{"password": hash, "wajib_ganti_password": wajibGanti, "updated_at": time.Now()}).Error
+}
+
+// tokenAcak membuat token acak URL-safe sepanjang n byte entropi.
+func tokenAcak(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken mengembalikan SHA-256 (hex) dari token; hanya hash yang disimpan di database.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// targetUser membentuk string target log audit, mis. "opd:12".
+func targetUser(role string, id uint) string {
+	return role + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// GantiPassword: User (OPD / Pemda) mengganti password sendiri dengan menyertakan password lama
+func GantiPassword(c *gin.Context) {
+	var req GantiPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password lama dan password baru wajib diisi"})
+		return
+	}
+
+	userClaims, _ := c.Get("user")
+	claims := userClaims.(*Claims)
+
+	user, err := muatKredensial(claims.Role, claims.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.PasswordLama)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password lama salah"})
+		return
+	}
+	if req.PasswordBaru == req.PasswordLama {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password baru tidak boleh sama dengan password lama"})
+		return
+	}
+	if err := kebijakanPassword().Validasi(req.PasswordBaru, user.NIP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hashed, err := hashPassword(req.PasswordBaru)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses password"})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := simpanPassword(tx, user.IDAkun, hashed, false); err != nil {
+			return err
+		}
+		// Sesi di perangkat lain diakhiri, sesi saat ini tetap berjalan
+		if _, err := cabutSemuaSesi(tx, user.IDAkun, claims.IDSesi, "Password diganti"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "password.ubah", targetUser(user.Role, user.ID), "Password diganti oleh pemilik akun")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password berhasil diganti"})
+}
+
+// TerbitkanTokenResetPassword: Admin Pemda menerbitkan token reset sekali pakai untuk user.
+// User wajib mengganti password (lewat POST /api/reset-password) sebelum dapat login lagi.
+func TerbitkanTokenResetPassword(c *gin.Context) {
+	role := c.Param("role")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID user tidak valid"})
+		return
+	}
+
+	user, err := muatKredensial(role, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := tokenAcak(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
+		return
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("RESET_PASSWORD_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	userClaims, _ := c.Get("user")
+	claims := userClaims.(*Claims)
+	now := time.Now()
+	reset := TokenResetPassword{
+		Role:            user.Role,
+		IDUser:          user.ID,
+		TokenHash:       hashToken(token),
+		KedaluwarsaPada: now.Add(ttl),
+		IDPembuat:       claims.ID,
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// Token lama yang belum terpakai dibatalkan
+		if err := tx.Model(&TokenResetPassword{}).
+			Where("role = ? AND id_user = ? AND dipakai_pada IS NULL", user.Role, user.ID).
+			Update("kedaluwarsa_pada", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&reset).Error; err != nil {
+			return err
+		}
+		if err := simpanPassword(tx, user.IDAkun, user.PasswordHash, true); err != nil {
+			return err
+		}
+		if _, err := cabutSemuaSesi(tx, user.IDAkun, 0, "Password direset admin"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "password.reset_token", targetUser(user.Role, user.ID),
+			"Token reset password diterbitkan, berlaku hingga "+reset.KedaluwarsaPada.Format(time.RFC3339))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menerbitkan token reset"})
+		return
+	}
+
+	// Token hanya ditampilkan sekali, serahkan ke user melalui saluran yang aman
+	c.JSON(http.StatusCreated, gin.H{
+		"token":            token,
+		"kedaluwarsa_pada": reset.KedaluwarsaPada,
+		"user":             gin.H{"role": user.Role, "id": user.ID, "nip": user.NIP, "nama": user.Nama},
+	})
+}
+
+// ResetPasswordDenganToken: Endpoint publik untuk menukar token reset dengan password baru
+func ResetPasswordDenganToken(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token dan password baru wajib diisi"})
+		return
+	}
+
+	var reset TokenResetPassword
+	err := DB.Where("token_hash = ? AND dipakai_pada IS NULL AND kedaluwarsa_pada > ?", hashToken(req.Token), time.Now()).
+		First(&reset).Error
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token reset tidak valid atau sudah kedaluwarsa"})
+		return
+	}
+
+	user, err := muatKredensial(reset.Role, reset.IDUser)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	if err := kebijakanPassword().Validasi(req.PasswordBaru, user.NIP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hashed, err := hashPassword(req.PasswordBaru)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses password"})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// Tandai terpakai secara atomik agar token tidak bisa dipakai dua kali
+		hasil := tx.Model(&TokenResetPassword{}).
+			Where("id_token_reset_password = ? AND dipakai_pada IS NULL", reset.ID).
+			Update("dipakai_pada", time.Now())
+		if hasil.Error != nil {
+			return hasil.Error
+		}
+		if hasil.RowsAffected == 0 {
+			return errors.New("token sudah dipakai")
+		}
+		if err := simpanPassword(tx, user.IDAkun, hashed, false); err != nil {
+			return err
+		}
+		if _, err := cabutSemuaSesi(tx, user.IDAkun, 0, "Password diatur ulang"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "password.reset", targetUser(user.Role, user.ID), "Password diatur ulang memakai token reset")
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal mereset password: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password berhasil diatur ulang, silakan login kembali"})
+}
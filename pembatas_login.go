@@ -0,0 +1,357 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= PERLINDUNGAN BRUTE-FORCE LOGIN (PER NIP & PER IP) =========
+//
+// Setiap login gagal menaikkan penghitung untuk kunci "nip:<nip>" dan "ip:<alamat>".
+// Sebelum mencapai batas, percobaan berikutnya harus menunggu jeda yang berlipat dua
+// (backoff eksponensial). Setelah batas tercapai, kunci dikunci sementara dan durasi
+// penguncian juga berlipat dua untuk setiap kegagalan tambahan.
+
+// statusKunci adalah kondisi penghitung kegagalan untuk satu kunci.
+type statusKunci struct {
+	JumlahGagal    int
+	TerakhirGagal  time.Time
+	TerkunciSampai time.Time
+}
+
+// penyimpanLogin menyimpan penghitung kegagalan login.
+// penyimpanMemori cukup untuk satu instance; penyimpanDB dipakai saat berjalan di beberapa replika.
+type penyimpanLogin interface {
+	// Ambil mengembalikan status kunci (nilai nol jika belum ada).
+	Ambil(kunci string) (statusKunci, error)
+	// TambahGagal menaikkan penghitung secara atomik. Penghitung dimulai ulang dari 1
+	// jika kegagalan terakhir lebih lama dari jendela dan kunci sedang tidak terkunci.
+	TambahGagal(kunci string, now time.Time, jendela time.Duration) (int, error)
+	// Kunci mengatur batas waktu tunggu untuk kunci.
+	Kunci(kunci string, sampai time.Time) error
+	// Hapus membuang penghitung (login berhasil atau dibuka admin).
+	Hapus(kunci string) error
+}
+
+// pembatasLogin adalah penyimpan aktif, dipilih lewat env LOGIN_STORE (memory / db).
+var pembatasLogin penyimpanLogin = newPenyimpanMemori()
+
+// siapkanPembatasLogin memilih penyimpan penghitung kegagalan login sesuai env LOGIN_STORE.
+func siapkanPembatasLogin() {
+	switch strings.ToLower(os.Getenv("LOGIN_STORE")) {
+	case "db", "database":
+		pembatasLogin = penyimpanDB{db: DB}
+		log.Println("🔒 Penguncian login memakai penyimpan database (bersama antar replika)")
+	default:
+		pembatasLogin = newPenyimpanMemori()
+		log.Println("🔒 Penguncian login memakai penyimpan memori (satu instance)")
+	}
+}
+
+// KebijakanLogin berisi batas percobaan login yang dapat diatur lewat environment.
+type KebijakanLogin struct {
+	MaksGagalNIP int           // LOGIN_MAX_GAGAL, default 5
+	MaksGagalIP  int           // LOGIN_MAX_GAGAL_IP, default 20
+	JedaDasar    time.Duration // LOGIN_BACKOFF_DASAR, default 1s: jeda setelah kegagalan pertama
+	DurasiKunci  time.Duration // LOGIN_DURASI_KUNCI, default 15m: penguncian pertama
+	DurasiMaks   time.Duration // LOGIN_DURASI_KUNCI_MAKS, default 24h
+	Jendela      time.Duration // LOGIN_JENDELA_GAGAL, default 1h: penghitung dimulai ulang setelah jeda ini
+}
+
+// kebijakanLogin membaca KebijakanLogin dari environment.
+func kebijakanLogin() KebijakanLogin {
+	return KebijakanLogin{
+		MaksGagalNIP: envInt("LOGIN_MAX_GAGAL", 5),
+		MaksGagalIP:  envInt("LOGIN_MAX_GAGAL_IP", 20),
+		JedaDasar:    envDurasi("LOGIN_BACKOFF_DASAR", time.Second),
+		DurasiKunci:  envDurasi("LOGIN_DURASI_KUNCI", 15*time.Minute),
+		DurasiMaks:   envDurasi("LOGIN_DURASI_KUNCI_MAKS", 24*time.Hour),
+		Jendela:      envDurasi("LOGIN_JENDELA_GAGAL", time.Hour),
+	}
+}
+
+// envDurasi membaca env berformat durasi Go (mis. "15m"), atau def jika kosong / tidak valid.
+func envDurasi(nama string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(nama)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// lipatDua mengembalikan dasar * 2^n, dibatasi maks.
+func lipatDua(dasar time.Duration, n int, maks time.Duration) time.Duration {
+	d := dasar
+	for i := 0; i < n; i++ {
+		d *= 2
+		if d >= maks {
+			return maks
+		}
+	}
+	if d > maks {
+		return maks
+	}
+	return d
+}
+
+// waktuTunggu menghitung lama kunci harus menunggu setelah kegagalan ke-jumlah.
+func (k KebijakanLogin) waktuTunggu(jumlah, maksGagal int) time.Duration {
+	if jumlah >= maksGagal {
+		return lipatDua(k.DurasiKunci, jumlah-maksGagal, k.DurasiMaks)
+	}
+	return lipatDua(k.JedaDasar, jumlah-1, k.DurasiKunci)
+}
+
+func kunciNIP(nip string) string { return "nip:" + nip }
+func kunciIP(ip string) string   { return "ip:" + ip }
+
+// cekKunciLogin mengembalikan sisa waktu tunggu terlama untuk NIP dan IP (0 jika boleh mencoba).
+func cekKunciLogin(nip, ip string) time.Duration {
+	now := time.Now()
+	var sisa time.Duration
+	for _, kunci := range []string{kunciNIP(nip), kunciIP(ip)} {
+		st, err := pembatasLogin.Ambil(kunci)
+		if err != nil {
+			log.Println("[LOGIN] Gagal membaca status penguncian:", err)
+			continue
+		}
+		if d := st.TerkunciSampai.Sub(now); d > sisa {
+			sisa = d
+		}
+	}
+	return sisa
+}
+
+// catatLoginGagal menaikkan penghitung NIP dan IP lalu memperbarui waktu tunggunya.
+func catatLoginGagal(nip, ip string) {
+	k := kebijakanLogin()
+	now := time.Now()
+	for _, item := range []struct {
+		kunci string
+		maks  int
+	}{{kunciNIP(nip), k.MaksGagalNIP}, {kunciIP(ip), k.MaksGagalIP}} {
+		jumlah, err := pembatasLogin.TambahGagal(item.kunci, now, k.Jendela)
+		if err != nil {
+			log.Println("[LOGIN] Gagal mencatat kegagalan login:", err)
+			continue
+		}
+		if err := pembatasLogin.Kunci(item.kunci, now.Add(k.waktuTunggu(jumlah, item.maks))); err != nil {
+			log.Println("[LOGIN] Gagal menyimpan penguncian:", err)
+		}
+		if jumlah == item.maks {
+			log.Println("[LOGIN] Terkunci sementara:", item.kunci)
+		}
+	}
+}
+
+// catatLoginBerhasil membuang penghitung NIP. Penghitung IP tidak dibuang agar
+// satu akun yang valid tidak dapat dipakai untuk menghapus jejak tebakan dari IP yang sama.
+func catatLoginBerhasil(nip string) {
+	if err := pembatasLogin.Hapus(kunciNIP(nip)); err != nil {
+		log.Println("[LOGIN] Gagal membuang penghitung kegagalan:", err)
+	}
+}
+
+// catatPercobaanLogin menyimpan satu baris log percobaan login.
+func catatPercobaanLogin(c *gin.Context, nip string, berhasil bool, alasan string) {
+	percobaan := LoginAttempt{
+		NIP:       nip,
+		AlamatIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Berhasil:  berhasil,
+		Alasan:    alasan,
+	}
+	if err := DB.Create(&percobaan).Error; err != nil {
+		log.Println("[LOGIN] Gagal menyimpan log percobaan login:", err)
+	}
+}
+
+// tolakLoginTerkunci mengirim 429 beserta header Retry-After.
+func tolakLoginTerkunci(c *gin.Context, sisa time.Duration) {
+	detik := int(sisa.Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(detik))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Terlalu banyak percobaan login gagal. Silakan coba lagi dalam " + strconv.Itoa(detik) + " detik.",
+		"retry_after": detik,
+	})
+}
+
+// kolomSortLoginAttempt: nilai ?sort= yang diizinkan untuk list percobaan login
+var kolomSortLoginAttempt = map[string]string{
+	"created_at": "created_at",
+	"nip":        "nip",
+	"alamat_ip":  "alamat_ip",
+}
+
+// GetAllLoginAttempt: Pemda melihat log percobaan login (?nip=&alamat_ip=&berhasil=&created_from=&created_to=)
+func GetAllLoginAttempt(c *gin.Context) {
+	daftar := []LoginAttempt{}
+
+	query := DB.Model(&LoginAttempt{})
+	if nip := c.Query("nip"); nip != "" {
+		query = query.Where("nip = ?", nip)
+	}
+	if ip := c.Query("alamat_ip"); ip != "" {
+		query = query.Where("alamat_ip = ?", ip)
+	}
+	if s := c.Query("berhasil"); s != "" {
+		berhasil, err := strconv.ParseBool(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parameter berhasil harus true atau false"})
+			return
+		}
+		query = query.Where("berhasil = ?", berhasil)
+	}
+	query, err := filterRentangTanggal(c, query, "created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := bindPaginasi(c, kolomSortLoginAttempt, "created_at", "DESC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := ambilHalaman(query, p, "id_login_attempt", nil, &daftar)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	responList(c, daftar, meta)
+}
+
+// BukaKunciLogin: Pemda membuka penguncian login untuk NIP dan/atau alamat IP
+func BukaKunciLogin(c *gin.Context) {
+	var req BukaKunciLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.NIP == "" && req.AlamatIP == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "NIP atau alamat_ip wajib diisi"})
+		return
+	}
+
+	var kunci []string
+	if req.NIP != "" {
+		kunci = append(kunci, kunciNIP(req.NIP))
+	}
+	if req.AlamatIP != "" {
+		kunci = append(kunci, kunciIP(req.AlamatIP))
+	}
+	for _, k := range kunci {
+		if err := pembatasLogin.Hapus(k); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka penguncian"})
+			return
+		}
+	}
+	if err := catatAudit(DB, c, "login.buka_kunci", strings.Join(kunci, ","), "Penguncian login dibuka oleh admin"); err != nil {
+		log.Println("[AUDIT] Gagal mencatat buka kunci login:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Penguncian login berhasil dibuka", "kunci": kunci})
+}
+
+// ----- Penyimpan memori (satu instance) -----
+
+type penyimpanMemori struct {
+	mu   sync.Mutex
+	data map[string]*statusKunci
+}
+
+func newPenyimpanMemori() *penyimpanMemori {
+	return &penyimpanMemori{data: make(map[string]*statusKunci)}
+}
+
+func (m *penyimpanMemori) Ambil(kunci string) (statusKunci, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.data[kunci]; ok {
+		return *st, nil
+	}
+	return statusKunci{}, nil
+}
+
+func (m *penyimpanMemori) TambahGagal(kunci string, now time.Time, jendela time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Bersihkan entri kedaluwarsa agar map tidak tumbuh tanpa batas
+	if len(m.data) > 10000 {
+		for k, st := range m.data {
+			if now.Sub(st.TerakhirGagal) > jendela && now.After(st.TerkunciSampai) {
+				delete(m.data, k)
+			}
+		}
+	}
+
+	st, ok := m.data[kunci]
+	if !ok {
+		st = &statusKunci{}
+		m.data[kunci] = st
+	}
+	if now.Sub(st.TerakhirGagal) > jendela && now.After(st.TerkunciSampai) {
+		st.JumlahGagal = 0
+	}
+	st.JumlahGagal++
+	st.TerakhirGagal = now
+	return st.JumlahGagal, nil
+}
+
+func (m *penyimpanMemori) Kunci(kunci string, sampai time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.data[kunci]; ok {
+		st.TerkunciSampai = sampai
+	}
+	return nil
+}
+
+func (m *penyimpanMemori) Hapus(kunci string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, kunci)
+	return nil
+}
+
+// ----- Penyimpan database (bersama antar replika) -----
+
+type penyimpanDB struct {
+	db *gorm.DB
+}
+
+func (p penyimpanDB) Ambil(kunci string) (statusKunci, error) {
+	var baris PenguncianLogin
+	err := p.db.Where("kunci = ?", kunci).Limit(1).Find(&baris).Error
+	st := statusKunci{JumlahGagal: baris.JumlahGagal, TerakhirGagal: baris.TerakhirGagal}
+	if baris.TerkunciSampai != nil {
+		st.TerkunciSampai = *baris.TerkunciSampai
+	}
+	return st, err
+}
+
+func (p penyimpanDB) TambahGagal(kunci string, now time.Time, jendela time.Duration) (int, error) {
+	// Upsert atomik agar aman dipanggil bersamaan dari beberapa replika
+	var jumlah int
+	err := p.db.Raw(`INSERT INTO penguncian_login (kunci, jumlah_gagal, terakhir_gagal) VALUES (?, 1, ?)
+		ON CONFLICT (kunci) DO UPDATE SET
+			jumlah_gagal = CASE
+				WHEN penguncian_login.terakhir_gagal < ? AND (penguncian_login.terkunci_sampai IS NULL OR penguncian_login.terkunci_sampai < ?)
+				THEN 1 ELSE penguncian_login.jumlah_gagal + 1 END,
+			terakhir_gagal = EXCLUDED.terakhir_gagal
+		RETURNING jumlah_gagal`,
+		kunci, now, now.Add(-jendela), now).Scan(&jumlah).Error
+	return jumlah, err
+}
+
+func (p penyimpanDB) Kunci(kunci string, sampai time.Time) error {
+	return p.db.Model(&PenguncianLogin{}).Where("kunci = ?", kunci).Update("terkunci_sampai", sampai).Error
+}
+
+func (p penyimpanDB) Hapus(kunci string) error {
+	return p.db.Where("kunci = ?", kunci).Delete(&PenguncianLogin{}).Error
+}
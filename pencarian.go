@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= PENCARIAN FULL-TEXT (PENGAJUAN, PEMOHON, STANDAR PELAYANAN) =========
+
+// Konfigurasi pencarian yang terdeteksi saat startup (lihat siapkanPencarian).
+var (
+	konfigurasiFTS    = "simple" // "indonesian" jika tersedia di PostgreSQL (stemmer Snowball)
+	trigramTersedia   = false    // true jika ekstensi pg_trgm berhasil diaktifkan
+	batasHasilDefault = 20
+	batasHasilMaks    = 50
+)
+
+// HasilPencarian adalah satu hit pencarian dengan tipe dan skor relevansi.
+type HasilPencarian struct {
+	Tipe     string  `gorm:"column:tipe" json:"tipe"` // pengajuan / pemohon / standar
+	ID       uint    `gorm:"column:id" json:"id"`
+	Judul    string  `gorm:"column:judul" json:"judul"`
+	Subjudul string  `gorm:"column:subjudul" json:"subjudul"`
+	Status   string  `gorm:"column:status" json:"status,omitempty"`
+	Cuplikan string  `gorm:"column:cuplikan" json:"cuplikan"` // Potongan teks dengan kata yang cocok ditandai <b>
+	IDOPD    uint    `gorm:"column:id_opd" json:"id_opd"`
+	Skor     float64 `gorm:"column:skor" json:"skor"`
+}
+
+// sumberPencarian mendeskripsikan satu tabel yang dapat dicari.
+type sumberPencarian struct {
+	Tipe       string
+	Tabel      string
+	KolomID    string
+	KolomJudul string
+	KolomSub   string
+	KolomStat  string   // Boleh kosong
+	KolomFTS   []string // Kolom yang masuk ke tsvector
+	KolomTrgm  []string // Kolom untuk pencocokan fuzzy (nama)
+	KolomNIK   string   // Kolom NIK untuk pencarian potongan angka (boleh kosong)
+	KolomTeks  string   // Kolom sumber cuplikan
+	BatasOPD   bool     // true jika hasil untuk role OPD dibatasi pada OPD-nya sendiri
+	Kondisi    string   // Kondisi SQL tambahan (boleh kosong), mis. mengecualikan data diarsipkan
+	Izin       string   // Izin untuk membaca sumber ini; sumber dilewati jika user tidak memilikinya
+	IzinSemua  string   // Izin melihat data seluruh OPD (boleh kosong); tanpanya hasil dibatasi OPD user
+}
+
+var sumberPencarianList = []sumberPencarian{
+	{
+		Tipe: "pengajuan", Tabel: "form_pengajuan", KolomID: "id_form_pengajuan",
+		KolomJudul: "judul_pengajuan", KolomSub: "nama_pemohon_lengkap", KolomStat: "status_proses",
+		KolomFTS:  []string{"judul_pengajuan", "deskripsi_singkat", "nama_pemohon_lengkap"},
+		KolomTrgm: []string{"nama_pemohon_lengkap", "judul_pengajuan"},
+		KolomNIK:  "nik_pemohon", KolomTeks: "deskripsi_singkat", BatasOPD: true,
+		Kondisi: kondisiPengajuanAktif,
+		Izin:    "pengajuan.read", IzinSemua: "pengajuan.read_all",
+	},
+	{
+		Tipe: "pemohon", Tabel: "form_pemohon", KolomID: "id_form_pemohon",
+		KolomJudul: "nama_lengkap", KolomSub: "alamat",
+		KolomFTS:  []string{"nama_lengkap", "alamat"},
+		KolomTrgm: []string{"nama_lengkap"},
+		KolomNIK:  "nik", KolomTeks: "alamat", BatasOPD: true,
+		Izin: "pemohon.read",
+	},
+	{
+		Tipe: "standar", Tabel: "jenis_pelayanan", KolomID: "id_jenis_pelayanan",
+		KolomJudul: "nama_standar", KolomSub: "produk_pelayanan", KolomStat: "status_validasi",
+		KolomFTS:  []string{"nama_standar", "dasar_hukum", "persyaratan", "produk_pelayanan"},
+		KolomTrgm: []string{"nama_standar"},
+		KolomTeks: "persyaratan", BatasOPD: false, // Standar pelayanan bersifat publik
+		Kondisi: "diarsipkan_pada IS NULL",
+		Izin:    "standar.read",
+	},
+}
+
+// vektorFTS membangun ekspresi tsvector. Harus identik antara index dan query
+// agar PostgreSQL dapat memakai index GIN.
+func (s sumberPencarian) vektorFTS() string {
+	bagian := make([]string, len(s.KolomFTS))
+	for i, k := range s.KolomFTS {
+		bagian[i] = "coalesce(" + k + ", '')"
+	}
+	return fmt.Sprintf("to_tsvector('%s'::regconfig, %s)", konfigurasiFTS, strings.Join(bagian, " || ' ' || "))
+}
+
+// siapkanPencarian mendeteksi konfigurasi bahasa, mengaktifkan pg_trgm (jika diizinkan),
+// dan membuat index GIN untuk pencarian. Kegagalan hanya dicatat di log.
+func siapkanPencarian() {
+	var adaIndonesian bool
+	DB.Raw("SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'indonesian')").Scan(&adaIndonesian)
+	if adaIndonesian {
+		konfigurasiFTS = "indonesian"
+	}
+
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Println("⚠ pg_trgm tidak dapat diaktifkan, pencarian fuzzy memakai ILIKE:", err)
+	} else {
+		trigramTersedia = true
+	}
+
+	for _, s := range sumberPencarianList {
+		sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_fts_%s ON %s USING GIN ((%s))", s.Tabel, konfigurasiFTS, s.Tabel, s.vektorFTS())
+		if err := DB.Exec(sql).Error; err != nil {
+			log.Println("⚠ Gagal membuat index full-text", s.Tabel+":", err)
+		}
+		if !trigramTersedia {
+			continue
+		}
+		kolomTrgm := append([]string{}, s.KolomTrgm...)
+		if s.KolomNIK != "" {
+			kolomTrgm = append(kolomTrgm, s.KolomNIK)
+		}
+		for _, k := range kolomTrgm {
+			sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s_trgm ON %s USING GIN (%s gin_trgm_ops)", s.Tabel, k, s.Tabel, k)
+			if err := DB.Exec(sql).Error; err != nil {
+				log.Println("⚠ Gagal membuat index trigram", s.Tabel+"."+k+":", err)
+			}
+		}
+	}
+	log.Println("🔎 Pencarian siap (konfigurasi:", konfigurasiFTS+", trigram:", trigramTersedia, ")")
+}
+
+// escapeLike meng-escape karakter wildcard LIKE dari input pengguna.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// kueri menyusun query pencarian pada satu sumber sebagai subquery dengan kolom HasilPencarian.
+// Pembatasan OPD, urutan, dan jumlah hasil ditambahkan oleh pemanggil.
+func (s sumberPencarian) kueri(q string) *gorm.DB {
+	pola := "%" + escapeLike(q) + "%"
+	args := []interface{}{q}
+
+	// Skor: rank full-text + kemiripan trigram (jika tersedia)
+	skor := fmt.Sprintf("ts_rank(%s, query)", s.vektorFTS())
+	var kondisi []string
+	kondisi = append(kondisi, s.vektorFTS()+" @@ query")
+	for _, k := range s.KolomTrgm {
+		if trigramTersedia {
+			skor += fmt.Sprintf(" + similarity(coalesce(%s, ''), ?)", k)
+			kondisi = append(kondisi, fmt.Sprintf("coalesce(%s, '') %% ?", k))
+		}
+		kondisi = append(kondisi, k+" ILIKE ?")
+	}
+	if s.KolomNIK != "" {
+		kondisi = append(kondisi, s.KolomNIK+" LIKE ?")
+		// Kecocokan potongan NIK diberi bobot tinggi
+		skor += fmt.Sprintf(" + CASE WHEN %s LIKE ? THEN 1 ELSE 0 END", s.KolomNIK)
+	}
+
+	status := "''"
+	if s.KolomStat != "" {
+		status = s.KolomStat
+	}
+
+	sql := fmt.Sprintf(`SELECT '%s' AS tipe, %s AS id, %s AS judul, coalesce(%s, '') AS subjudul, %s AS status,
+		ts_headline('%s'::regconfig, coalesce(%s, ''), query, 'MaxWords=25, MinWords=10') AS cuplikan,
+		id_opd, (%s) AS skor
+		FROM %s, websearch_to_tsquery('%s'::regconfig, ?) AS query
+		WHERE (%s)`,
+		s.Tipe, s.KolomID, s.KolomJudul, s.KolomSub, status,
+		konfigurasiFTS, s.KolomTeks,
+		skor,
+		s.Tabel, konfigurasiFTS,
+		strings.Join(kondisi, " OR "))
+
+	// Susun argumen sesuai urutan placeholder di atas
+	var argsSkor, argsKondisi []interface{}
+	for range s.KolomTrgm {
+		if trigramTersedia {
+			argsSkor = append(argsSkor, q)
+			argsKondisi = append(argsKondisi, q)
+		}
+		argsKondisi = append(argsKondisi, pola)
+	}
+	if s.KolomNIK != "" {
+		argsKondisi = append(argsKondisi, pola)
+		argsSkor = append(argsSkor, pola)
+	}
+	args = append(argsSkor, args...)
+	args = append(args, argsKondisi...)
+
+	if s.Kondisi != "" {
+		sql += " AND " + s.Kondisi
+	}
+	return DB.Table("(?) AS hasil", DB.Raw(sql, args...))
+}
+
+// cakupan membatasi query sumber sesuai hak akses user. ok bernilai false jika user
+// tidak boleh membaca sumber ini sama sekali sehingga sumber harus dilewati.
+func (s sumberPencarian) cakupan(c *gin.Context, query *gorm.DB) (*gorm.DB, bool, error) {
+	claims := ambilClaims(c)
+	if boleh, err := punyaIzin(claims, s.Izin); err != nil || !boleh {
+		return nil, false, err
+	}
+	if !s.BatasOPD {
+		return query, true, nil
+	}
+	query = cakupanOPD(c, query, "id_opd")
+	if s.IzinSemua == "" || opdPengguna(c) != 0 {
+		return query, true, nil
+	}
+	// User Pemda tanpa izin baca seluruh OPD hanya melihat OPD yang tercatat di token-nya
+	semua, err := punyaIzin(claims, s.IzinSemua)
+	if err != nil {
+		return nil, false, err
+	}
+	if semua {
+		return query, true, nil
+	}
+	if claims.IDOPD == 0 {
+		return nil, false, nil
+	}
+	return query.Where("id_opd = ?", claims.IDOPD), true, nil
+}
+
+// Search: GET /api/search?q=&tipe=&limit=
+// Mencari di pengajuan, pemohon, dan standar pelayanan. Hasil diurutkan berdasarkan skor.
+// Sumber yang tidak boleh dibaca user dilewati; user OPD hanya melihat pengajuan dan pemohon
+// milik OPD-nya, dan pengajuan OPD lain hanya tampil bagi pemilik izin pengajuan.read_all.
+func Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if len([]rune(q)) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kata kunci pencarian minimal 2 karakter"})
+		return
+	}
+
+	batas := batasHasilDefault
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > batasHasilMaks {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter limit harus angka 1 - " + strconv.Itoa(batasHasilMaks)})
+			return
+		}
+		batas = n
+	}
+	tipe := c.Query("tipe")
+
+	if opdPengguna(c) == opdTidakDikenal {
+		c.JSON(http.StatusForbidden, gin.H{"error": pesanBedaOPD})
+		return
+	}
+
+	hasil := []HasilPencarian{}
+	for _, sumber := range sumberPencarianList {
+		if tipe != "" && tipe != sumber.Tipe {
+			continue
+		}
+		query, ok, err := sumber.cakupan(c, sumber.kueri(q))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa hak akses"})
+			return
+		}
+		if !ok {
+			continue
+		}
+		var hits []HasilPencarian
+		if err := query.Order("skor DESC").Limit(batas).Scan(&hits).Error; err != nil {
+			log.Println("!!! ERROR PENCARIAN", sumber.Tipe+":", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal melakukan pencarian"})
+			return
+		}
+		hasil = append(hasil, hits...)
+	}
+
+	sort.SliceStable(hasil, func(i, j int) bool { return hasil[i].Skor > hasil[j].Skor })
+	if len(hasil) > batas {
+		hasil = hasil[:batas]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": hasil, "meta": gin.H{"q": q, "total": len(hasil)}})
+}
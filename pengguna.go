@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= MANAJEMEN USER OPD & USER PEMDA =========
+//
+// User tidak pernah dihapus secara fisik: FormPengajuan.IDUserOPD, FormPemohon.IDUserOPDInput,
+// dan JenisPelayanan.IDValidatorPemda tetap menunjuk ke baris aslinya. Hapus hanya mengisi
+// dihapus_pada dan menonaktifkan user. User nonaktif / terhapus tidak dapat login, sesi dan
+// API key-nya dicabut.
+
+// RingkasanUser adalah satu baris pada list GET /api/users (gabungan user OPD dan user Pemda).
+type RingkasanUser struct {
+	Role        string     `json:"role"`
+	ID          uint       `json:"id"`
+	IDAkun      uint       `json:"id_akun"`
+	IDOPD       *uint      `json:"id_opd"`
+	NamaOPD     *string    `json:"nama_opd"`
+	Nama        string     `json:"nama"`
+	NIP         string     `json:"nip"`
+	Jabatan     string     `json:"jabatan"`
+	KodePeran   string     `json:"kode_peran"`
+	Aktif       bool       `json:"aktif"`
+	DihapusPada *time.Time `json:"dihapus_pada"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// kolomSortUser: nilai ?sort= yang diizinkan untuk list user
+var kolomSortUser = map[string]string{
+	"nama":       "nama",
+	"nip":        "nip",
+	"created_at": "created_at",
+}
+
+// queryGabunganUser menggabungkan user_opd dan user_pemda menjadi satu tabel turunan "u".
+func queryGabunganUser() *gorm.DB {
+	gabungan := DB.Raw(`
+		SELECT 'opd' AS role, u.id_user_opd AS id, u.id_akun, u.id_opd, o.nama_opd, u.nama, u.nip, u.jabatan,
+		       u.kode_peran, u.aktif, u.dihapus_pada, u.created_at
+		FROM user_opd u LEFT JOIN opd o ON o.id_opd = u.id_opd
+		UNION ALL
+		SELECT 'pemda', p.id_user_pemda, p.id_akun, NULL, NULL, p.nama, p.nip, p.jabatan,
+		       p.kode_peran, p.aktif, p.dihapus_pada, p.created_at
+		FROM user_pemda p`)
+	return DB.Table("(?) AS u", gabungan)
+}
+
+// GetAllUser: GET /api/users
+// Filter: ?role=opd|pemda&id_opd=&kode_peran=&aktif=true|false&q=&termasuk_dihapus=true
+func GetAllUser(c *gin.Context) {
+	query := queryGabunganUser()
+	if role := c.Query("role"); role != "" {
+		if role != "opd" && role != "pemda" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parameter role harus opd atau pemda"})
+			return
+		}
+		query = query.Where("u.role = ?", role)
+	}
+	if kode := c.Query("kode_peran"); kode != "" {
+		query = query.Where("u.kode_peran = ?", kode)
+	}
+	if s := c.Query("aktif"); s != "" {
+		aktif, err := strconv.ParseBool(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parameter aktif harus true atau false"})
+			return
+		}
+		query = query.Where("u.aktif = ?", aktif)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pola := "%" + escapeLike(q) + "%"
+		query = query.Where("(u.nama ILIKE ? OR u.nip ILIKE ?)", pola, pola)
+	}
+	if c.Query("termasuk_dihapus") != "true" {
+		query = query.Where("u.dihapus_pada IS NULL")
+	}
+	query, err := filterID(c, query, "id_opd", "u.id_opd")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := bindPaginasi(c, kolomSortUser, "nama", "ASC")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	daftar := []RingkasanUser{}
+	meta, err := ambilHalaman(query, p, "u.role, u.id", nil, &daftar)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	responList(c, daftar, meta)
+}
+
+// muatProfilUser memuat UserOPD / UserPemda (termasuk yang sudah dihapus) berdasarkan :role dan :id.
+// Jika gagal, respons error langsung dikirim.
+func muatProfilUser(c *gin.Context) (role string, id uint, user interface{}, ok bool) {
+	role = c.Param("role")
+	id64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID user tidak valid"})
+		return "", 0, nil, false
+	}
+	id = uint(id64)
+
+	switch role {
+	case "opd":
+		var u UserOPD
+		err = DB.Preload("OPD").First(&u, id).Error
+		user = &u
+	case "pemda":
+		var u UserPemda
+		err = DB.First(&u, id).Error
+		user = &u
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role harus opd atau pemda"})
+		return "", 0, nil, false
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return "", 0, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", 0, nil, false
+	}
+	return role, id, user, true
+}
+
+// statusProfil mengembalikan status aktif, waktu hapus, dan kode peran dari profil user.
+func statusProfil(user interface{}) (aktif bool, dihapusPada *time.Time, kodePeran string) {
+	switch u := user.(type) {
+	case *UserOPD:
+		return u.Aktif, u.DihapusPada, u.KodePeran
+	case *UserPemda:
+		return u.Aktif, u.DihapusPada, u.KodePeran
+	}
+	return false, nil, ""
+}
+
+// GetUserByID: GET /api/users/:role/:id
+func GetUserByID(c *gin.Context) {
+	_, _, user, ok := muatProfilUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUser: PUT /api/users/:role/:id, mengubah nama / jabatan, atau memindahkan user OPD ke OPD lain.
+// Pindah OPD mencabut sesi dan API key user karena cakupan datanya berubah.
+func UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+	role, id, user, ok := muatProfilUser(c)
+	if !ok {
+		return
+	}
+	if _, dihapusPada, _ := statusProfil(user); dihapusPada != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User sudah dihapus"})
+		return
+	}
+
+	perubahan := map[string]interface{}{}
+	if req.Nama != nil {
+		if strings.TrimSpace(*req.Nama) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nama tidak boleh kosong"})
+			return
+		}
+		perubahan["nama"] = strings.TrimSpace(*req.Nama)
+	}
+	if req.Jabatan != nil {
+		perubahan["jabatan"] = *req.Jabatan
+	}
+	pindahOPD := false
+	if req.IDOPD != nil {
+		u, isOPD := user.(*UserOPD)
+		if !isOPD {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id_opd hanya dapat diubah untuk user OPD"})
+			return
+		}
+		var opd OPD
+		if err := DB.First(&opd, *req.IDOPD).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID OPD tidak valid"})
+			return
+		}
+		if u.IDOPD != *req.IDOPD {
+			perubahan["id_opd"] = *req.IDOPD
+			pindahOPD = true
+		}
+	}
+	if len(perubahan) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada data yang diubah"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(perubahan).Error; err != nil {
+			return err
+		}
+		keterangan := "Data user diubah"
+		if pindahOPD {
+			keterangan = fmt.Sprintf("User dipindahkan ke OPD %d", *req.IDOPD)
+			if err := cabutAksesProfil(tx, role, id, "User dipindahkan ke OPD lain"); err != nil {
+				return err
+			}
+		}
+		return catatAudit(tx, c, "user.ubah", targetUser(role, id), keterangan)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan data user"})
+		return
+	}
+
+	_, _, user, _ = muatProfilUser(c)
+	c.JSON(http.StatusOK, user)
+}
+
+// cabutAksesProfil mencabut semua sesi yang sedang memakai profil ini dan semua API key-nya.
+func cabutAksesProfil(tx *gorm.DB, role string, id uint, alasan string) error {
+	var daftar []Sesi
+	if err := tx.Where("role = ? AND id_user = ? AND dicabut_pada IS NULL", role, id).Find(&daftar).Error; err != nil {
+		return err
+	}
+	for i := range daftar {
+		if err := cabutSesi(tx, &daftar[i], alasan); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&APIKey{}).Where("role = ? AND id_user = ? AND dicabut_pada IS NULL", role, id).
+		Update("dicabut_pada", time.Now()).Error
+}
+
+// cekBolehNonaktifkan mencegah admin menonaktifkan dirinya sendiri atau superadmin aktif terakhir.
+func cekBolehNonaktifkan(tx *gorm.DB, c *gin.Context, role string, id uint, kodePeran string) error {
+	if claims := ambilClaims(c); claims.Role == role && claims.ID == id {
+		return errParameter("Anda tidak dapat menonaktifkan atau menghapus user Anda sendiri")
+	}
+	if role == "pemda" && kodePeran == PeranSuperadmin {
+		var jumlah int64
+		tx.Model(&UserPemda{}).Where("kode_peran = ?", PeranSuperadmin).Where(kondisiProfilAktif).Count(&jumlah)
+		if jumlah <= 1 {
+			return errParameter("Tidak dapat menonaktifkan superadmin terakhir")
+		}
+	}
+	return nil
+}
+
+// NonaktifkanUser: POST /api/users/:role/:id/deactivate
+func NonaktifkanUser(c *gin.Context) {
+	role, id, user, ok := muatProfilUser(c)
+	if !ok {
+		return
+	}
+	aktif, dihapusPada, kodePeran := statusProfil(user)
+	if dihapusPada != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User sudah dihapus"})
+		return
+	}
+	if !aktif {
+		c.JSON(http.StatusConflict, gin.H{"error": "User sudah nonaktif"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := cekBolehNonaktifkan(tx, c, role, id, kodePeran); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("aktif", false).Error; err != nil {
+			return err
+		}
+		if err := cabutAksesProfil(tx, role, id, "User dinonaktifkan"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "user.nonaktifkan", targetUser(role, id), "User dinonaktifkan")
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "User berhasil dinonaktifkan"})
+}
+
+// AktifkanUser: POST /api/users/:role/:id/activate
+func AktifkanUser(c *gin.Context) {
+	role, id, user, ok := muatProfilUser(c)
+	if !ok {
+		return
+	}
+	aktif, dihapusPada, _ := statusProfil(user)
+	if dihapusPada != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User sudah dihapus dan tidak dapat diaktifkan kembali"})
+		return
+	}
+	if aktif {
+		c.JSON(http.StatusConflict, gin.H{"error": "User sudah aktif"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("aktif", true).Error; err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "user.aktifkan", targetUser(role, id), "User diaktifkan kembali")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengaktifkan user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "User berhasil diaktifkan"})
+}
+
+// DeleteUser: DELETE /api/users/:role/:id (soft delete)
+// Baris user tetap disimpan agar pengajuan dan validasi lama tetap menunjuk ke petugasnya.
+func DeleteUser(c *gin.Context) {
+	role, id, user, ok := muatProfilUser(c)
+	if !ok {
+		return
+	}
+	_, dihapusPada, kodePeran := statusProfil(user)
+	if dihapusPada != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User sudah dihapus"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := cekBolehNonaktifkan(tx, c, role, id, kodePeran); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{"aktif": false, "dihapus_pada": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := cabutAksesProfil(tx, role, id, "User dihapus"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "user.hapus", targetUser(role, id), "User dihapus (soft delete)")
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "User berhasil dihapus"})
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= PERSYARATAN TERSTRUKTUR =========
+//
+// Setiap versi standar memiliki daftar ItemPersyaratan (nama, wajib, tipe file, ukuran maks).
+// Saat pengajuan dibuat, item dari versi yang berlaku disalin menjadi slot PersyaratanPengajuan.
+// Petugas OPD memeriksa setiap slot; pengajuan baru dapat Diverifikasi jika seluruh
+// item wajib sudah Terverifikasi. Kolom teks Persyaratan tetap diisi untuk tampilan lama.
+
+// Status pemeriksaan slot persyaratan pengajuan.
+const (
+	StatusVerifBelum         = "Belum Diperiksa"
+	StatusVerifTerverifikasi = "Terverifikasi"
+	StatusVerifTidakSesuai   = "Tidak Sesuai"
+)
+
+// batasUnggahByte adalah batas ukuran dokumen jika item tidak menentukan ukuran maks.
+const batasUnggahByte = 10 << 20 // 10 MB
+
+// polaNomorPersyaratan mencocokkan penomoran/bullet di awal baris teks persyaratan ("1.", "2)", "-", "•").
+var polaNomorPersyaratan = regexp.MustCompile(`^\s*(\d+[.)]|[-*•])\s*`)
+
+// parsePersyaratanTeks memecah teks persyaratan lama menjadi item (satu baris satu item, semua wajib).
+func parsePersyaratanTeks(teks string) []ItemPersyaratan {
+	var hasil []ItemPersyaratan
+	for _, baris := range strings.Split(teks, "\n") {
+		nama := strings.TrimSpace(polaNomorPersyaratan.ReplaceAllString(baris, ""))
+		if nama == "" {
+			continue
+		}
+		hasil = append(hasil, ItemPersyaratan{Urutan: len(hasil) + 1, Nama: nama, Wajib: true})
+	}
+	return hasil
+}
+
+// teksPersyaratan menyusun teks bernomor dari item, untuk kolom Persyaratan.
+func teksPersyaratan(items []ItemPersyaratan) string {
+	baris := make([]string, len(items))
+	for i, item := range items {
+		baris[i] = strconv.Itoa(i+1) + ". " + item.Nama
+		if !item.Wajib {
+			baris[i] += " (opsional)"
+		}
+	}
+	return strings.Join(baris, "\n")
+}
+
+// normalisasiDaftarPersyaratan memvalidasi item dari request: nama wajib & unik, tipe file
+// berupa ekstensi tanpa titik (huruf kecil), ukuran maks tidak negatif. Urutan mengikuti posisi.
+func normalisasiDaftarPersyaratan(items []ItemPersyaratan) ([]ItemPersyaratan, error) {
+	hasil := make([]ItemPersyaratan, 0, len(items))
+	sudahAda := map[string]bool{}
+	for i, item := range items {
+		item.Nama = strings.TrimSpace(item.Nama)
+		if item.Nama == "" {
+			return nil, errors.New("Nama persyaratan ke-" + strconv.Itoa(i+1) + " tidak boleh kosong")
+		}
+		kunci := strings.ToLower(item.Nama)
+		if sudahAda[kunci] {
+			return nil, errors.New("Persyaratan '" + item.Nama + "' tercantum lebih dari sekali")
+		}
+		sudahAda[kunci] = true
+		if item.UkuranMaksKB < 0 {
+			return nil, errors.New("Ukuran maksimal persyaratan '" + item.Nama + "' tidak boleh negatif")
+		}
+
+		var tipe []string
+		for _, t := range strings.Split(item.TipeFile, ",") {
+			if t = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(t)), "."); t != "" {
+				tipe = append(tipe, t)
+			}
+		}
+		item.TipeFile = strings.Join(tipe, ",")
+		item.Keterangan = strings.TrimSpace(item.Keterangan)
+		item.ID, item.IDVersiStandar, item.Urutan = 0, 0, i+1
+		hasil = append(hasil, item)
+	}
+	return hasil, nil
+}
+
+// salinItemPersyaratan menyalin item untuk versi lain (ID dikosongkan agar dibuat baris baru).
+func salinItemPersyaratan(items []ItemPersyaratan) []ItemPersyaratan {
+	hasil := make([]ItemPersyaratan, len(items))
+	for i, item := range items {
+		item.ID, item.IDVersiStandar = 0, 0
+		hasil[i] = item
+	}
+	return hasil
+}
+
+// PerbedaanPersyaratan adalah perubahan daftar persyaratan antara dua versi (dicocokkan berdasarkan nama).
+type PerbedaanPersyaratan struct {
+	Ditambah []ItemPersyaratan `json:"ditambah"`
+	Dihapus  []ItemPersyaratan `json:"dihapus"`
+	Diubah   []PerbedaanField  `json:"diubah"` // Field berformat "<nama item>.<field>"
+}
+
+// kosong bernilai true jika kedua daftar identik.
+func (p PerbedaanPersyaratan) kosong() bool {
+	return len(p.Ditambah) == 0 && len(p.Dihapus) == 0 && len(p.Diubah) == 0
+}
+
+// bandingkanPersyaratan membandingkan dua daftar item persyaratan.
+func bandingkanPersyaratan(dari, ke []ItemPersyaratan) PerbedaanPersyaratan {
+	hasil := PerbedaanPersyaratan{Ditambah: []ItemPersyaratan{}, Dihapus: []ItemPersyaratan{}, Diubah: []PerbedaanField{}}
+	lama := make(map[string]ItemPersyaratan, len(dari))
+	for _, item := range dari {
+		lama[item.Nama] = item
+	}
+	for _, b := range ke {
+		a, ada := lama[b.Nama]
+		if !ada {
+			hasil.Ditambah = append(hasil.Ditambah, b)
+			continue
+		}
+		delete(lama, b.Nama)
+		for _, f := range []PerbedaanField{
+			{Field: "urutan", Dari: a.Urutan, Ke: b.Urutan},
+			{Field: "wajib", Dari: a.Wajib, Ke: b.Wajib},
+			{Field: "tipe_file", Dari: a.TipeFile, Ke: b.TipeFile},
+			{Field: "ukuran_maks_kb", Dari: a.UkuranMaksKB, Ke: b.UkuranMaksKB},
+			{Field: "keterangan", Dari: a.Keterangan, Ke: b.Keterangan},
+		} {
+			if f.Dari != f.Ke {
+				f.Field = b.Nama + "." + f.Field
+				hasil.Diubah = append(hasil.Diubah, f)
+			}
+		}
+	}
+	for _, a := range dari {
+		if _, sisa := lama[a.Nama]; sisa {
+			hasil.Dihapus = append(hasil.Dihapus, a)
+		}
+	}
+	return hasil
+}
+
+// itemPersyaratanVersi mengambil item persyaratan sebuah versi sesuai urutan.
+func itemPersyaratanVersi(tx *gorm.DB, idVersi uint) ([]ItemPersyaratan, error) {
+	var items []ItemPersyaratan
+	err := tx.Where("id_versi_standar = ?", idVersi).Order("urutan").Find(&items).Error
+	return items, err
+}
+
+// gantiItemPersyaratan mengganti seluruh item persyaratan milik versi.
+func gantiItemPersyaratan(tx *gorm.DB, idVersi uint, items []ItemPersyaratan) error {
+	if err := tx.Where("id_versi_standar = ?", idVersi).Delete(&ItemPersyaratan{}).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].IDVersiStandar = idVersi
+	}
+	return tx.Create(&items).Error
+}
+
+// isiDaftarPersyaratan mengisi DaftarPersyaratan setiap standar dari versi yang berlaku (satu query).
+func isiDaftarPersyaratan(tx *gorm.DB, standar []JenisPelayanan) error {
+	if len(standar) == 0 {
+		return nil
+	}
+	var baris []struct {
+		IDJenisPelayanan uint
+		ItemPersyaratan
+	}
+	err := tx.Table("item_persyaratan i").
+		Select("v.id_jenis_pelayanan, i.*").
+		Joins("JOIN versi_standar v ON v.id_versi_standar = i.id_versi_standar").
+		Joins("JOIN jenis_pelayanan j ON j.id_jenis_pelayanan = v.id_jenis_pelayanan").
+		Where("j.id_jenis_pelayanan IN ?", idStandar(standar)).
+		Where("v.nomor = CASE WHEN j.versi_aktif > 0 THEN j.versi_aktif ELSE j.versi_terbaru END").
+		Order("i.urutan").Scan(&baris).Error
+	if err != nil {
+		return err
+	}
+	perStandar := map[uint][]ItemPersyaratan{}
+	for _, b := range baris {
+		perStandar[b.IDJenisPelayanan] = append(perStandar[b.IDJenisPelayanan], b.ItemPersyaratan)
+	}
+	for i := range standar {
+		standar[i].DaftarPersyaratan = perStandar[standar[i].ID]
+	}
+	return nil
+}
+
+// idStandar mengambil ID dari daftar standar.
+func idStandar(standar []JenisPelayanan) []uint {
+	ids := make([]uint, len(standar))
+	for i, s := range standar {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+// buatSlotPersyaratan menyalin item persyaratan versi standar menjadi slot dokumen pengajuan.
+func buatSlotPersyaratan(tx *gorm.DB, idForm uint, idVersi *uint) error {
+	if idVersi == nil {
+		return nil
+	}
+	items, err := itemPersyaratanVersi(tx, *idVersi)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	slot := make([]PersyaratanPengajuan, len(items))
+	for i, item := range items {
+		idItem := item.ID
+		slot[i] = PersyaratanPengajuan{
+			IDFormPengajuan:   idForm,
+			IDItemPersyaratan: &idItem,
+			Urutan:            item.Urutan,
+			Nama:              item.Nama,
+			Wajib:             item.Wajib,
+			TipeFile:          item.TipeFile,
+			UkuranMaksKB:      item.UkuranMaksKB,
+			StatusVerifikasi:  StatusVerifBelum,
+		}
+	}
+	return tx.Create(&slot).Error
+}
+
+// gantiSlotPersyaratan membuat ulang slot pengajuan, mis. setelah jenis pelayanan diganti.
+func gantiSlotPersyaratan(tx *gorm.DB, idForm uint, idVersi *uint) error {
+	if err := tx.Where("id_form_pengajuan = ?", idForm).Delete(&PersyaratanPengajuan{}).Error; err != nil {
+		return err
+	}
+	return buatSlotPersyaratan(tx, idForm, idVersi)
+}
+
+// persyaratanBelumTerpenuhi mengembalikan nama item wajib yang belum Terverifikasi.
+func persyaratanBelumTerpenuhi(tx *gorm.DB, idForm uint) ([]string, error) {
+	var nama []string
+	err := tx.Model(&PersyaratanPengajuan{}).
+		Where("id_form_pengajuan = ? AND wajib = true AND status_verifikasi <> ?", idForm, StatusVerifTerverifikasi).
+		Order("urutan").Pluck("nama", &nama).Error
+	return nama, err
+}
+
+// migrasiPersyaratan membuat item dari teks Persyaratan untuk versi lama, lalu slot untuk pengajuan lama.
+func migrasiPersyaratan() {
+	var versi []VersiStandar
+	DB.Where("NOT EXISTS (SELECT 1 FROM item_persyaratan i WHERE i.id_versi_standar = versi_standar.id_versi_standar)").
+		Find(&versi)
+	for _, v := range versi {
+		items := parsePersyaratanTeks(v.Persyaratan)
+		if len(items) == 0 {
+			continue
+		}
+		if err := gantiItemPersyaratan(DB, v.ID, items); err != nil {
+			log.Println("⚠ Gagal membuat item persyaratan versi", v.ID, ":", err)
+		}
+	}
+
+	var form []FormPengajuan
+	DB.Where("id_versi_standar IS NOT NULL").Where(kondisiPengajuanAktif).
+		Where("NOT EXISTS (SELECT 1 FROM persyaratan_pengajuan p WHERE p.id_form_pengajuan = form_pengajuan.id_form_pengajuan)").
+		Find(&form)
+	for _, f := range form {
+		if err := buatSlotPersyaratan(DB, f.ID, f.IDVersiStandar); err != nil {
+			log.Println("⚠ Gagal membuat slot persyaratan pengajuan", f.ID, ":", err)
+		}
+	}
+}
+
+// muatPengajuanMilik mengambil pengajuan dari :id dan memastikan user OPD hanya mengakses milik OPD-nya.
+// Jika gagal, respons error sudah dikirim dan fungsi mengembalikan false.
+func muatPengajuanMilik(c *gin.Context, form *FormPengajuan) bool {
+	if err := DB.Where(kondisiPengajuanAktif).First(form, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data pengajuan tidak ditemukan"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return cekMilikOPD(c, form.IDOPD)
+}
+
+// muatSlotPersyaratan mengambil slot :id_slot milik pengajuan. Jika gagal, respons error sudah dikirim.
+func muatSlotPersyaratan(c *gin.Context, idForm uint, slot *PersyaratanPengajuan) bool {
+	if err := DB.Where("id_persyaratan_pengajuan = ? AND id_form_pengajuan = ?", c.Param("id_slot"), idForm).
+		First(slot).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Persyaratan pengajuan tidak ditemukan"})
+		return false
+	}
+	return true
+}
+
+// GetPersyaratanPengajuan: GET /api/pengajuan/:id/persyaratan
+// Checklist dokumen pengajuan beserta daftar item wajib yang belum terpenuhi.
+func GetPersyaratanPengajuan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) {
+		return
+	}
+
+	var slot []PersyaratanPengajuan
+	if err := DB.Where("id_form_pengajuan = ?", form.ID).Order("urutan").Find(&slot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil persyaratan pengajuan"})
+		return
+	}
+	belum, err := persyaratanBelumTerpenuhi(DB, form.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil persyaratan pengajuan"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": slot, "belum_terpenuhi": belum, "lengkap": len(belum) == 0})
+}
+
+// batasBerkasPersyaratan memeriksa tipe file sesuai item dan mengembalikan batas ukurannya.
+func batasBerkasPersyaratan(slot *PersyaratanPengajuan, berkas *multipart.FileHeader) (int64, error) {
+	if slot.TipeFile != "" {
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(berkas.Filename)), ".")
+		if !mengandung(strings.Split(slot.TipeFile, ","), ext) {
+			return 0, errors.New("Tipe file untuk '" + slot.Nama + "' harus salah satu dari: " + slot.TipeFile)
+		}
+	}
+	if slot.UkuranMaksKB > 0 {
+		return int64(slot.UkuranMaksKB) * 1024, nil
+	}
+	return batasUnggahByte, nil
+}
+
+// UnggahDokumenPersyaratan: POST /api/pengajuan/:id/persyaratan/:id_slot/dokumen (multipart, field "dokumen")
+// Tipe & ukuran file diperiksa sesuai item. Dokumen disimpan sebagai lampiran versi baru
+// dan slot kembali ke Belum Diperiksa.
+func UnggahDokumenPersyaratan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) || !cekPengajuanDapatDiubah(c, &form) {
+		return
+	}
+	var slot PersyaratanPengajuan
+	if !muatSlotPersyaratan(c, form.ID, &slot) {
+		return
+	}
+
+	berkas, err := c.FormFile("dokumen")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File dokumen wajib diunggah (field 'dokumen')"})
+		return
+	}
+	batas, err := batasBerkasPersyaratan(&slot, berkas)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pendahulu, err := cariLampiranTunggal(DB, form.ID, JenisLampiranPersyaratan, &slot)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if unggahLampiran(c, &form, berkas, batas, JenisLampiranPersyaratan, &slot, pendahulu) == nil {
+		return
+	}
+	c.JSON(http.StatusOK, slot)
+}
+
+// VerifikasiPersyaratan: PUT /api/pengajuan/:id/persyaratan/:id_slot/verifikasi (petugas OPD)
+// Body: {"status": "Terverifikasi" | "Tidak Sesuai" | "Belum Diperiksa", "catatan": "..."}.
+// Tidak Sesuai wajib disertai catatan agar pemohon tahu yang harus diperbaiki.
+func VerifikasiPersyaratan(c *gin.Context) {
+	var form FormPengajuan
+	if !muatPengajuanMilik(c, &form) {
+		return
+	}
+	if !statusDapatDiubah(form.StatusProses) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Persyaratan tidak dapat diperiksa pada pengajuan berstatus '" + form.StatusProses + "'"})
+		return
+	}
+	var slot PersyaratanPengajuan
+	if !muatSlotPersyaratan(c, form.ID, &slot) {
+		return
+	}
+
+	var req VerifikasiPersyaratanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+	req.Catatan = strings.TrimSpace(req.Catatan)
+	switch req.Status {
+	case StatusVerifTerverifikasi:
+		if slot.DokumenPath == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dokumen '" + slot.Nama + "' belum diunggah"})
+			return
+		}
+	case StatusVerifTidakSesuai:
+		if req.Catatan == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Catatan wajib diisi untuk status '" + StatusVerifTidakSesuai + "'"})
+			return
+		}
+	case StatusVerifBelum:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status harus '" + StatusVerifTerverifikasi + "', '" + StatusVerifTidakSesuai + "', atau '" + StatusVerifBelum + "'"})
+		return
+	}
+
+	claims := ambilClaims(c)
+	now := time.Now()
+	perubahan := map[string]interface{}{
+		"status_verifikasi":  req.Status,
+		"catatan_verifikasi": req.Catatan,
+		"id_verifikator":     &claims.ID,
+		"diverifikasi_pada":  &now,
+	}
+	if req.Status == StatusVerifBelum {
+		perubahan["id_verifikator"], perubahan["diverifikasi_pada"] = nil, nil
+	}
+	// Hanya berlaku untuk dokumen yang diperiksa: jika dokumen diganti di antara pemuatan slot
+	// dan penyimpanan, hasil pemeriksaan tidak boleh menempel ke dokumen baru.
+	query := DB.Model(&PersyaratanPengajuan{}).Where("id_persyaratan_pengajuan = ?", slot.ID)
+	if slot.DokumenPath == nil {
+		query = query.Where("dokumen_path IS NULL")
+	} else {
+		query = query.Where("dokumen_path = ?", *slot.DokumenPath)
+	}
+	hasil := query.Updates(perubahan)
+	if hasil.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan hasil pemeriksaan"})
+		return
+	}
+	if hasil.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dokumen '" + slot.Nama + "' baru saja diganti; muat ulang dan periksa dokumen terbaru"})
+		return
+	}
+	if err := DB.First(&slot, slot.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, slot)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= PROFIL USER YANG SEDANG LOGIN (/api/me) =========
+
+// GetProfilSaya: GET /api/me
+// Data user dari Claims dilengkapi dari database, agar frontend dapat memulihkan
+// state setelah halaman dimuat ulang tanpa login ulang.
+func GetProfilSaya(c *gin.Context) {
+	claims := ambilClaims(c)
+
+	peran, idAkun, err := peranUntukUser(claims.Role, claims.ID)
+	if err != nil || idAkun != claims.IDAkun || !peran.Aktif {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak ditemukan atau sudah dinonaktifkan"})
+		return
+	}
+	var akun Akun
+	if err := DB.First(&akun, claims.IDAkun).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Akun tidak ditemukan"})
+		return
+	}
+	daftar, err := daftarPeranAkun(akun.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat peran akun"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":  dataUser(claims, peran),
+		"peran": daftar,
+		"akun": gin.H{
+			"totp_aktif":           akun.TOTPAktif,
+			"wajib_aktifkan_2fa":   claims.Terbatas,
+			"wajib_ganti_password": akun.WajibGantiPassword,
+			"terakhir_login_pada":  akun.TerakhirLoginPada,
+			"terakhir_login_ip":    akun.TerakhirLoginIP,
+		},
+		"sesi": gin.H{
+			"id_sesi":          claims.IDSesi,
+			"kedaluwarsa_pada": claims.ExpiresAt.Time, // Access token; perpanjang lewat POST /api/refresh
+		},
+	})
+}
+
+// UpdateProfilSaya: PUT /api/me, mengubah nama dan jabatan pada profil peran aktif.
+// OPD, NIP, dan peran hanya dapat diubah admin lewat /api/users.
+func UpdateProfilSaya(c *gin.Context) {
+	var req UpdateProfilSayaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+	claims := ambilClaims(c)
+
+	perubahan := map[string]interface{}{}
+	if req.Nama != nil {
+		nama := strings.TrimSpace(*req.Nama)
+		if nama == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nama tidak boleh kosong"})
+			return
+		}
+		perubahan["nama"] = nama
+	}
+	if req.Jabatan != nil {
+		perubahan["jabatan"] = strings.TrimSpace(*req.Jabatan)
+	}
+	if len(perubahan) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada data yang diubah"})
+		return
+	}
+
+	var model interface{} = &UserOPD{}
+	if claims.Role == "pemda" {
+		model = &UserPemda{}
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(model).Where(kolomIDProfil(claims.Role)+" = ?", claims.ID).Updates(perubahan).Error; err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "user.ubah_profil", targetUser(claims.Role, claims.ID), "Profil diubah oleh pemilik akun")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan profil"})
+		return
+	}
+
+	// Nama & jabatan ada di access token; terbitkan ulang agar langsung berlaku
+	accessToken, err := terbitkanUlangAksesSesi(c, claims)
+	if err != nil {
+		log.Println("[PROFIL] Gagal menerbitkan ulang access token:", err)
+	}
+
+	peran, _, err := peranUntukUser(claims.Role, claims.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat profil"})
+		return
+	}
+	c.JSON(http.StatusOK, tambahTokenAkses(c, gin.H{"success": true, "user": dataUser(claims, peran)}, accessToken))
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ========= RBAC: PERAN, IZIN & MIDDLEWARE RequirePermission =========
+
+// Kode peran bawaan.
+const (
+	PeranSuperadmin  = "superadmin"
+	PeranValidator   = "validator"
+	PeranAuditor     = "auditor"
+	PeranKepalaOPD   = "kepala_opd"
+	PeranOperatorOPD = "operator_opd"
+)
+
+// izinSemua adalah izin wildcard yang mencakup seluruh izin di katalog.
+const izinSemua = "*"
+
+// definisiIzin mendeskripsikan satu izin. Profil tidak kosong berarti izin hanya
+// bermakna untuk user dengan profil tersebut (mis. "opd": handler memakai OPD dari token).
+type definisiIzin struct {
+	Kode      string `json:"kode"`
+	Deskripsi string `json:"deskripsi"`
+	Profil    string `json:"profil,omitempty"`
+}
+
+// katalogIzin adalah daftar seluruh izin yang dikenal aplikasi.
+var katalogIzin = []definisiIzin{
+	{Kode: "opd.read", Deskripsi: "Melihat daftar OPD"},
+	{Kode: "opd.create", Deskripsi: "Menambah OPD"},
+	{Kode: "user.create", Deskripsi: "Mendaftarkan user OPD / Pemda"},
+	{Kode: "user.read", Deskripsi: "Melihat daftar dan detail user"},
+	{Kode: "user.update", Deskripsi: "Mengubah data user dan memindahkan user OPD ke OPD lain"},
+	{Kode: "user.delete", Deskripsi: "Menonaktifkan, mengaktifkan kembali, dan menghapus user"},
+	{Kode: "user.reset_password", Deskripsi: "Menerbitkan token reset password user"},
+	{Kode: "user.peran", Deskripsi: "Mengubah peran user"},
+	{Kode: "sesi.manage", Deskripsi: "Melihat dan mencabut sesi login user"},
+	{Kode: "api_key.manage", Deskripsi: "Menerbitkan dan mencabut API key akun layanan", Profil: "pemda"},
+	{Kode: "peran.read", Deskripsi: "Melihat daftar peran dan izin"},
+	{Kode: "peran.manage", Deskripsi: "Membuat peran dan mengatur izin peran"},
+	{Kode: "audit.read", Deskripsi: "Melihat log audit"},
+	{Kode: "login_attempt.read", Deskripsi: "Melihat log percobaan login"},
+	{Kode: "login_attempt.unlock", Deskripsi: "Membuka penguncian login"},
+	{Kode: "hari_libur.read", Deskripsi: "Melihat kalender hari libur"},
+	{Kode: "hari_libur.manage", Deskripsi: "Mengelola dan mengimpor hari libur"},
+	{Kode: "standar.read", Deskripsi: "Melihat standar pelayanan per OPD"},
+	{Kode: "standar.create", Deskripsi: "Membuat standar pelayanan OPD", Profil: "opd"},
+	{Kode: "standar.validate", Deskripsi: "Memvalidasi standar pelayanan", Profil: "pemda"},
+	{Kode: "standar.delete", Deskripsi: "Mengarsipkan dan memulihkan standar pelayanan"},
+	{Kode: "pengajuan.read_all", Deskripsi: "Melihat seluruh pengajuan semua OPD"},
+	{Kode: "pengajuan.read", Deskripsi: "Melihat detail dan riwayat pengajuan"},
+	{Kode: "pengajuan.create", Deskripsi: "Membuat pengajuan", Profil: "opd"},
+	{Kode: "pengajuan.update", Deskripsi: "Mengubah pengajuan", Profil: "opd"},
+	{Kode: "pengajuan.delete", Deskripsi: "Menghapus pengajuan", Profil: "opd"},
+	{Kode: "pengajuan.transition", Deskripsi: "Mengubah status proses pengajuan"},
+	{Kode: "pengajuan.verifikasi", Deskripsi: "Memeriksa dokumen persyaratan pengajuan", Profil: "opd"},
+	{Kode: "pemohon.read", Deskripsi: "Melihat data pemohon OPD", Profil: "opd"},
+	{Kode: "pemohon.write", Deskripsi: "Menambah, mengubah, dan menghapus data pemohon OPD", Profil: "opd"},
+}
+
+// cariDefinisiIzin mencari izin di katalog.
+func cariDefinisiIzin(kode string) (definisiIzin, bool) {
+	for _, d := range katalogIzin {
+		if d.Kode == kode {
+			return d, true
+		}
+	}
+	return definisiIzin{}, false
+}
+
+// peranBawaan adalah peran dan izin awal yang dibuat saat startup jika belum ada.
+var peranBawaan = []struct {
+	Peran Peran
+	Izin  []string
+}{
+	{Peran{Kode: PeranSuperadmin, Nama: "Super Admin", Jenis: "pemda", Deskripsi: "Akses penuh ke seluruh fitur"}, []string{izinSemua}},
+	{Peran{Kode: PeranValidator, Nama: "Validator", Jenis: "pemda", Deskripsi: "Memvalidasi standar pelayanan dan memproses pengajuan"}, []string{
+		"opd.read", "hari_libur.read", "standar.read", "standar.validate",
+		"pengajuan.read_all", "pengajuan.read", "pengajuan.transition",
+	}},
+	{Peran{Kode: PeranAuditor, Nama: "Auditor", Jenis: "pemda", Deskripsi: "Akses baca saja untuk keperluan pemeriksaan"}, []string{
+		"opd.read", "hari_libur.read", "standar.read", "pengajuan.read_all", "pengajuan.read",
+		"audit.read", "login_attempt.read", "peran.read", "user.read",
+	}},
+	{Peran{Kode: PeranKepalaOPD, Nama: "Kepala OPD", Jenis: "opd", Deskripsi: "Pimpinan OPD: seluruh fitur OPD termasuk standar dan penghapusan pengajuan"}, []string{
+		"standar.read", "standar.create", "standar.delete", "pengajuan.read", "pengajuan.create", "pengajuan.update",
+		"pengajuan.delete", "pengajuan.transition", "pengajuan.verifikasi", "pemohon.read", "pemohon.write", "hari_libur.read",
+	}},
+	{Peran{Kode: PeranOperatorOPD, Nama: "Operator OPD", Jenis: "opd", Deskripsi: "Petugas input pengajuan dan data pemohon"}, []string{
+		"standar.read", "pengajuan.read", "pengajuan.create", "pengajuan.update",
+		"pengajuan.transition", "pengajuan.verifikasi", "pemohon.read", "pemohon.write", "hari_libur.read",
+	}},
+}
+
+// tambahanIzinBawaan adalah izin yang ditambahkan ke peranBawaan setelah peran tersebut
+// mungkin sudah dibuat di database. siapkanPeran hanya mengisi izin saat peran pertama kali
+// dibuat, sehingga tambahan ini diberikan sekali lewat migrasiIzinBawaan; izin yang kemudian
+// dicabut admin tidak diberikan lagi.
+var tambahanIzinBawaan = []struct {
+	Kode  string // Penanda unik di tabel migrasi_izin_peran
+	Izin  string
+	Peran []string
+}{
+	{"user.read-auditor", "user.read", []string{PeranAuditor}},
+	{"standar.delete-kepala_opd", "standar.delete", []string{PeranKepalaOPD}},
+	{"pengajuan.verifikasi-opd", "pengajuan.verifikasi", []string{PeranKepalaOPD, PeranOperatorOPD}},
+	{"hari_libur.read-opd", "hari_libur.read", []string{PeranKepalaOPD, PeranOperatorOPD}},
+}
+
+// kolomPeranBelumAda dicek sebelum AutoMigrate: true jika tabel profil sudah ada
+// tetapi belum memiliki kolom kode_peran (database dari versi sebelum RBAC).
+func kolomPeranBelumAda() (opd, pemda bool) {
+	m := DB.Migrator()
+	opd = m.HasTable(&UserOPD{}) && !m.HasColumn(&UserOPD{}, "kode_peran")
+	pemda = m.HasTable(&UserPemda{}) && !m.HasColumn(&UserPemda{}, "kode_peran")
+	return opd, pemda
+}
+
+// siapkanPeran membuat peran bawaan beserta izinnya (hanya saat peran pertama kali dibuat,
+// agar perubahan izin oleh admin tidak tertimpa). User yang sudah ada sebelum RBAC
+// mendapat peran penuh sesuai jenisnya agar hak aksesnya tidak berkurang.
+func siapkanPeran(userOPDLama, userPemdaLama bool) {
+	for _, b := range peranBawaan {
+		peran := b.Peran
+		hasil := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&peran)
+		if hasil.Error != nil {
+			log.Fatal("❌ Gagal menyiapkan peran "+peran.Kode+": ", hasil.Error)
+		}
+		if hasil.RowsAffected == 0 {
+			continue
+		}
+		if err := simpanIzinPeran(DB, peran.Kode, b.Izin); err != nil {
+			log.Fatal("❌ Gagal menyiapkan izin peran "+peran.Kode+": ", err)
+		}
+	}
+
+	migrasiIzinBawaan()
+
+	if userOPDLama {
+		DB.Model(&UserOPD{}).Where("1 = 1").Update("kode_peran", PeranKepalaOPD)
+		log.Println("✅ User OPD lama diberi peran", PeranKepalaOPD)
+	}
+	if userPemdaLama {
+		DB.Model(&UserPemda{}).Where("1 = 1").Update("kode_peran", PeranSuperadmin)
+		log.Println("✅ User Pemda lama diberi peran", PeranSuperadmin)
+	}
+	izinCache.reset()
+}
+
+// migrasiIzinBawaan memberikan setiap entri tambahanIzinBawaan yang belum tercatat ke peran
+// bawaan yang sudah ada. Peran yang tidak ada, peran dengan izin "*", dan peran yang sudah
+// memiliki izin tersebut dilewati.
+func migrasiIzinBawaan() {
+	for _, t := range tambahanIzinBawaan {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			hasil := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&MigrasiIzinPeran{Kode: t.Kode})
+			if hasil.Error != nil || hasil.RowsAffected == 0 {
+				return hasil.Error
+			}
+			for _, kode := range t.Peran {
+				var peran, semua int64
+				tx.Model(&Peran{}).Where("kode = ?", kode).Count(&peran)
+				tx.Model(&IzinPeran{}).Where("kode_peran = ? AND izin = ?", kode, izinSemua).Count(&semua)
+				if peran == 0 || semua > 0 {
+					continue
+				}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&IzinPeran{KodePeran: kode, Izin: t.Izin}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal("❌ Gagal menambahkan izin bawaan "+t.Kode+": ", err)
+		}
+	}
+}
+
+// simpanIzinPeran mengganti seluruh izin milik peran.
+func simpanIzinPeran(tx *gorm.DB, kodePeran string, izin []string) error {
+	if err := tx.Where("kode_peran = ?", kodePeran).Delete(&IzinPeran{}).Error; err != nil {
+		return err
+	}
+	if len(izin) == 0 {
+		return nil
+	}
+	baris := make([]IzinPeran, len(izin))
+	for i, iz := range izin {
+		baris[i] = IzinPeran{KodePeran: kodePeran, Izin: iz}
+	}
+	return tx.Create(&baris).Error
+}
+
+// validasiDaftarIzin memastikan semua izin dikenal, tidak ganda, dan cocok dengan jenis peran.
+func validasiDaftarIzin(jenis string, izin []string) ([]string, error) {
+	unik := map[string]bool{}
+	var hasil []string
+	for _, iz := range izin {
+		iz = strings.TrimSpace(iz)
+		if unik[iz] {
+			continue
+		}
+		if iz != izinSemua {
+			def, ok := cariDefinisiIzin(iz)
+			if !ok {
+				return nil, errParameter("Izin tidak dikenal: " + iz)
+			}
+			if def.Profil != "" && def.Profil != jenis {
+				return nil, errParameter("Izin " + iz + " hanya dapat diberikan ke peran berjenis " + def.Profil)
+			}
+		}
+		unik[iz] = true
+		hasil = append(hasil, iz)
+	}
+	sort.Strings(hasil)
+	return hasil, nil
+}
+
+// ----- Cache izin per peran -----
+
+// cacheIzin menyimpan peta peran → izin. Dimuat ulang setelah IZIN_CACHE_TTL (default 1 menit)
+// agar perubahan di replika lain ikut terbaca, atau segera setelah admin mengubah izin.
+type cacheIzin struct {
+	mu     sync.RWMutex
+	data   map[string]map[string]bool
+	dimuat time.Time
+}
+
+var izinCache = &cacheIzin{}
+
+func (c *cacheIzin) reset() {
+	c.mu.Lock()
+	c.data = nil
+	c.mu.Unlock()
+}
+
+func (c *cacheIzin) muat() (map[string]map[string]bool, error) {
+	c.mu.RLock()
+	data, dimuat := c.data, c.dimuat
+	c.mu.RUnlock()
+	if data != nil && time.Since(dimuat) < envDurasi("IZIN_CACHE_TTL", time.Minute) {
+		return data, nil
+	}
+
+	var baris []IzinPeran
+	if err := DB.Find(&baris).Error; err != nil {
+		return nil, err
+	}
+	data = make(map[string]map[string]bool)
+	for _, b := range baris {
+		if data[b.KodePeran] == nil {
+			data[b.KodePeran] = make(map[string]bool)
+		}
+		data[b.KodePeran][b.Izin] = true
+	}
+
+	c.mu.Lock()
+	c.data, c.dimuat = data, time.Now()
+	c.mu.Unlock()
+	return data, nil
+}
+
+// punya memeriksa apakah peran memiliki izin (langsung atau lewat wildcard "*").
+func (c *cacheIzin) punya(kodePeran, izin string) (bool, error) {
+	data, err := c.muat()
+	if err != nil {
+		return false, err
+	}
+	return data[kodePeran][izin] || data[kodePeran][izinSemua], nil
+}
+
+// izinPeran mengembalikan daftar izin efektif milik peran (wildcard diuraikan).
+func izinPeran(kodePeran string) []string {
+	data, err := izinCache.muat()
+	if err != nil {
+		return nil
+	}
+	var hasil []string
+	for _, def := range katalogIzin {
+		if data[kodePeran][def.Kode] || data[kodePeran][izinSemua] {
+			hasil = append(hasil, def.Kode)
+		}
+	}
+	return hasil
+}
+
+// RequirePermission adalah middleware otorisasi berbasis izin. Dipasang setelah AuthMiddleware.
+func RequirePermission(izin string) gin.HandlerFunc {
+	def, ok := cariDefinisiIzin(izin)
+	if !ok {
+		log.Fatal("❌ RequirePermission: izin tidak terdaftar di katalog: ", izin)
+	}
+	return func(c *gin.Context) {
+		userClaims, exists := c.Get("user")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Silakan login terlebih dahulu"})
+			return
+		}
+		claims := userClaims.(*Claims)
+
+		if claims.Terbatas {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":              "Peran Anda mewajibkan autentikasi dua faktor. Aktifkan 2FA terlebih dahulu.",
+				"wajib_aktifkan_2fa": true,
+			})
+			return
+		}
+		if def.Profil != "" && claims.Role != def.Profil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Fitur ini hanya dapat diakses oleh user " + strings.ToUpper(def.Profil)})
+			return
+		}
+		if !cekIzin(c, claims, izin) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// punyaIzin memeriksa apakah peran user (dan API key-nya, jika ada) memiliki izin.
+func punyaIzin(claims *Claims, izin string) (bool, error) {
+	diizinkan, err := izinCache.punya(claims.Peran, izin)
+	if err != nil {
+		return false, err
+	}
+	// API key dibatasi izinnya sendiri di samping izin peran akun layanan
+	if claims.IDAPIKey != 0 && !mengandung(claims.IzinKunci, izin) {
+		diizinkan = false
+	}
+	return diizinkan, nil
+}
+
+// cekIzin dipakai handler yang membutuhkan izin tambahan di luar izin route-nya.
+// Jika user tidak memiliki izin, respons error sudah dikirim dan fungsi mengembalikan false.
+func cekIzin(c *gin.Context, claims *Claims, izin string) bool {
+	diizinkan, err := punyaIzin(claims, izin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa hak akses"})
+		return false
+	}
+	if !diizinkan {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Anda tidak memiliki izin " + izin, "izin": izin})
+		return false
+	}
+	return true
+}
+
+// ----- Handler admin: peran & izin -----
+
+// GetKatalogIzin: GET /api/izin, daftar seluruh izin yang dapat diberikan ke peran
+func GetKatalogIzin(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": katalogIzin})
+}
+
+// GetAllPeran: GET /api/peran, daftar peran beserta izinnya
+func GetAllPeran(c *gin.Context) {
+	var daftar []Peran
+	if err := DB.Preload("Izin").Order("jenis, kode").Find(&daftar).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range daftar {
+		daftar[i].DaftarIzin = []string{}
+		for _, iz := range daftar[i].Izin {
+			daftar[i].DaftarIzin = append(daftar[i].DaftarIzin, iz.Izin)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": daftar})
+}
+
+// CreatePeran: POST /api/peran, membuat peran baru beserta izinnya
+func CreatePeran(c *gin.Context) {
+	var req PeranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode, nama, dan jenis peran wajib diisi"})
+		return
+	}
+	req.Kode = strings.ToLower(strings.TrimSpace(req.Kode))
+	if req.Jenis != "opd" && req.Jenis != "pemda" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Jenis peran harus opd atau pemda"})
+		return
+	}
+	izin, err := validasiDaftarIzin(req.Jenis, req.Izin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	peran := Peran{Kode: req.Kode, Nama: req.Nama, Jenis: req.Jenis, Deskripsi: req.Deskripsi}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var jumlah int64
+		tx.Model(&Peran{}).Where("kode = ?", peran.Kode).Count(&jumlah)
+		if jumlah > 0 {
+			return errParameter("Kode peran sudah dipakai")
+		}
+		if err := tx.Create(&peran).Error; err != nil {
+			return err
+		}
+		if err := simpanIzinPeran(tx, peran.Kode, izin); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "peran.buat", "peran:"+peran.Kode, "Izin: "+strings.Join(izin, ", "))
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+	izinCache.reset()
+
+	peran.DaftarIzin = izin
+	c.JSON(http.StatusCreated, peran)
+}
+
+// UpdateIzinPeran: PUT /api/peran/:kode/izin, mengganti seluruh izin peran
+func UpdateIzinPeran(c *gin.Context) {
+	var req IzinPeranRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+
+	var peran Peran
+	if err := DB.First(&peran, "kode = ?", c.Param("kode")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Peran tidak ditemukan"})
+		return
+	}
+	izin, err := validasiDaftarIzin(peran.Jenis, req.Izin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Cegah admin terkunci dari pengelolaan peran
+	if peran.Kode == PeranSuperadmin && !mengandung(izin, izinSemua) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Izin peran superadmin harus tetap \"*\""})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := simpanIzinPeran(tx, peran.Kode, izin); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "peran.ubah_izin", "peran:"+peran.Kode, "Izin: "+strings.Join(izin, ", "))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan izin peran"})
+		return
+	}
+	izinCache.reset()
+
+	peran.DaftarIzin = izin
+	c.JSON(http.StatusOK, peran)
+}
+
+// UbahPeranUser: PUT /api/users/:role/:id/peran, mengganti peran RBAC milik user.
+// Semua sesi user dicabut agar peran baru langsung berlaku.
+func UbahPeranUser(c *gin.Context) {
+	var req UbahPeranUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kode_peran wajib diisi"})
+		return
+	}
+	role := c.Param("role")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID user tidak valid"})
+		return
+	}
+
+	target, idAkun, err := peranUntukUser(role, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cekPeranUntukProfil(req.KodePeran, role); err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// Minimal harus tersisa satu superadmin
+		if target.KodePeran == PeranSuperadmin && req.KodePeran != PeranSuperadmin {
+			var jumlah int64
+			tx.Model(&UserPemda{}).Where("kode_peran = ?", PeranSuperadmin).Where(kondisiProfilAktif).Count(&jumlah)
+			if jumlah <= 1 {
+				return errParameter("Tidak dapat mengubah peran superadmin terakhir")
+			}
+		}
+
+		var tabel interface{} = &UserOPD{}
+		if role == "pemda" {
+			tabel = &UserPemda{}
+		}
+		if err := tx.Model(tabel).Where(kolomIDProfil(role)+" = ?", id).Update("kode_peran", req.KodePeran).Error; err != nil {
+			return err
+		}
+		if _, err := cabutSemuaSesi(tx, idAkun, 0, "Peran diubah admin"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "user.ubah_peran", targetUser(role, uint(id)),
+			"Peran diubah dari "+target.KodePeran+" menjadi "+req.KodePeran)
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Peran user berhasil diubah", "kode_peran": req.KodePeran})
+}
+
+// cekPeranUntukProfil memastikan peran ada dan jenisnya cocok dengan profil user ("opd"/"pemda").
+func cekPeranUntukProfil(kodePeran, role string) error {
+	var peran Peran
+	if err := DB.First(&peran, "kode = ?", kodePeran).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errParameter("Peran " + kodePeran + " tidak ditemukan")
+		}
+		return err
+	}
+	if peran.Jenis != role {
+		return errParameter("Peran " + kodePeran + " hanya dapat diberikan ke user " + strings.ToUpper(peran.Jenis))
+	}
+	return nil
+}
+
+// kolomIDProfil mengembalikan nama kolom primary key tabel profil untuk role.
+func kolomIDProfil(role string) string {
+	if role == "pemda" {
+		return "id_user_pemda"
+	}
+	return "id_user_opd"
+}
+
+// mengandung memeriksa apakah s ada di daftar.
+func mengandung(daftar []string, s string) bool {
+	for _, d := range daftar {
+		if d == s {
+			return true
+		}
+	}
+	return false
+}
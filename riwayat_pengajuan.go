@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= RIWAYAT (AUDIT TRAIL) FORM PENGAJUAN =========
+
+// catatRiwayatPengajuan menyimpan satu baris riwayat untuk pengajuan.
+// statusLama bernilai nil ketika pengajuan baru saja dibuat.
+// Dipanggil di dalam transaksi yang sama dengan perubahan datanya.
+func catatRiwayatPengajuan(tx *gorm.DB, claims *Claims, idForm uint, statusLama *string, statusBaru, catatan string) error {
+	riwayat := RiwayatPengajuan{
+		IDFormPengajuan: idForm,
+		RoleAktor:       claims.Role,
+		IDAktor:         claims.ID,
+		NamaAktor:       claims.Nama,
+		StatusLama:      statusLama,
+		StatusBaru:      statusBaru,
+		Catatan:         catatan,
+	}
+	return tx.Create(&riwayat).Error
+}
+
+// GetTimelinePengajuan: Mendapatkan seluruh riwayat perubahan sebuah pengajuan (urut kronologis)
+func GetTimelinePengajuan(c *gin.Context) {
+	formID := c.Param("id")
+	var form FormPengajuan
+
+	if err := DB.Where(kondisiPengajuanAktif).First(&form, formID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data pengajuan tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Otorisasi: sama dengan detail pengajuan, OPD hanya boleh melihat milik OPD-nya
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
+
+	var riwayat []RiwayatPengajuan
+	if err := DB.Where("id_form_pengajuan = ?", form.ID).
+		Order("created_at ASC, id_riwayat_pengajuan ASC").
+		Find(&riwayat).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat pengajuan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": riwayat})
+}
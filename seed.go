@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	_ "embed"
 	"fmt"
 	"log"
 
@@ -11,6 +13,11 @@ import (
 // dan variabel koneksi GORM 'DB' sudah dideklarasikan.
 // Implementasi FirstOrCreate di sini mengasumsikan GORM v2.
 
+// dataHariLibur adalah kalender hari libur nasional & cuti bersama bawaan aplikasi.
+//
+//go:embed data/hari_libur.csv
+var dataHariLibur []byte
+
 // Seed akan mengisi database dengan data awal untuk keperluan development.
 func Seed() {
 	fmt.Println("===== MEMULAI PROSES SEEDING DATA (V8) =====")
@@ -44,40 +51,50 @@ func Seed() {
 	opdPerkim := OPD{NamaOPD: "Dinas PERKIM", AlamatOPD: "Jl. Mastrip No. 10"}
 	DB.FirstOrCreate(&opdPerkim, OPD{NamaOPD: "Dinas PERKIM"})
 
+	// --- Akun login (password disimpan di akun, bukan di profil) ---
+	akunPemda := seedAkun("198505052015012002", string(hashedPasswordPemda))
+	akunBappeda := seedAkun("199001012020121001", string(hashedPasswordOPD))
+	akunDinkes := seedAkun("199203152021012003", string(hashedPasswordOPD))
+	akunPerkim := seedAkun("199407102022021005", string(hashedPasswordOPD))
+
 	// --- User Pemda (1) ---
 	userPemda := UserPemda{
-		Nama:     "Dr. Anisa Wijayanti",
-		Password: string(hashedPasswordPemda),
-		NIP:      "198505052015012002",
-		Jabatan:  "Kepala Bidang Verifikasi",
+		Nama:      "Dr. Anisa Wijayanti",
+		IDAkun:    akunPemda,
+		KodePeran: PeranSuperadmin,
+		NIP:       "198505052015012002",
+		Jabatan:   "Kepala Bidang Verifikasi",
 	}
 	DB.FirstOrCreate(&userPemda, UserPemda{NIP: "198505052015012002"})
 
 	// --- User OPD (3) ---
 	userBappeda := UserOPD{
-		IDOPD:    opdBappeda.ID,
-		Nama:     "Budi Santoso",
-		Password: string(hashedPasswordOPD),
-		NIP:      "199001012020121001",
-		Jabatan:  "Staf Perencanaan",
+		IDOPD:     opdBappeda.ID,
+		Nama:      "Budi Santoso",
+		IDAkun:    akunBappeda,
+		KodePeran: PeranKepalaOPD,
+		NIP:       "199001012020121001",
+		Jabatan:   "Staf Perencanaan",
 	}
 	DB.FirstOrCreate(&userBappeda, UserOPD{NIP: "199001012020121001"})
 
 	userDinkes := UserOPD{
-		IDOPD:    opdDinkes.ID,
-		Nama:     "Citra Lestari",
-		Password: string(hashedPasswordOPD),
-		NIP:      "199203152021012003",
-		Jabatan:  "Staf Administrasi Kesehatan",
+		IDOPD:     opdDinkes.ID,
+		Nama:      "Citra Lestari",
+		IDAkun:    akunDinkes,
+		KodePeran: PeranKepalaOPD,
+		NIP:       "199203152021012003",
+		Jabatan:   "Staf Administrasi Kesehatan",
 	}
 	DB.FirstOrCreate(&userDinkes, UserOPD{NIP: "199203152021012003"})
 
 	userPerkim := UserOPD{
-		IDOPD:    opdPerkim.ID,
-		Nama:     "Ahmad Sahroni",
-		Password: string(hashedPasswordOPD),
-		NIP:      "199407102022021005",
-		Jabatan:  "Staf Pendataan PSU",
+		IDOPD:     opdPerkim.ID,
+		Nama:      "Ahmad Sahroni",
+		IDAkun:    akunPerkim,
+		KodePeran: PeranKepalaOPD,
+		NIP:       "199407102022021005",
+		Jabatan:   "Staf Pendataan PSU",
 	}
 	DB.FirstOrCreate(&userPerkim, UserOPD{NIP: "199407102022021005"})
 
@@ -89,54 +106,83 @@ func Seed() {
 
 	// --- Standar milik OPD BAPPEDA (1) ---
 	standarBappeda1 := JenisPelayanan{
-		IDOPD:           opdBappeda.ID,
-		NamaStandar:     "Rekomendasi Izin Prinsip Pembangunan",
-		DasarHukum:      "Perda No. 5 Tahun 2020 tentang RTRW",
-		Persyaratan:     "1. Fotokopi KTP\n2. Fotokopi Sertifikat Tanah\n3. Proposal Rencana Pembangunan",
-		WaktuPelayanan:  "14 Hari Kerja",
-		BiayaTarif:      "Rp 0 (Sesuai Perda)",
-		ProdukPelayanan: "Surat Rekomendasi Izin Prinsip",
-		StatusValidasi:  "Menunggu Validasi", // Sesuai permintaan
+		IDOPD:                opdBappeda.ID,
+		NamaStandar:          "Rekomendasi Izin Prinsip Pembangunan",
+		DasarHukum:           "Perda No. 5 Tahun 2020 tentang RTRW",
+		Persyaratan:          "1. Fotokopi KTP\n2. Fotokopi Sertifikat Tanah\n3. Proposal Rencana Pembangunan",
+		WaktuPelayanan:       "14 Hari Kerja",
+		WaktuPelayananNilai:  14,
+		WaktuPelayananSatuan: SatuanHariKerja,
+		BiayaTarif:           "Rp 0 (Sesuai Perda)",
+		ProdukPelayanan:      "Surat Rekomendasi Izin Prinsip",
+		StatusValidasi:       StatusValidasiMenunggu, // Sesuai permintaan
 	}
 	DB.FirstOrCreate(&standarBappeda1, JenisPelayanan{NamaStandar: standarBappeda1.NamaStandar})
 
 	// --- Standar milik OPD Dinas Kesehatan (1) ---
 	standarDinkes1 := JenisPelayanan{
-		IDOPD:           opdDinkes.ID,
-		NamaStandar:     "Penerbitan Surat Izin Praktik (SIP) Dokter",
-		DasarHukum:      "UU No. 29 Tahun 2004 tentang Praktik Kedokteran",
-		Persyaratan:     "1. Fotokopi KTP\n2. Pas Foto 4x6\n3. Surat Tanda Registrasi (STR)",
-		WaktuPelayanan:  "7 Hari Kerja",
-		BiayaTarif:      "Gratis",
-		ProdukPelayanan: "Surat Izin Praktik (SIP) Dokter",
-		StatusValidasi:  "Menunggu Validasi", // Sesuai permintaan
+		IDOPD:                opdDinkes.ID,
+		NamaStandar:          "Penerbitan Surat Izin Praktik (SIP) Dokter",
+		DasarHukum:           "UU No. 29 Tahun 2004 tentang Praktik Kedokteran",
+		Persyaratan:          "1. Fotokopi KTP\n2. Pas Foto 4x6\n3. Surat Tanda Registrasi (STR)",
+		WaktuPelayanan:       "7 Hari Kerja",
+		WaktuPelayananNilai:  7,
+		WaktuPelayananSatuan: SatuanHariKerja,
+		BiayaTarif:           "Gratis",
+		ProdukPelayanan:      "Surat Izin Praktik (SIP) Dokter",
+		StatusValidasi:       StatusValidasiMenunggu, // Sesuai permintaan
 	}
 	DB.FirstOrCreate(&standarDinkes1, JenisPelayanan{NamaStandar: standarDinkes1.NamaStandar})
 
 	// --- Standar milik OPD Dinas PERKIM (1) ---
 	standarPerkim1 := JenisPelayanan{
-		IDOPD:           opdPerkim.ID,
-		NamaStandar:     "Permohonan Bantuan Prasarana, Sarana, dan Utilitas (PSU) Perumahan",
-		DasarHukum:      "Permen PUPR No. 03/PRT/M/2018",
-		Persyaratan:     "1. Proposal dari Pengembang\n2. Site Plan yang Disetujui\n3. Data Calon Penerima Manfaat",
-		WaktuPelayanan:  "30 Hari Kerja (Verifikasi Lapangan)",
-		BiayaTarif:      "Gratis",
-		ProdukPelayanan: "SK Penetapan Penerima Bantuan PSU",
-		StatusValidasi:  "Menunggu Validasi", // Sesuai permintaan
+		IDOPD:                opdPerkim.ID,
+		NamaStandar:          "Permohonan Bantuan Prasarana, Sarana, dan Utilitas (PSU) Perumahan",
+		DasarHukum:           "Permen PUPR No. 03/PRT/M/2018",
+		Persyaratan:          "1. Proposal dari Pengembang\n2. Site Plan yang Disetujui\n3. Data Calon Penerima Manfaat",
+		WaktuPelayanan:       "30 Hari Kerja (Verifikasi Lapangan)",
+		WaktuPelayananNilai:  30,
+		WaktuPelayananSatuan: SatuanHariKerja,
+		BiayaTarif:           "Gratis",
+		ProdukPelayanan:      "SK Penetapan Penerima Bantuan PSU",
+		StatusValidasi:       StatusValidasiMenunggu, // Sesuai permintaan
 	}
 	DB.FirstOrCreate(&standarPerkim1, JenisPelayanan{NamaStandar: standarPerkim1.NamaStandar})
 
+	migrasiVersiStandar() // Versi 1 untuk standar hasil seed
+	migrasiPersyaratan()  // Item persyaratan dari teks Persyaratan seed
 	log.Println("📃 Seeding Jenis Pelayanan (Standar) selesai! (Total 3 Standar, status 'Menunggu Validasi')")
 
 	// ==================================================================
-	// LANGKAH 4: Form Pemohon (Dikosongkan)
+	// LANGKAH 4: Kalender Hari Libur (dari data/hari_libur.csv)
+	// ==================================================================
+	hariLibur, err := parseCSVHariLibur(bytes.NewReader(dataHariLibur))
+	if err != nil {
+		log.Fatalf("Gagal membaca data hari libur: %v", err)
+	}
+	if err := simpanHariLibur(DB, hariLibur); err != nil {
+		log.Fatalf("Gagal menyimpan data hari libur: %v", err)
+	}
+	muatKalenderKerja()
+
+	log.Println("📅 Seeding Hari Libur selesai! (Total", len(hariLibur), "tanggal)")
+
+	// ==================================================================
+	// LANGKAH 5: Form Pemohon (Dikosongkan)
 	// ==================================================================
 	log.Println("⏩ Seeding Form Pemohon (Master) dilompati sesuai permintaan.")
 
 	// ==================================================================
-	// LANGKAH 5: Form Pengajuan (Dikosongkan)
+	// LANGKAH 6: Form Pengajuan (Dikosongkan)
 	// ==================================================================
 	log.Println("⏩ Seeding Form Pengajuan (Transaksi) dilompati sesuai permintaan.")
 
 	fmt.Println("===== PROSES SEEDING SELESAI =====")
-}
\ No newline at end of file
+}
+
+// seedAkun membuat akun login untuk NIP (jika belum ada) dan mengembalikan ID-nya.
+func seedAkun(nip, hashedPassword string) uint {
+	akun := Akun{NIP: nip, Password: hashedPassword}
+	DB.FirstOrCreate(&akun, Akun{NIP: nip})
+	return akun.ID
+}
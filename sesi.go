@@ -0,0 +1,360 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ========= SESI LOGIN, REFRESH TOKEN & PENCABUTAN TOKEN =========
+
+// cookieRefresh adalah nama cookie HttpOnly yang menyimpan refresh token.
+const cookieRefresh = "refresh_token"
+
+// ttlAccessToken: umur access token (JWT), default 15 menit (env ACCESS_TOKEN_TTL, mis. "15m").
+func ttlAccessToken() time.Duration {
+	return envDurasi("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// ttlRefreshToken: umur sesi / refresh token, default 7 hari (env REFRESH_TOKEN_TTL, mis. "168h").
+func ttlRefreshToken() time.Duration {
+	return envDurasi("REFRESH_TOKEN_TTL", 7*24*time.Hour)
+}
+
+// namaCookieRole mengembalikan nama cookie access token untuk role.
+func namaCookieRole(role string) string {
+	if role == "pemda" {
+		return "pemda_token"
+	}
+	return "opd_token"
+}
+
+// pengaturanCookie mengembalikan domain dan flag secure cookie sesuai environment.
+func pengaturanCookie() (string, bool) {
+	domain := os.Getenv("APP_DOMAIN")
+	if domain == "" {
+		domain = "localhost"
+	}
+	return domain, os.Getenv("GIN_MODE") == "release"
+}
+
+// setCookieAkses menyimpan access token sebagai cookie HttpOnly dan menghapus cookie
+// role lainnya agar cookie usang dari peran sebelumnya tidak ikut terbaca.
+// Umur cookie mengikuti sesi agar frontend tetap mengenali status login;
+// masa berlaku sebenarnya dibatasi oleh klaim exp di dalam JWT.
+func setCookieAkses(c *gin.Context, role, accessToken string) {
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie(namaCookieRole(role), accessToken, int(ttlRefreshToken().Seconds()), "/", domain, isSecure, true)
+	for _, lain := range []string{"opd", "pemda"} {
+		if lain != role {
+			c.SetCookie(namaCookieRole(lain), "", -1, "/", domain, isSecure, true)
+		}
+	}
+}
+
+// setCookieAuth menyimpan access token dan refresh token sebagai cookie HttpOnly.
+func setCookieAuth(c *gin.Context, role, accessToken, refreshToken string) {
+	setCookieAkses(c, role, accessToken)
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie(cookieRefresh, refreshToken, int(ttlRefreshToken().Seconds()), "/api", domain, isSecure, true)
+}
+
+// hapusCookieAuth menghapus semua cookie otentikasi.
+func hapusCookieAuth(c *gin.Context) {
+	domain, isSecure := pengaturanCookie()
+	c.SetCookie("opd_token", "", -1, "/", domain, isSecure, true)
+	c.SetCookie("pemda_token", "", -1, "/", domain, isSecure, true)
+	c.SetCookie(cookieRefresh, "", -1, "/api", domain, isSecure, true)
+	hapusCookieCSRF(c)
+}
+
+// buatSesi membuat sesi baru beserta refresh token-nya (format "<id_sesi>.<rahasia>").
+func buatSesi(c *gin.Context, idAkun uint, role string, idUser uint) (*Sesi, string, error) {
+	rahasia, err := tokenAcak(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	sesi := Sesi{
+		IDAkun:           idAkun,
+		Role:             role,
+		IDUser:           idUser,
+		RefreshTokenHash: hashToken(rahasia),
+		UserAgent:        c.Request.UserAgent(),
+		AlamatIP:         c.ClientIP(),
+		KedaluwarsaPada:  now.Add(ttlRefreshToken()),
+		TerakhirDipakai:  now,
+	}
+	if err := DB.Create(&sesi).Error; err != nil {
+		return nil, "", err
+	}
+	return &sesi, strconv.FormatUint(uint64(sesi.ID), 10) + "." + rahasia, nil
+}
+
+// terbitkanAccessToken menandatangani JWT berumur pendek untuk sesi dan
+// mencatat jti-nya di sesi (agar dapat dicabut saat logout).
+func terbitkanAccessToken(claims *Claims, sesi *Sesi) (string, error) {
+	jti, err := tokenAcak(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.IDSesi = sesi.ID
+	claims.Terbatas = perluAktifkan2FA(claims.Peran, claims.IDAkun)
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Audience:  jwt.ClaimStrings{audAksesToken},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttlAccessToken())),
+	}
+
+	tokenString, err := tandatanganiJWT(claims)
+	if err != nil {
+		return "", err
+	}
+
+	sesi.JTIAkses = jti
+	sesi.AksesKedaluwarsaPada = claims.ExpiresAt.Time
+	return tokenString, DB.Model(sesi).Updates(map[string]interface{}{
+		"jti_akses":              sesi.JTIAkses,
+		"akses_kedaluwarsa_pada": sesi.AksesKedaluwarsaPada,
+	}).Error
+}
+
+// cabutJTI memasukkan jti access token ke daftar token dicabut.
+func cabutJTI(tx *gorm.DB, jti string, kedaluwarsa time.Time) error {
+	if jti == "" || kedaluwarsa.Before(time.Now()) {
+		return nil // Token sudah kedaluwarsa dengan sendirinya
+	}
+	// Bersihkan entri lama yang sudah tidak relevan
+	tx.Where("kedaluwarsa_pada < ?", time.Now()).Delete(&TokenDicabut{})
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&TokenDicabut{JTI: jti, KedaluwarsaPada: kedaluwarsa}).Error
+}
+
+// cabutSesi menandai sesi dicabut dan mencabut access token terakhirnya.
+func cabutSesi(tx *gorm.DB, sesi *Sesi, alasan string) error {
+	if sesi.DicabutPada != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := tx.Model(sesi).Updates(map[string]interface{}{"dicabut_pada": now, "alasan_cabut": alasan}).Error; err != nil {
+		return err
+	}
+	sesi.DicabutPada = &now
+	return cabutJTI(tx, sesi.JTIAkses, sesi.AksesKedaluwarsaPada)
+}
+
+// cabutSemuaSesi mencabut semua sesi aktif milik akun (semua peran), kecuali sesi dengan ID kecuali (0 = tanpa pengecualian).
+func cabutSemuaSesi(tx *gorm.DB, idAkun uint, kecuali uint, alasan string) (int, error) {
+	var daftar []Sesi
+	if err := tx.Where("id_akun = ? AND dicabut_pada IS NULL AND id_sesi <> ?", idAkun, kecuali).
+		Find(&daftar).Error; err != nil {
+		return 0, err
+	}
+	for i := range daftar {
+		if err := cabutSesi(tx, &daftar[i], alasan); err != nil {
+			return 0, err
+		}
+	}
+	return len(daftar), nil
+}
+
+// tokenDicabut memeriksa apakah jti ada di daftar token dicabut.
+func tokenDicabut(jti string) bool {
+	var jumlah int64
+	DB.Model(&TokenDicabut{}).Where("jti = ?", jti).Count(&jumlah)
+	return jumlah > 0
+}
+
+// jumlahHashRefreshLama: banyaknya hash refresh token hasil rotasi yang disimpan per sesi.
+const jumlahHashRefreshLama = 10
+
+// sesiDariRefreshToken mencari sesi dari refresh token "<id_sesi>.<rahasia>".
+// cocok bernilai true jika rahasia sama dengan refresh token saat ini; dipakaiUlang bernilai
+// true jika rahasia adalah refresh token yang pernah diterbitkan untuk sesi ini lalu dirotasi.
+// Rahasia yang tidak dikenal menghasilkan keduanya false.
+func sesiDariRefreshToken(refreshToken string) (sesi *Sesi, cocok, dipakaiUlang bool, err error) {
+	idStr, rahasia, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return nil, false, false, errors.New("format refresh token tidak valid")
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, false, false, errors.New("format refresh token tidak valid")
+	}
+	var s Sesi
+	if err := DB.First(&s, id).Error; err != nil {
+		return nil, false, false, err
+	}
+	hash := []byte(hashToken(rahasia))
+	if subtle.ConstantTimeCompare([]byte(s.RefreshTokenHash), hash) == 1 {
+		return &s, true, false, nil
+	}
+	for _, lama := range strings.Split(s.RefreshTokenHashLama, ",") {
+		if lama != "" && subtle.ConstantTimeCompare([]byte(lama), hash) == 1 {
+			return &s, false, true, nil
+		}
+	}
+	return &s, false, false, nil
+}
+
+// tambahHashRefreshLama menambahkan hash yang baru dirotasi ke daftar, dibatasi jumlahHashRefreshLama terakhir.
+func tambahHashRefreshLama(daftar, hash string) string {
+	var hasil []string
+	if daftar != "" {
+		hasil = strings.Split(daftar, ",")
+	}
+	hasil = append(hasil, hash)
+	if len(hasil) > jumlahHashRefreshLama {
+		hasil = hasil[len(hasil)-jumlahHashRefreshLama:]
+	}
+	return strings.Join(hasil, ",")
+}
+
+// RefreshHandler: POST /api/refresh
+// Menukar refresh token (cookie) dengan access token baru dan merotasi refresh token.
+// Refresh token lama (yang pernah diterbitkan lalu dirotasi) yang dipakai ulang dianggap dicuri:
+// sesinya langsung dicabut. Rahasia yang tidak dikenal hanya ditolak tanpa menyentuh sesi.
+func RefreshHandler(c *gin.Context) {
+	refreshToken, err := c.Cookie(cookieRefresh)
+	if err != nil || refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token tidak ditemukan"})
+		return
+	}
+
+	sesi, cocok, dipakaiUlang, err := sesiDariRefreshToken(refreshToken)
+	if err != nil || (!cocok && !dipakaiUlang) {
+		hapusCookieAuth(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi tidak valid, silakan login kembali"})
+		return
+	}
+	if dipakaiUlang {
+		// Refresh token lama dipakai lagi: kemungkinan dicuri
+		log.Println("[REFRESH] Refresh token dipakai ulang, sesi dicabut. ID Sesi:", sesi.ID)
+		cabutSesi(DB, sesi, "Refresh token dipakai ulang")
+		hapusCookieAuth(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi tidak valid, silakan login kembali"})
+		return
+	}
+	if sesi.DicabutPada != nil || time.Now().After(sesi.KedaluwarsaPada) {
+		hapusCookieAuth(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi sudah berakhir, silakan login kembali"})
+		return
+	}
+
+	// Data peran dibaca ulang agar perubahan nama / jabatan / OPD ikut terbawa
+	peran, idAkun, err := peranUntukUser(sesi.Role, sesi.IDUser)
+	if err != nil || idAkun != sesi.IDAkun || !peran.Aktif {
+		cabutSesi(DB, sesi, "Peran tidak lagi dimiliki akun")
+		hapusCookieAuth(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	claims := peran.claims(idAkun)
+
+	// Rotasi: rahasia baru, access token lama dicabut
+	rahasia, err := tokenAcak(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
+		return
+	}
+	jtiLama, kedaluwarsaLama := sesi.JTIAkses, sesi.AksesKedaluwarsaPada
+	hasil := DB.Model(&Sesi{}).
+		Where("id_sesi = ? AND refresh_token_hash = ?", sesi.ID, sesi.RefreshTokenHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":      hashToken(rahasia),
+			"refresh_token_hash_lama": tambahHashRefreshLama(sesi.RefreshTokenHashLama, sesi.RefreshTokenHash),
+			"terakhir_dipakai":        time.Now(),
+		})
+	if hasil.Error != nil || hasil.RowsAffected == 0 {
+		// Request refresh lain untuk sesi yang sama sudah lebih dulu memutar token
+		c.JSON(http.StatusConflict, gin.H{"error": "Refresh token sudah dipakai, silakan coba lagi"})
+		return
+	}
+	cabutJTI(DB, jtiLama, kedaluwarsaLama)
+
+	accessToken, err := terbitkanAccessToken(claims, sesi)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
+		return
+	}
+
+	setCookieAuth(c, sesi.Role, accessToken, strconv.FormatUint(uint64(sesi.ID), 10)+"."+rahasia)
+	c.JSON(http.StatusOK, gin.H{"success": true, "kedaluwarsa_pada": claims.ExpiresAt.Time})
+}
+
+// LogoutSemuaPerangkat: POST /api/me/logout-all, mencabut semua sesi milik user yang login
+func LogoutSemuaPerangkat(c *gin.Context) {
+	userClaims, _ := c.Get("user")
+	claims := userClaims.(*Claims)
+
+	var jumlah int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		jumlah, err = cabutSemuaSesi(tx, claims.IDAkun, 0, "Logout dari semua perangkat")
+		if err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "sesi.logout_semua", targetUser(claims.Role, claims.ID), strconv.Itoa(jumlah)+" sesi dicabut")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencabut sesi"})
+		return
+	}
+
+	hapusCookieAuth(c)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Berhasil logout dari semua perangkat", "jumlah_sesi": jumlah})
+}
+
+// GetSesiUser: Admin melihat sesi aktif milik user (?semua=true untuk menyertakan yang sudah berakhir)
+func GetSesiUser(c *gin.Context) {
+	role := c.Param("role")
+	if role != "opd" && role != "pemda" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role harus opd atau pemda"})
+		return
+	}
+
+	query := DB.Where("role = ? AND id_user = ?", role, c.Param("id"))
+	if c.Query("semua") != "true" {
+		query = query.Where("dicabut_pada IS NULL AND kedaluwarsa_pada > ?", time.Now())
+	}
+
+	var daftar []Sesi
+	if err := query.Order("terakhir_dipakai DESC").Find(&daftar).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": daftar})
+}
+
+// CabutSesiAdmin: Admin mencabut satu sesi (user akan ter-logout dari perangkat tersebut)
+func CabutSesiAdmin(c *gin.Context) {
+	var sesi Sesi
+	if err := DB.First(&sesi, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sesi tidak ditemukan"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := cabutSesi(tx, &sesi, "Dicabut oleh admin"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "sesi.cabut", targetUser(sesi.Role, sesi.IDUser), "Sesi #"+strconv.FormatUint(uint64(sesi.ID), 10)+" dicabut")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencabut sesi"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Sesi berhasil dicabut"})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ========= SLA (WAKTU PELAYANAN) & KALENDER HARI KERJA =========
+
+// Satuan yang sah untuk JenisPelayanan.WaktuPelayananSatuan.
+const (
+	SatuanHariKerja    = "hari_kerja"
+	SatuanHariKalender = "hari_kalender"
+	SatuanJam          = "jam"
+)
+
+// satuanWaktuValid memeriksa apakah satuan waktu pelayanan dikenali.
+func satuanWaktuValid(satuan string) bool {
+	return satuan == SatuanHariKerja || satuan == SatuanHariKalender || satuan == SatuanJam
+}
+
+// labelSatuanWaktu mengembalikan teks tampilan untuk satuan, mis. "Hari Kerja".
+func labelSatuanWaktu(satuan string) string {
+	switch satuan {
+	case SatuanHariKalender:
+		return "Hari Kalender"
+	case SatuanJam:
+		return "Jam"
+	default:
+		return "Hari Kerja"
+	}
+}
+
+var polaWaktuPelayanan = regexp.MustCompile(`(?i)(\d+)\s*(hari\s+kerja|hari\s+kalender|hari|jam)`)
+
+// parseWaktuPelayanan membaca teks bebas seperti "14 Hari Kerja" atau
+// "30 Hari Kerja (Verifikasi Lapangan)" menjadi nilai + satuan.
+// "Hari" tanpa keterangan dianggap hari kalender.
+func parseWaktuPelayanan(teks string) (int, string, bool) {
+	m := polaWaktuPelayanan.FindStringSubmatch(teks)
+	if m == nil {
+		return 0, "", false
+	}
+	nilai, err := strconv.Atoi(m[1])
+	if err != nil || nilai <= 0 {
+		return 0, "", false
+	}
+	switch strings.Join(strings.Fields(strings.ToLower(m[2])), " ") {
+	case "hari kerja":
+		return nilai, SatuanHariKerja, true
+	case "jam":
+		return nilai, SatuanJam, true
+	default:
+		return nilai, SatuanHariKalender, true
+	}
+}
+
+// KalenderKerja menyimpan daftar hari libur (nasional, cuti bersama, daerah)
+// yang dipakai untuk menghitung hari kerja. Sabtu dan Minggu selalu libur.
+type KalenderKerja struct {
+	mu    sync.RWMutex
+	libur map[string]string // "2006-01-02" -> keterangan libur
+}
+
+// kalenderKerja adalah kalender global yang dipakai oleh mesin SLA.
+var kalenderKerja = &KalenderKerja{libur: map[string]string{}}
+
+// Set mengganti seluruh daftar hari libur.
+func (k *KalenderKerja) Set(libur map[string]string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.libur = libur
+}
+
+// IsHariKerja bernilai true jika tanggal t bukan akhir pekan dan bukan hari libur.
+func (k *KalenderKerja) IsHariKerja(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, libur := k.libur[t.Format("2006-01-02")]
+	return !libur
+}
+
+// HariKerjaAntara menghitung jumlah hari kerja setelah tanggal dari hingga tanggal sampai (inklusif).
+// Hasilnya negatif jika sampai lebih awal dari dari.
+func (k *KalenderKerja) HariKerjaAntara(dari, sampai time.Time) int {
+	d, s := awalHari(dari), awalHari(sampai)
+	arah := 1
+	if s.Before(d) {
+		d, s = s, d
+		arah = -1
+	}
+	jumlah := 0
+	for t := d.AddDate(0, 0, 1); !t.After(s); t = t.AddDate(0, 0, 1) {
+		if k.IsHariKerja(t) {
+			jumlah++
+		}
+	}
+	return jumlah * arah
+}
+
+// awalHari memotong jam dari t (tetap di zona waktu t).
+func awalHari(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// akhirHari mengembalikan pukul 23:59:59 pada tanggal t.
+func akhirHari(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+}
+
+// muatKalenderKerja mengisi kalender dari tabel hari_libur, ditambah environment variable
+// HARI_LIBUR_NASIONAL dan HARI_LIBUR_DAERAH (daftar tanggal YYYY-MM-DD dipisah koma).
+// Dipanggil ulang setiap kali data hari libur diubah oleh admin.
+func muatKalenderKerja() {
+	libur := map[string]string{}
+
+	var daftar []HariLibur
+	if err := DB.Find(&daftar).Error; err != nil {
+		log.Println("⚠ Gagal memuat tabel hari_libur:", err)
+	}
+	for _, h := range daftar {
+		libur[h.Tanggal.Format("2006-01-02")] = h.Nama
+	}
+
+	for env, keterangan := range map[string]string{
+		"HARI_LIBUR_NASIONAL": "Libur Nasional",
+		"HARI_LIBUR_DAERAH":   "Libur Daerah",
+	} {
+		for _, s := range strings.Split(os.Getenv(env), ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				log.Println("⚠ Tanggal libur tidak valid di", env+":", s)
+				continue
+			}
+			libur[s] = keterangan
+		}
+	}
+	kalenderKerja.Set(libur)
+	log.Println("📅 Kalender kerja dimuat:", len(libur), "hari libur")
+}
+
+// hitungJatuhTempo menghitung batas waktu pelayanan sejak waktu mulai.
+// Mengembalikan nil jika standar tidak memiliki durasi SLA.
+func hitungJatuhTempo(mulai time.Time, nilai int, satuan string) *time.Time {
+	if nilai <= 0 {
+		return nil
+	}
+
+	var jatuhTempo time.Time
+	switch satuan {
+	case SatuanJam:
+		jatuhTempo = mulai.Add(time.Duration(nilai) * time.Hour)
+	case SatuanHariKalender:
+		jatuhTempo = akhirHari(mulai.AddDate(0, 0, nilai))
+	default: // hari kerja: lewati akhir pekan dan hari libur
+		t := mulai
+		for n := 0; n < nilai; {
+			t = t.AddDate(0, 0, 1)
+			if kalenderKerja.IsHariKerja(t) {
+				n++
+			}
+		}
+		jatuhTempo = akhirHari(t)
+	}
+	return &jatuhTempo
+}
+
+// hitungSLA mengisi field turunan SisaHari, SisaHariKerja, dan Terlambat.
+func (f *FormPengajuan) hitungSLA(sekarang time.Time) {
+	if f.TanggalJatuhTempo == nil {
+		return
+	}
+	jatuhTempo := f.TanggalJatuhTempo.In(sekarang.Location())
+
+	// Pengajuan yang sudah selesai dinilai berdasarkan tanggal selesainya
+	if f.TanggalSelesai != nil {
+		f.Terlambat = f.TanggalSelesai.After(jatuhTempo)
+		return
+	}
+
+	f.Terlambat = sekarang.After(jatuhTempo)
+	sisa := int(awalHari(jatuhTempo).Sub(awalHari(sekarang)).Hours() / 24)
+	sisaKerja := kalenderKerja.HariKerjaAntara(sekarang, jatuhTempo)
+	f.SisaHari = &sisa
+	f.SisaHariKerja = &sisaKerja
+}
+
+// AfterFind memastikan setiap FormPengajuan yang dibaca dari database membawa informasi SLA.
+func (f *FormPengajuan) AfterFind(tx *gorm.DB) error {
+	f.hitungSLA(time.Now())
+	return nil
+}
+
+// normalisasiWaktuPelayanan melengkapi nilai/satuan dari teks (atau sebaliknya).
+func normalisasiWaktuPelayanan(standar *JenisPelayanan) {
+	if standar.WaktuPelayananNilai <= 0 {
+		if nilai, satuan, ok := parseWaktuPelayanan(standar.WaktuPelayanan); ok {
+			standar.WaktuPelayananNilai = nilai
+			standar.WaktuPelayananSatuan = satuan
+		}
+	}
+	if standar.WaktuPelayananSatuan == "" {
+		standar.WaktuPelayananSatuan = SatuanHariKerja
+	}
+	if standar.WaktuPelayanan == "" && standar.WaktuPelayananNilai > 0 {
+		standar.WaktuPelayanan = strconv.Itoa(standar.WaktuPelayananNilai) + " " + labelSatuanWaktu(standar.WaktuPelayananSatuan)
+	}
+}
+
+// migrasiSLA mengisi durasi terstruktur untuk standar lama (dari teks WaktuPelayanan)
+// dan jatuh tempo untuk pengajuan lama yang belum memilikinya.
+func migrasiSLA() {
+	var standar []JenisPelayanan
+	DB.Where("waktu_pelayanan_nilai = 0 AND waktu_pelayanan <> ''").Find(&standar)
+	for _, s := range standar {
+		if nilai, satuan, ok := parseWaktuPelayanan(s.WaktuPelayanan); ok {
+			DB.Model(&JenisPelayanan{}).Where("id_jenis_pelayanan = ?", s.ID).
+				Updates(map[string]interface{}{"waktu_pelayanan_nilai": nilai, "waktu_pelayanan_satuan": satuan})
+		}
+	}
+
+	var forms []FormPengajuan
+	DB.Preload("JenisPelayanan").Where("tanggal_jatuh_tempo IS NULL").Where(kondisiPengajuanAktif).Find(&forms)
+	for _, f := range forms {
+		if jatuhTempo := hitungJatuhTempo(f.CreatedAt, f.JenisPelayanan.WaktuPelayananNilai, f.JenisPelayanan.WaktuPelayananSatuan); jatuhTempo != nil {
+			DB.Model(&FormPengajuan{}).Where("id_form_pengajuan = ?", f.ID).Update("tanggal_jatuh_tempo", jatuhTempo)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// ========= TES SLA & KALENDER HARI KERJA =========
+//
+// Tanggal acuan: Senin 27 Januari 2025, dengan Rabu 29 Januari 2025 sebagai hari libur.
+
+// tanggal membuat waktu UTC pada tanggal dan jam tertentu.
+func tanggal(tahun int, bulan time.Month, hari, jam int) time.Time {
+	return time.Date(tahun, bulan, hari, jam, 0, 0, 0, time.UTC)
+}
+
+// pakaiKalenderTes mengganti daftar libur kalenderKerja global selama tes berjalan.
+func pakaiKalenderTes(t *testing.T, libur map[string]string) {
+	t.Helper()
+	kalenderKerja.mu.RLock()
+	lama := kalenderKerja.libur
+	kalenderKerja.mu.RUnlock()
+	kalenderKerja.Set(libur)
+	t.Cleanup(func() { kalenderKerja.Set(lama) })
+}
+
+func TestParseWaktuPelayanan(t *testing.T) {
+	kasus := []struct {
+		teks   string
+		nilai  int
+		satuan string
+		ok     bool
+	}{
+		{"14 Hari Kerja", 14, SatuanHariKerja, true},
+		{"30 Hari Kerja (Verifikasi Lapangan)", 30, SatuanHariKerja, true},
+		{"3  HARI   KERJA", 3, SatuanHariKerja, true},
+		{"Paling lama 10 hari kerja sejak berkas lengkap", 10, SatuanHariKerja, true},
+		{"7 Hari Kalender", 7, SatuanHariKalender, true},
+		{"5 Hari", 5, SatuanHariKalender, true},
+		{"24 Jam", 24, SatuanJam, true},
+		{"", 0, "", false},
+		{"Sesuai ketentuan", 0, "", false},
+		{"Hari Kerja", 0, "", false},
+		{"0 Hari Kerja", 0, "", false},
+	}
+	for _, k := range kasus {
+		nilai, satuan, ok := parseWaktuPelayanan(k.teks)
+		if nilai != k.nilai || satuan != k.satuan || ok != k.ok {
+			t.Errorf("parseWaktuPelayanan(%q) = %d, %q, %v; ingin %d, %q, %v",
+				k.teks, nilai, satuan, ok, k.nilai, k.satuan, k.ok)
+		}
+	}
+}
+
+func TestHariKerjaAntara(t *testing.T) {
+	kalender := &KalenderKerja{libur: map[string]string{"2025-01-29": "Tahun Baru Imlek"}}
+	kasus := []struct {
+		nama         string
+		dari, sampai time.Time
+		ingin        int
+	}{
+		{"hari yang sama", tanggal(2025, 1, 27, 8), tanggal(2025, 1, 27, 16), 0},
+		{"melewati hari libur", tanggal(2025, 1, 27, 8), tanggal(2025, 1, 31, 8), 3},
+		{"melewati akhir pekan", tanggal(2025, 1, 24, 8), tanggal(2025, 1, 27, 8), 1},
+		{"hanya akhir pekan", tanggal(2025, 1, 25, 8), tanggal(2025, 1, 26, 8), 0},
+		{"mulai hari libur", tanggal(2025, 1, 29, 8), tanggal(2025, 1, 31, 8), 2},
+		{"jam diabaikan", tanggal(2025, 1, 27, 23), tanggal(2025, 1, 28, 1), 1},
+		{"terbalik", tanggal(2025, 1, 31, 8), tanggal(2025, 1, 27, 8), -3},
+	}
+	for _, k := range kasus {
+		if got := kalender.HariKerjaAntara(k.dari, k.sampai); got != k.ingin {
+			t.Errorf("%s: HariKerjaAntara(%s, %s) = %d; ingin %d",
+				k.nama, k.dari.Format("2006-01-02"), k.sampai.Format("2006-01-02"), got, k.ingin)
+		}
+	}
+}
+
+func TestHitungJatuhTempo(t *testing.T) {
+	pakaiKalenderTes(t, map[string]string{"2025-01-29": "Tahun Baru Imlek"})
+
+	akhir := func(hari int) time.Time { return time.Date(2025, 1, hari, 23, 59, 59, 0, time.UTC) }
+	kasus := []struct {
+		nama   string
+		mulai  time.Time
+		nilai  int
+		satuan string
+		ingin  *time.Time
+	}{
+		{"tanpa SLA", tanggal(2025, 1, 27, 8), 0, SatuanHariKerja, nil},
+		{"jam", tanggal(2025, 1, 27, 8), 4, SatuanJam, ptrWaktu(tanggal(2025, 1, 27, 12))},
+		{"hari kalender", tanggal(2025, 1, 27, 8), 3, SatuanHariKalender, ptrWaktu(akhir(30))},
+		{"hari kerja melewati hari libur", tanggal(2025, 1, 27, 8), 3, SatuanHariKerja, ptrWaktu(akhir(31))},
+		{"hari kerja melewati akhir pekan", tanggal(2025, 1, 24, 8), 1, SatuanHariKerja, ptrWaktu(akhir(27))},
+		{"mulai hari Sabtu", tanggal(2025, 1, 25, 8), 2, SatuanHariKerja, ptrWaktu(akhir(28))},
+		{"mulai hari libur", tanggal(2025, 1, 29, 8), 1, SatuanHariKerja, ptrWaktu(akhir(30))},
+		{"satuan kosong dianggap hari kerja", tanggal(2025, 1, 28, 8), 1, "", ptrWaktu(akhir(30))},
+	}
+	for _, k := range kasus {
+		got := hitungJatuhTempo(k.mulai, k.nilai, k.satuan)
+		switch {
+		case got == nil && k.ingin == nil:
+		case got == nil || k.ingin == nil || !got.Equal(*k.ingin):
+			t.Errorf("%s: hitungJatuhTempo = %v; ingin %v", k.nama, got, k.ingin)
+		}
+	}
+}
+
+// ptrWaktu mengembalikan pointer ke salinan t.
+func ptrWaktu(t time.Time) *time.Time { return &t }
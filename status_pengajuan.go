@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ========= STATUS PROSES FORM PENGAJUAN =========
+
+// Daftar status yang sah untuk FormPengajuan.StatusProses.
+const (
+	StatusBaru         = "Baru"
+	StatusDiverifikasi = "Diverifikasi"
+	StatusDiproses     = "Diproses"
+	StatusSelesai      = "Selesai"
+	StatusDitolak      = "Ditolak"
+	StatusDikembalikan = "Dikembalikan"
+)
+
+// aturanTransisi mendefinisikan satu perpindahan status yang diizinkan.
+type aturanTransisi struct {
+	Ke          string
+	Roles       []string // Role yang boleh melakukan transisi ini
+	WajibAlasan bool     // Alasan wajib diisi (penolakan / pengembalian)
+}
+
+// transisiStatus adalah state machine FormPengajuan:
+// Baru -> Diverifikasi -> Diproses -> Selesai, dengan cabang Ditolak / Dikembalikan.
+// Pengajuan yang Dikembalikan dapat diperbaiki lalu diajukan ulang menjadi Baru.
+var transisiStatus = map[string][]aturanTransisi{
+	StatusBaru: {
+		{Ke: StatusDiverifikasi, Roles: []string{"opd"}},
+		{Ke: StatusDitolak, Roles: []string{"opd", "pemda"}, WajibAlasan: true},
+		{Ke: StatusDikembalikan, Roles: []string{"opd", "pemda"}, WajibAlasan: true},
+	},
+	StatusDiverifikasi: {
+		{Ke: StatusDiproses, Roles: []string{"opd"}},
+		{Ke: StatusDitolak, Roles: []string{"opd", "pemda"}, WajibAlasan: true},
+		{Ke: StatusDikembalikan, Roles: []string{"opd", "pemda"}, WajibAlasan: true},
+	},
+	StatusDiproses: {
+		{Ke: StatusSelesai, Roles: []string{"opd"}},
+		{Ke: StatusDitolak, Roles: []string{"opd", "pemda"}, WajibAlasan: true},
+	},
+	StatusDikembalikan: {
+		{Ke: StatusBaru, Roles: []string{"opd"}},
+	},
+	// Selesai dan Ditolak adalah status akhir, tidak ada transisi keluar.
+}
+
+// cariAturanTransisi mengembalikan aturan untuk perpindahan dari -> ke, atau nil jika tidak sah.
+func cariAturanTransisi(dari, ke string) *aturanTransisi {
+	for _, aturan := range transisiStatus[dari] {
+		if aturan.Ke == ke {
+			return &aturan
+		}
+	}
+	return nil
+}
+
+// statusProsesSah memeriksa apakah status merupakan salah satu state di transisiStatus.
+func statusProsesSah(status string) bool {
+	for dari, daftar := range transisiStatus {
+		if dari == status {
+			return true
+		}
+		for _, aturan := range daftar {
+			if aturan.Ke == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// roleDiizinkan memeriksa apakah role termasuk dalam daftar role aturan.
+func (a *aturanTransisi) roleDiizinkan(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusBerikutnya mengembalikan status yang dapat dituju dari status saat ini oleh role tertentu.
+func StatusBerikutnya(status, role string) []string {
+	hasil := []string{}
+	for _, aturan := range transisiStatus[status] {
+		if aturan.roleDiizinkan(role) {
+			hasil = append(hasil, aturan.Ke)
+		}
+	}
+	return hasil
+}
+
+// statusDapatDiubah: data pengajuan hanya boleh diedit/dihapus sebelum diverifikasi
+// atau ketika dikembalikan ke pemohon untuk diperbaiki.
+func statusDapatDiubah(status string) bool {
+	return status == StatusBaru || status == StatusDikembalikan
+}
+
+// TransitionFormPengajuan: Memindahkan status proses sebuah pengajuan sesuai state machine
+func TransitionFormPengajuan(c *gin.Context) {
+	id := c.Param("id")
+	var form FormPengajuan
+
+	// 1. Cari pengajuan
+	if err := DB.Where(kondisiPengajuanAktif).First(&form, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Data pengajuan tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 2. Bind request body
+	var req TransisiStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+	req.Alasan = strings.TrimSpace(req.Alasan)
+
+	claims := ambilClaims(c)
+
+	// 3. OPD hanya boleh memproses pengajuan yang ditujukan ke OPD-nya sendiri
+	if !cekMilikOPD(c, form.IDOPD) {
+		return
+	}
+
+	// 4. Cek apakah transisi sah dan role diizinkan
+	aturan := cariAturanTransisi(form.StatusProses, req.Status)
+	if aturan == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "Transisi status dari '" + form.StatusProses + "' ke '" + req.Status + "' tidak diizinkan",
+			"status_berikutnya": StatusBerikutnya(form.StatusProses, claims.Role),
+		})
+		return
+	}
+	if !aturan.roleDiizinkan(claims.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Role Anda tidak diizinkan mengubah status menjadi '" + req.Status + "'"})
+		return
+	}
+	if aturan.WajibAlasan && req.Alasan == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alasan wajib diisi untuk status '" + req.Status + "'"})
+		return
+	}
+
+	// 5. Update status dan catat riwayatnya dalam satu transaksi
+	statusLama := form.StatusProses
+	form.StatusProses = req.Status
+	if req.Alasan != "" {
+		form.KeteranganStatus = &req.Alasan
+	} else {
+		form.KeteranganStatus = nil
+	}
+
+	// Catat waktu selesai untuk penilaian SLA pada status akhir
+	if form.StatusProses == StatusSelesai || form.StatusProses == StatusDitolak {
+		now := time.Now()
+		form.TanggalSelesai = &now
+	}
+
+	var belum []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Pengajuan baru dapat diverifikasi setelah semua persyaratan wajib diperiksa dan sesuai.
+		// Slot dikunci FOR SHARE agar verifikasinya tidak dapat diubah sebelum transaksi ini selesai.
+		if form.StatusProses == StatusDiverifikasi {
+			var slot []PersyaratanPengajuan
+			if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+				Where("id_form_pengajuan = ?", form.ID).Find(&slot).Error; err != nil {
+				return err
+			}
+			var err error
+			if belum, err = persyaratanBelumTerpenuhi(tx, form.ID); err != nil {
+				return err
+			}
+			if len(belum) > 0 {
+				return errParameter("Persyaratan wajib belum terverifikasi")
+			}
+		}
+
+		// Update bersyarat: gagal jika status sudah diubah request lain sejak pengajuan dibaca
+		hasil := tx.Model(&FormPengajuan{}).
+			Where("id_form_pengajuan = ? AND status_proses = ?", form.ID, statusLama).
+			Where(kondisiPengajuanAktif).
+			Updates(map[string]interface{}{
+				"status_proses":     form.StatusProses,
+				"keterangan_status": form.KeteranganStatus,
+				"tanggal_selesai":   form.TanggalSelesai,
+			})
+		if hasil.Error != nil {
+			return hasil.Error
+		}
+		if hasil.RowsAffected == 0 {
+			return errKonflik("Status pengajuan sudah diubah menjadi status lain; muat ulang data")
+		}
+		return catatRiwayatPengajuan(tx, claims, form.ID, &statusLama, form.StatusProses, req.Alasan)
+	})
+	if err != nil {
+		if len(belum) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":                       err.Error(),
+				"persyaratan_belum_terpenuhi": belum,
+			})
+			return
+		}
+		if statusUntukError(err) == http.StatusConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui status"})
+		return
+	}
+
+	// 6. Response
+	DB.Preload("UserOPD.OPD").Preload("JenisPelayanan.OPD").Preload("OPD").First(&form, form.ID)
+	form.StatusBerikutnya = StatusBerikutnya(form.StatusProses, claims.Role)
+	c.JSON(http.StatusOK, form)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========= CAKUPAN DATA PER OPD (TENANT SCOPING) =========
+//
+// Data OPD (standar pelayanan, pengajuan, pemohon) hanya boleh diakses oleh user
+// dari OPD pemiliknya. OPD selalu diambil dari Claims.IDOPD, tidak pernah dari URL
+// atau body request. User Pemda tidak dibatasi OPD; hak aksesnya diatur lewat izin RBAC.
+
+// pesanBedaOPD adalah pesan standar untuk akses lintas OPD.
+const pesanBedaOPD = "Anda tidak memiliki hak akses ke data milik OPD lain"
+
+// ambilClaims mengambil Claims user yang login dari context (diisi AuthMiddleware).
+func ambilClaims(c *gin.Context) *Claims {
+	userClaims, exists := c.Get("user")
+	if !exists {
+		return nil
+	}
+	return userClaims.(*Claims)
+}
+
+// opdTidakDikenal dikembalikan opdPengguna untuk user OPD yang claims-nya tidak
+// memuat ID OPD. Nilainya tidak pernah sama dengan ID OPD mana pun sehingga
+// cekMilikOPD dan cakupanOPD menolak akses (fail closed), bukan membukanya.
+const opdTidakDikenal = ^uint(0)
+
+// opdPengguna mengembalikan ID OPD user jika login sebagai user OPD (atau memakai
+// API key yang dibatasi ke satu OPD), atau 0 jika user tidak dibatasi OPD (Pemda).
+// User OPD tanpa ID OPD mendapat opdTidakDikenal.
+func opdPengguna(c *gin.Context) uint {
+	claims := ambilClaims(c)
+	if claims == nil || (claims.Role != "opd" && claims.IDAPIKey == 0) {
+		return 0
+	}
+	if claims.IDOPD == 0 {
+		if claims.Role == "opd" {
+			return opdTidakDikenal
+		}
+		return 0
+	}
+	return claims.IDOPD
+}
+
+// cakupanOPD membatasi query pada OPD user (kolom = Claims.IDOPD) untuk user OPD.
+func cakupanOPD(c *gin.Context, query *gorm.DB, kolom string) *gorm.DB {
+	switch idOPD := opdPengguna(c); idOPD {
+	case 0:
+		return query
+	case opdTidakDikenal:
+		return query.Where("1 = 0")
+	default:
+		return query.Where(kolom+" = ?", idOPD)
+	}
+}
+
+// cekMilikOPD memastikan data dengan idOPD boleh diakses user. Jika tidak,
+// respons 403 langsung dikirim dan fungsi mengembalikan false.
+func cekMilikOPD(c *gin.Context, idOPD uint) bool {
+	if opd := opdPengguna(c); opd != 0 && opd != idOPD {
+		c.JSON(http.StatusForbidden, gin.H{"error": pesanBedaOPD})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========= TES CAKUPAN DATA PER OPD =========
+//
+// Tes tanpa database memastikan claims OPD tanpa ID OPD ditolak (fail closed) dan
+// setiap route OPD tercantum di rutePerOPD. Tes integrasi memanggil semua route itu
+// dengan token user OPD A terhadap data OPD B; hanya dijalankan jika TEST_DB_NAME
+// diisi dengan nama database Postgres khusus tes (DB_HOST, DB_USER, dst. dari env).
+
+// ruteOPD adalah satu route yang datanya dibatasi per OPD. Parameter di Pola diganti
+// dengan ID data milik OPD B (lihat dataOPD.url).
+type ruteOPD struct {
+	Metode string
+	Pola   string
+	Body   string // JSON; kosong jika tidak ada body
+	Berkas bool   // Kirim multipart/form-data (lihat bodyMultipart)
+}
+
+var rutePerOPD = []ruteOPD{
+	{Metode: "GET", Pola: "/api/standar-pelayanan/opd/:id_opd"},
+	{Metode: "PUT", Pola: "/api/standar-pelayanan/:id", Body: `{"nama_standar":"Diubah OPD lain"}`},
+	{Metode: "DELETE", Pola: "/api/standar-pelayanan/:id"},
+	{Metode: "POST", Pola: "/api/standar-pelayanan/:id/pulihkan"},
+	{Metode: "POST", Pola: "/api/standar-pelayanan/:id/tarik"},
+	{Metode: "POST", Pola: "/api/standar-pelayanan/:id/validate", Body: `{"status_validasi":"Disetujui"}`},
+	{Metode: "POST", Pola: "/api/standar-pelayanan/:id/ajukan-ulang", Body: `{}`},
+	{Metode: "GET", Pola: "/api/standar-pelayanan/:id/komentar-revisi"},
+	{Metode: "GET", Pola: "/api/standar-pelayanan/:id/versions"},
+	{Metode: "POST", Pola: "/api/standar-pelayanan/:id/versions", Body: `{"nama_standar":"Versi OPD lain"}`},
+	{Metode: "GET", Pola: "/api/standar-pelayanan/:id/versions/diff"},
+	{Metode: "GET", Pola: "/api/standar-pelayanan/:id/versions/:nomor"},
+	{Metode: "GET", Pola: "/api/user/:id/pengajuan"},
+	{Metode: "POST", Pola: "/api/pengajuan", Berkas: true},
+	{Metode: "GET", Pola: "/api/pengajuan/:id"},
+	{Metode: "PUT", Pola: "/api/pengajuan/:id", Berkas: true},
+	{Metode: "DELETE", Pola: "/api/pengajuan/:id"},
+	{Metode: "POST", Pola: "/api/pengajuan/:id/transition", Body: `{"status":"Diverifikasi"}`},
+	{Metode: "GET", Pola: "/api/pengajuan/:id/timeline"},
+	{Metode: "GET", Pola: "/api/pengajuan/:id/persyaratan"},
+	{Metode: "POST", Pola: "/api/pengajuan/:id/persyaratan/:id_slot/dokumen", Berkas: true},
+	{Metode: "PUT", Pola: "/api/pengajuan/:id/persyaratan/:id_slot/verifikasi", Body: `{"status":"Terverifikasi"}`},
+	{Metode: "GET", Pola: "/api/pengajuan/:id/lampiran"},
+	{Metode: "POST", Pola: "/api/pengajuan/:id/lampiran", Berkas: true},
+	{Metode: "GET", Pola: "/api/pengajuan/:id/lampiran/:id_lampiran/unduh"},
+	{Metode: "DELETE", Pola: "/api/pengajuan/:id/lampiran/:id_lampiran"},
+	{Metode: "GET", Pola: "/api/form-pemohon/:id"},
+	{Metode: "PUT", Pola: "/api/form-pemohon/:id", Body: `{"nama_lengkap":"Diubah OPD lain","nik":"0000000000000000"}`},
+	{Metode: "DELETE", Pola: "/api/form-pemohon/:id"},
+}
+
+// prefiksRuteOPD adalah awalan route yang datanya milik satu OPD.
+var prefiksRuteOPD = []string{"/api/standar-pelayanan/", "/api/pengajuan", "/api/user/", "/api/form-pemohon/"}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// konteksDenganClaims membuat gin.Context tes yang sudah berisi claims user login.
+func konteksDenganClaims(claims *Claims) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	if claims != nil {
+		c.Set("user", claims)
+	}
+	return c, w
+}
+
+func TestOPDPenggunaTanpaIDOPDDitolak(t *testing.T) {
+	c, w := konteksDenganClaims(&Claims{Role: "opd", Peran: PeranOperatorOPD})
+
+	if got := opdPengguna(c); got != opdTidakDikenal {
+		t.Fatalf("opdPengguna = %d, ingin opdTidakDikenal", got)
+	}
+	for _, idOPD := range []uint{0, 1, 42} {
+		if cekMilikOPD(c, idOPD) {
+			t.Fatalf("cekMilikOPD(%d) mengizinkan user OPD tanpa ID OPD", idOPD)
+		}
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, ingin %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestOPDPenggunaSesuaiClaims(t *testing.T) {
+	tests := []struct {
+		nama   string
+		claims *Claims
+		ingin  uint
+	}{
+		{"tanpa login", nil, 0},
+		{"user pemda", &Claims{Role: "pemda", Peran: PeranValidator}, 0},
+		{"user opd", &Claims{Role: "opd", IDOPD: 7}, 7},
+		{"api key pemda tanpa OPD", &Claims{Role: "pemda", IDAPIKey: 3}, 0},
+		{"api key pemda dibatasi OPD", &Claims{Role: "pemda", IDAPIKey: 3, IDOPD: 5}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nama, func(t *testing.T) {
+			c, _ := konteksDenganClaims(tt.claims)
+			if got := opdPengguna(c); got != tt.ingin {
+				t.Fatalf("opdPengguna = %d, ingin %d", got, tt.ingin)
+			}
+		})
+	}
+}
+
+func TestCekMilikOPD(t *testing.T) {
+	c, _ := konteksDenganClaims(&Claims{Role: "opd", IDOPD: 7})
+	if !cekMilikOPD(c, 7) {
+		t.Fatal("user OPD ditolak mengakses data OPD-nya sendiri")
+	}
+	c, w := konteksDenganClaims(&Claims{Role: "opd", IDOPD: 7})
+	if cekMilikOPD(c, 8) || w.Code != http.StatusForbidden {
+		t.Fatalf("user OPD 7 dapat mengakses data OPD 8 (status %d)", w.Code)
+	}
+	c, _ = konteksDenganClaims(&Claims{Role: "pemda"})
+	if !cekMilikOPD(c, 8) {
+		t.Fatal("user Pemda ditolak mengakses data OPD")
+	}
+}
+
+// TestRutePerOPDLengkap memastikan route OPD baru ikut diuji oleh TestAksesLintasOPD.
+func TestRutePerOPDLengkap(t *testing.T) {
+	tercakup := map[string]bool{}
+	for _, rt := range rutePerOPD {
+		tercakup[rt.Metode+" "+rt.Pola] = true
+	}
+	for _, r := range siapkanRouter().Routes() {
+		if !strings.Contains(r.Path, ":") && r.Path != "/api/pengajuan" {
+			continue
+		}
+		for _, p := range prefiksRuteOPD {
+			if strings.HasPrefix(r.Path, p) && !tercakup[r.Method+" "+r.Path] {
+				if r.Method == "GET" && r.Path == "/api/pengajuan" { // Khusus Pemda (pengajuan.read_all)
+					continue
+				}
+				t.Errorf("route %s %s belum tercantum di rutePerOPD", r.Method, r.Path)
+			}
+		}
+	}
+}
+
+// dataOPD adalah data tes milik satu OPD beserta token user-nya.
+type dataOPD struct {
+	OPD      OPD
+	User     UserOPD
+	Standar  JenisPelayanan
+	Form     FormPengajuan
+	Slot     PersyaratanPengajuan
+	Lampiran LampiranPengajuan
+	Pemohon  FormPemohon
+	Token    string
+}
+
+// url mengganti parameter pada pola route dengan ID data milik OPD ini.
+func (d *dataOPD) url(pola string) string {
+	id := d.Form.ID
+	switch {
+	case strings.HasPrefix(pola, "/api/standar-pelayanan/"):
+		id = d.Standar.ID
+	case strings.HasPrefix(pola, "/api/user/"):
+		id = d.User.ID
+	case strings.HasPrefix(pola, "/api/form-pemohon/"):
+		id = d.Pemohon.ID
+	}
+	return strings.NewReplacer(
+		":id_opd", strconv.FormatUint(uint64(d.OPD.ID), 10),
+		":id_slot", strconv.FormatUint(uint64(d.Slot.ID), 10),
+		":id_lampiran", strconv.FormatUint(uint64(d.Lampiran.ID), 10),
+		":nomor", "1",
+		":id", strconv.FormatUint(uint64(id), 10),
+	).Replace(pola)
+}
+
+// bodyMultipart menyusun form pengajuan dan berkas yang merujuk data OPD ini.
+func (d *dataOPD) bodyMultipart(t *testing.T) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	isian := map[string]string{
+		"id_jenis_pelayanan":       strconv.FormatUint(uint64(d.Standar.ID), 10),
+		"nama_pemohon_lengkap":     "Pemohon Lintas OPD",
+		"nik_pemohon":              "3200000000000001",
+		"judul_pengajuan":          "Pengajuan Lintas OPD",
+		"is_agreed":                "true",
+		"jenis":                    JenisLampiranPendukung,
+		"id_persyaratan_pengajuan": strconv.FormatUint(uint64(d.Slot.ID), 10),
+	}
+	for k, v := range isian {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, field := range []string{"dokumen_pengajuan", "dokumen", "berkas"} {
+		fw, err := mw.CreateFormFile(field, "lintas.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte("%PDF-1.4 tes"))
+	}
+	mw.Close()
+	return &buf, mw.FormDataContentType()
+}
+
+// siapkanDataOPD membuat OPD, user Kepala OPD, standar, pengajuan, slot persyaratan,
+// lampiran, dan pemohon, lalu menerbitkan access token untuk user tersebut.
+func siapkanDataOPD(t *testing.T, nama string) *dataOPD {
+	t.Helper()
+	sufiks := nama + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	d := &dataOPD{OPD: OPD{NamaOPD: "OPD Tes " + sufiks}}
+	wajib := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wajib(DB.Create(&d.OPD).Error)
+	akun := Akun{NIP: "tes-" + sufiks, Password: "-"}
+	t.Cleanup(func() { hapusDataOPD(t, d.OPD.ID, akun.NIP) })
+	wajib(DB.Create(&akun).Error)
+	d.User = UserOPD{IDOPD: d.OPD.ID, Nama: "User " + sufiks, NIP: akun.NIP, IDAkun: akun.ID, KodePeran: PeranKepalaOPD, Aktif: true}
+	wajib(DB.Create(&d.User).Error)
+
+	d.Standar = JenisPelayanan{IDOPD: d.OPD.ID, NamaStandar: "Standar " + sufiks, StatusValidasi: StatusValidasiDisetujui, VersiAktif: 1, VersiTerbaru: 1}
+	wajib(DB.Create(&d.Standar).Error)
+	wajib(DB.Create(&VersiStandar{IDJenisPelayanan: d.Standar.ID, Nomor: 1, KontenStandar: KontenStandar{NamaStandar: d.Standar.NamaStandar},
+		StatusValidasi: StatusValidasiDisetujui}).Error)
+
+	d.Form = FormPengajuan{IDOPD: d.OPD.ID, IDJenisPelayanan: d.Standar.ID, IDUserOPD: d.User.ID, NamaPemohonLengkap: "Pemohon " + sufiks,
+		NIKPemohon: "1", JudulPengajuan: "Pengajuan " + sufiks, StatusProses: StatusBaru}
+	wajib(DB.Create(&d.Form).Error)
+	d.Slot = PersyaratanPengajuan{IDFormPengajuan: d.Form.ID, Nama: "KTP", Wajib: true, StatusVerifikasi: StatusVerifBelum}
+	wajib(DB.Create(&d.Slot).Error)
+	d.Lampiran = LampiranPengajuan{IDFormPengajuan: d.Form.ID, Jenis: JenisLampiranLainnya, Versi: 1, NamaFile: "tes.pdf",
+		Path: "uploads/tidak-ada-" + sufiks + ".pdf", DiunggahPada: time.Now()}
+	wajib(DB.Create(&d.Lampiran).Error)
+	d.Pemohon = FormPemohon{IDUserOPDInput: d.User.ID, IDOPD: d.OPD.ID, NamaLengkap: "Pemohon " + sufiks, NIK: "nik-" + sufiks}
+	wajib(DB.Create(&d.Pemohon).Error)
+
+	peran, idAkun, err := peranUntukUser("opd", d.User.ID)
+	wajib(err)
+	sesi := Sesi{IDAkun: idAkun, Role: "opd", IDUser: d.User.ID, RefreshTokenHash: hashToken(sufiks), KedaluwarsaPada: time.Now().Add(time.Hour)}
+	wajib(DB.Create(&sesi).Error)
+	d.Token, err = terbitkanAccessToken(peran.claims(idAkun), &sesi)
+	wajib(err)
+	return d
+}
+
+// hapusDataOPD menghapus semua data yang dibuat siapkanDataOPD untuk satu OPD, dari tabel
+// anak ke induk agar foreign key terpenuhi.
+func hapusDataOPD(t *testing.T, idOPD uint, nip string) {
+	pengajuan := DB.Model(&FormPengajuan{}).Select("id_form_pengajuan").Where("id_opd = ?", idOPD)
+	standar := DB.Model(&JenisPelayanan{}).Select("id_jenis_pelayanan").Where("id_opd = ?", idOPD)
+	versi := DB.Model(&VersiStandar{}).Select("id_versi_standar").Where("id_jenis_pelayanan IN (?)", standar)
+	akun := DB.Model(&Akun{}).Select("id_akun").Where("nip = ?", nip)
+	for _, h := range []struct {
+		model   any
+		kondisi string
+		arg     any
+	}{
+		{&Sesi{}, "id_akun IN (?)", akun},
+		{&FormPemohon{}, "id_opd = ?", idOPD},
+		{&LampiranPengajuan{}, "id_form_pengajuan IN (?)", pengajuan},
+		{&PersyaratanPengajuan{}, "id_form_pengajuan IN (?)", pengajuan},
+		{&RiwayatPengajuan{}, "id_form_pengajuan IN (?)", pengajuan},
+		{&FormPengajuan{}, "id_opd = ?", idOPD},
+		{&KomentarRevisi{}, "id_jenis_pelayanan IN (?)", standar},
+		{&ItemPersyaratan{}, "id_versi_standar IN (?)", versi},
+		{&VersiStandar{}, "id_jenis_pelayanan IN (?)", standar},
+		{&JenisPelayanan{}, "id_opd = ?", idOPD},
+		{&UserOPD{}, "id_opd = ?", idOPD},
+		{&Akun{}, "nip = ?", nip},
+		{&OPD{}, "id_opd = ?", idOPD},
+	} {
+		if err := DB.Where(h.kondisi, h.arg).Delete(h.model).Error; err != nil {
+			t.Errorf("gagal menghapus data tes %T: %v", h.model, err)
+		}
+	}
+}
+
+// panggil mengirim request ke router dengan token Bearer (tanpa cookie, sehingga bebas CSRF).
+func panggil(t *testing.T, r *gin.Engine, token string, rt ruteOPD, data *dataOPD) *httptest.ResponseRecorder {
+	t.Helper()
+	var req *http.Request
+	switch {
+	case rt.Berkas:
+		body, tipe := data.bodyMultipart(t)
+		req = httptest.NewRequest(rt.Metode, data.url(rt.Pola), body)
+		req.Header.Set("Content-Type", tipe)
+	case rt.Body != "":
+		req = httptest.NewRequest(rt.Metode, data.url(rt.Pola), strings.NewReader(rt.Body))
+		req.Header.Set("Content-Type", "application/json")
+	default:
+		req = httptest.NewRequest(rt.Metode, data.url(rt.Pola), nil)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAksesLintasOPD(t *testing.T) {
+	namaDB := os.Getenv("TEST_DB_NAME")
+	if namaDB == "" {
+		t.Skip("TEST_DB_NAME tidak diisi; tes integrasi cakupan OPD dilewati")
+	}
+	os.Setenv("DB_NAME", namaDB)
+	if os.Getenv("JWT_SECRET_KEY") == "" {
+		os.Setenv("JWT_SECRET_KEY", "rahasia-tes-cakupan-opd-minimal-32-byte")
+	}
+	InitDB()
+	siapkanKunciJWT()
+
+	r := siapkanRouter()
+	a := siapkanDataOPD(t, "a")
+	b := siapkanDataOPD(t, "b")
+
+	for _, rt := range rutePerOPD {
+		t.Run(rt.Metode+" "+rt.Pola, func(t *testing.T) {
+			w := panggil(t, r, a.Token, rt, b)
+			if w.Code != http.StatusForbidden && w.Code != http.StatusNotFound {
+				t.Fatalf("user OPD A mendapat status %d untuk data OPD B: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// Kontrol positif: pemilik data tetap dapat membacanya
+	for _, pola := range []string{"/api/pengajuan/:id", "/api/form-pemohon/:id", "/api/standar-pelayanan/:id/versions"} {
+		if w := panggil(t, r, b.Token, ruteOPD{Metode: "GET", Pola: pola}, b); w.Code != http.StatusOK {
+			t.Errorf("user OPD B mendapat status %d untuk datanya sendiri di %s: %s", w.Code, pola, w.Body.String())
+		}
+	}
+
+	// Daftar dan pencarian tidak boleh memuat data OPD B
+	for _, pola := range []string{"/api/form-pemohon/", "/api/user/" + strconv.FormatUint(uint64(a.User.ID), 10) + "/pengajuan",
+		"/api/search?q=" + strings.TrimPrefix(b.Form.JudulPengajuan, "Pengajuan ")} {
+		w := panggil(t, r, a.Token, ruteOPD{Metode: "GET", Pola: pola}, a)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d: %s", pola, w.Code, w.Body.String())
+			continue
+		}
+		var isi any
+		if err := json.Unmarshal(w.Body.Bytes(), &isi); err != nil {
+			t.Fatalf("GET %s: respons bukan JSON: %v", pola, err)
+		}
+		if teks := w.Body.String(); strings.Contains(teks, b.Pemohon.NIK) || strings.Contains(teks, b.Form.JudulPengajuan) {
+			t.Errorf("GET %s memuat data OPD B: %s", pola, teks)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module testoidc
+
+go 1.25.1
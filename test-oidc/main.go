@@ -0,0 +1,231 @@
+// Mock identity provider OpenID Connect untuk menguji login SSO secara lokal.
+//
+// Menjalankan:
+//
+//	cd test-oidc && go run .
+//
+// Lalu set di .env backend:
+//
+//	OIDC_ISSUER=http://localhost:9000
+//	OIDC_CLIENT_ID=db-bappeda
+//	OIDC_REDIRECT_URL=http://localhost:8080/api/oidc/callback
+//
+// Halaman /authorize menampilkan form NIP; NIP yang diisi dikirim sebagai klaim "nip"
+// dan "sub" = "mock-<nip>". Tidak ada password: hanya untuk pengujian.
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const kid = "mock-1"
+
+var (
+	issuer    string
+	kunci     *rsa.PrivateKey
+	mu        sync.Mutex
+	kodeAktif = map[string]permintaanAuth{}
+)
+
+// permintaanAuth adalah data authorization request yang menunggu ditukar di /token.
+type permintaanAuth struct {
+	NIP           string
+	ClientID      string
+	RedirectURI   string
+	Nonce         string
+	CodeChallenge string
+	Kedaluwarsa   time.Time
+}
+
+func main() {
+	port := os.Getenv("MOCK_OIDC_PORT")
+	if port == "" {
+		port = "9000"
+	}
+	issuer = "http://localhost:" + port
+
+	var err error
+	if kunci, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		log.Fatal(err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", discovery)
+	http.HandleFunc("/jwks.json", jwks)
+	http.HandleFunc("/authorize", authorize)
+	http.HandleFunc("/token", token)
+
+	log.Println("Mock OIDC berjalan di", issuer)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func tulisJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func discovery(w http.ResponseWriter, r *http.Request) {
+	tulisJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func jwks(w http.ResponseWriter, r *http.Request) {
+	pub := kunci.PublicKey
+	tulisJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+var formLogin = template.Must(template.New("login").Parse(`<!doctype html>
+<html><body style="font-family:sans-serif;max-width:420px;margin:80px auto">
+<h2>Mock SSO</h2>
+<form method="post">
+  <label>NIP <input name="nip" autofocus required></label>
+  <button type="submit">Masuk</button>
+</form>
+</body></html>`))
+
+// authorize menampilkan form NIP (GET) lalu mengarahkan kembali ke redirect_uri dengan code (POST).
+// ?nip= pada URL langsung menyetujui tanpa form, berguna untuk pengujian otomatis.
+func authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "hanya response_type=code dengan PKCE S256 yang didukung", http.StatusBadRequest)
+		return
+	}
+	nip := q.Get("nip")
+	if r.Method == http.MethodPost {
+		r.ParseForm()
+		nip = r.PostForm.Get("nip")
+	}
+	if nip == "" {
+		formLogin.Execute(w, nil)
+		return
+	}
+
+	kode := acak()
+	mu.Lock()
+	kodeAktif[kode] = permintaanAuth{
+		NIP:           nip,
+		ClientID:      q.Get("client_id"),
+		RedirectURI:   q.Get("redirect_uri"),
+		Nonce:         q.Get("nonce"),
+		CodeChallenge: q.Get("code_challenge"),
+		Kedaluwarsa:   time.Now().Add(time.Minute),
+	}
+	mu.Unlock()
+
+	tujuan, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "redirect_uri tidak valid", http.StatusBadRequest)
+		return
+	}
+	v := tujuan.Query()
+	v.Set("code", kode)
+	v.Set("state", q.Get("state"))
+	tujuan.RawQuery = v.Encode()
+	http.Redirect(w, r, tujuan.String(), http.StatusFound)
+}
+
+// token menukar code (sekali pakai) dengan ID token setelah memeriksa PKCE.
+func token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	kode := r.PostForm.Get("code")
+	mu.Lock()
+	req, ada := kodeAktif[kode]
+	delete(kodeAktif, kode)
+	mu.Unlock()
+
+	gagal := func(pesan string) {
+		tulisJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": pesan})
+	}
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		gagal("grant_type harus authorization_code")
+		return
+	case !ada || time.Now().After(req.Kedaluwarsa):
+		gagal("code tidak valid atau kedaluwarsa")
+		return
+	case r.PostForm.Get("client_id") != req.ClientID || r.PostForm.Get("redirect_uri") != req.RedirectURI:
+		gagal("client_id / redirect_uri tidak cocok")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != req.CodeChallenge {
+		gagal("code_verifier tidak cocok")
+		return
+	}
+
+	now := time.Now()
+	idToken, err := tandatangani(map[string]interface{}{
+		"iss":   issuer,
+		"sub":   "mock-" + req.NIP,
+		"aud":   req.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": req.Nonce,
+		"nip":   req.NIP,
+		"name":  "User Mock " + req.NIP,
+	})
+	if err != nil {
+		tulisJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	tulisJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": acak(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// tandatangani membuat JWT RS256 tanpa pustaka tambahan.
+func tandatangani(klaim map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, err := json.Marshal(klaim)
+	if err != nil {
+		return "", err
+	}
+	masukan := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(masukan))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, kunci, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return masukan + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func acak() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,568 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ========= AUTENTIKASI DUA FAKTOR (TOTP, RFC 6238) =========
+//
+// 2FA bersifat opsional per akun, namun admin dapat mewajibkannya per peran
+// (Peran.Wajib2FA). User dengan peran wajib 2FA yang belum mengaktifkannya tetap
+// dapat login, tetapi access token-nya ditandai Terbatas sehingga RequirePermission
+// menolak semua aksi sampai 2FA diaktifkan lewat /api/me/2fa.
+
+const (
+	periodeTOTP         = 30 // detik
+	digitTOTP           = 6
+	toleransiTOTP       = 1 // Jumlah langkah sebelum/sesudah yang masih diterima (selisih jam)
+	jumlahKodePemulihan = 10
+	tujuan2FA           = "2fa"
+)
+
+var base32TanpaPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// buatRahasiaTOTP membuat rahasia acak 160 bit dalam Base32 (format yang dipahami aplikasi authenticator).
+func buatRahasiaTOTP() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32TanpaPadding.EncodeToString(b), nil
+}
+
+// kodeTOTP menghitung kode TOTP (HMAC-SHA1, 6 digit) untuk langkah waktu tertentu.
+func kodeTOTP(rahasia string, langkah int64) (string, error) {
+	kunci, err := base32TanpaPadding.DecodeString(strings.ToUpper(rahasia))
+	if err != nil {
+		return "", err
+	}
+	var pesan [8]byte
+	binary.BigEndian.PutUint64(pesan[:], uint64(langkah))
+	mac := hmac.New(sha1.New, kunci)
+	mac.Write(pesan[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	nilai := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digitTOTP, nilai%1000000), nil
+}
+
+// cocokTOTP mengembalikan langkah waktu yang cocok dengan kode, atau 0 jika tidak ada yang cocok.
+func cocokTOTP(rahasia, kode string, now time.Time) int64 {
+	sekarang := now.Unix() / periodeTOTP
+	for d := int64(-toleransiTOTP); d <= toleransiTOTP; d++ {
+		harapan, err := kodeTOTP(rahasia, sekarang+d)
+		if err != nil {
+			return 0
+		}
+		if subtle.ConstantTimeCompare([]byte(harapan), []byte(kode)) == 1 {
+			return sekarang + d
+		}
+	}
+	return 0
+}
+
+// uriProvisioning membentuk URI otpauth:// untuk ditampilkan sebagai QR code.
+// Nama penerbit diambil dari env TOTP_ISSUER (default "BAPPEDA").
+func uriProvisioning(rahasia, nip string) string {
+	penerbit := os.Getenv("TOTP_ISSUER")
+	if penerbit == "" {
+		penerbit = "BAPPEDA"
+	}
+	q := url.Values{}
+	q.Set("secret", rahasia)
+	q.Set("issuer", penerbit)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(digitTOTP))
+	q.Set("period", strconv.Itoa(periodeTOTP))
+	label := url.PathEscape(penerbit + ":" + nip)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// normalisasiKode menghapus spasi dan tanda hubung agar kode dapat diketik bebas.
+func normalisasiKode(kode string) string {
+	kode = strings.ToLower(strings.TrimSpace(kode))
+	return strings.NewReplacer(" ", "", "-", "").Replace(kode)
+}
+
+// adalahKodeTOTP memeriksa apakah kode berbentuk 6 digit angka.
+func adalahKodeTOTP(kode string) bool {
+	if len(kode) != digitTOTP {
+		return false
+	}
+	for _, r := range kode {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// buatKodePemulihan mengganti seluruh kode pemulihan akun dan mengembalikan kode baru (format "xxxx-xxxx").
+// Kode hanya ditampilkan sekali; yang disimpan hanya hash-nya.
+func buatKodePemulihan(tx *gorm.DB, idAkun uint) ([]string, error) {
+	if err := tx.Where("id_akun = ?", idAkun).Delete(&KodePemulihan{}).Error; err != nil {
+		return nil, err
+	}
+	daftar := make([]string, 0, jumlahKodePemulihan)
+	for i := 0; i < jumlahKodePemulihan; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		acak := strings.ToLower(base32TanpaPadding.EncodeToString(b)) // 8 karakter
+		kode := acak[:4] + "-" + acak[4:]
+		if err := tx.Create(&KodePemulihan{IDAkun: idAkun, KodeHash: hashToken(normalisasiKode(kode))}).Error; err != nil {
+			return nil, err
+		}
+		daftar = append(daftar, kode)
+	}
+	return daftar, nil
+}
+
+// pakaiTOTP memverifikasi kode TOTP akun dan mencatat langkahnya agar kode yang sama
+// tidak dapat dipakai ulang (termasuk oleh dua request bersamaan).
+func pakaiTOTP(tx *gorm.DB, akun *Akun, kode string) (bool, error) {
+	if akun.TOTPRahasia == "" || !adalahKodeTOTP(kode) {
+		return false, nil
+	}
+	langkah := cocokTOTP(akun.TOTPRahasia, kode, time.Now())
+	if langkah == 0 || langkah <= akun.TOTPLangkahTerakhir {
+		return false, nil
+	}
+	res := tx.Model(&Akun{}).
+		Where("id_akun = ? AND totp_langkah_terakhir < ?", akun.ID, langkah).
+		Update("totp_langkah_terakhir", langkah)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	akun.TOTPLangkahTerakhir = langkah
+	return res.RowsAffected == 1, nil
+}
+
+// verifikasiFaktorKedua menerima kode TOTP atau kode pemulihan (sekali pakai) untuk akun dengan 2FA aktif.
+func verifikasiFaktorKedua(tx *gorm.DB, akun *Akun, kode string) (bool, error) {
+	kode = normalisasiKode(kode)
+	if !akun.TOTPAktif {
+		return false, nil
+	}
+	if adalahKodeTOTP(kode) {
+		return pakaiTOTP(tx, akun, kode)
+	}
+	res := tx.Model(&KodePemulihan{}).
+		Where("id_akun = ? AND kode_hash = ? AND dipakai_pada IS NULL", akun.ID, hashToken(kode)).
+		Update("dipakai_pada", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		log.Println("[2FA] Kode pemulihan dipakai, akun:", akun.ID)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// peranWajib2FA memeriksa apakah peran RBAC mewajibkan 2FA.
+func peranWajib2FA(kodePeran string) bool {
+	var peran Peran
+	if err := DB.Select("wajib_2fa").First(&peran, "kode = ?", kodePeran).Error; err != nil {
+		return false
+	}
+	return peran.Wajib2FA
+}
+
+// perluAktifkan2FA bernilai true jika peran mewajibkan 2FA tetapi akun belum mengaktifkannya.
+func perluAktifkan2FA(kodePeran string, idAkun uint) bool {
+	if !peranWajib2FA(kodePeran) {
+		return false
+	}
+	var akun Akun
+	if err := DB.Select("totp_aktif").First(&akun, idAkun).Error; err != nil {
+		return true
+	}
+	return !akun.TOTPAktif
+}
+
+// ----- Login langkah kedua -----
+
+// Login2FAHandler: POST /api/login/2fa
+// Langkah kedua login untuk akun dengan 2FA aktif. Cookie sesi baru diterbitkan
+// setelah kode TOTP / kode pemulihan terverifikasi.
+func Login2FAHandler(c *gin.Context) {
+	var req Login2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tiket dan kode wajib diisi"})
+		return
+	}
+
+	// Tiket belum dicabut di sini agar user dapat mengulang kode yang salah ketik.
+	klaim, err := periksaTiket(req.Tiket, tujuan2FA)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var akun Akun
+	if err := DB.First(&akun, klaim.IDAkun).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Akun tidak ditemukan"})
+		return
+	}
+
+	// Kode yang salah dihitung sebagai login gagal sehingga tebakan kode ikut dibatasi.
+	if sisa := cekKunciLogin(akun.NIP, c.ClientIP()); sisa > 0 {
+		catatPercobaanLogin(c, akun.NIP, false, "terkunci")
+		tolakLoginTerkunci(c, sisa)
+		return
+	}
+	ok, err := verifikasiFaktorKedua(DB, &akun, req.Kode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memverifikasi kode"})
+		return
+	}
+	if !ok {
+		log.Println("[LOGIN FAILED] Kode 2FA salah, NIP:", akun.NIP)
+		catatLoginGagal(akun.NIP, c.ClientIP())
+		catatPercobaanLogin(c, akun.NIP, false, "kode_2fa_salah")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kode verifikasi salah atau sudah dipakai"})
+		return
+	}
+
+	if err := cabutJTI(DB, klaim.ID, klaim.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses tiket login"})
+		return
+	}
+	catatLoginBerhasil(akun.NIP)
+	lanjutkanLogin(c, &akun, klaim.Role, klaim.IDPeran)
+}
+
+// ----- Pengelolaan 2FA oleh pemilik akun -----
+
+// akunPengguna memuat akun milik user yang login.
+func akunPengguna(c *gin.Context) (*Akun, bool) {
+	var akun Akun
+	if err := DB.First(&akun, ambilClaims(c).IDAkun).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Akun tidak ditemukan"})
+		return nil, false
+	}
+	return &akun, true
+}
+
+// GetStatus2FA: GET /api/me/2fa
+func GetStatus2FA(c *gin.Context) {
+	akun, ok := akunPengguna(c)
+	if !ok {
+		return
+	}
+	var sisa int64
+	DB.Model(&KodePemulihan{}).Where("id_akun = ? AND dipakai_pada IS NULL", akun.ID).Count(&sisa)
+	c.JSON(http.StatusOK, gin.H{
+		"aktif":               akun.TOTPAktif,
+		"aktif_pada":          akun.TOTPAktifPada,
+		"wajib":               peranWajib2FA(ambilClaims(c).Peran),
+		"sisa_kode_pemulihan": sisa,
+	})
+}
+
+// Enroll2FA: POST /api/me/2fa/enroll
+// Membuat rahasia TOTP baru (belum aktif) dan mengembalikan URI provisioning untuk QR code.
+func Enroll2FA(c *gin.Context) {
+	akun, ok := akunPengguna(c)
+	if !ok {
+		return
+	}
+	if akun.TOTPAktif {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA sudah aktif. Nonaktifkan terlebih dahulu untuk mendaftarkan perangkat baru."})
+		return
+	}
+	rahasia, err := buatRahasiaTOTP()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat rahasia 2FA"})
+		return
+	}
+	if err := DB.Model(akun).Updates(map[string]interface{}{"totp_rahasia": rahasia, "totp_langkah_terakhir": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan rahasia 2FA"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rahasia":          rahasia,
+		"uri_provisioning": uriProvisioning(rahasia, akun.NIP),
+		"message":          "Pindai QR code di aplikasi authenticator, lalu konfirmasi dengan kode 6 digit",
+	})
+}
+
+// Konfirmasi2FA: POST /api/me/2fa/confirm
+// Mengaktifkan 2FA setelah user membuktikan authenticator-nya menghasilkan kode yang benar.
+// Kode pemulihan hanya dikembalikan sekali pada respons ini.
+func Konfirmasi2FA(c *gin.Context) {
+	var req Kode2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode wajib diisi"})
+		return
+	}
+	akun, ok := akunPengguna(c)
+	if !ok {
+		return
+	}
+	if akun.TOTPAktif {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA sudah aktif"})
+		return
+	}
+	if akun.TOTPRahasia == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lakukan pendaftaran 2FA terlebih dahulu"})
+		return
+	}
+
+	var kodePemulihan []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		cocok, err := pakaiTOTP(tx, akun, normalisasiKode(req.Kode))
+		if err != nil {
+			return err
+		}
+		if !cocok {
+			return errParameter("Kode verifikasi salah")
+		}
+		now := time.Now()
+		if err := tx.Model(akun).Updates(map[string]interface{}{"totp_aktif": true, "totp_aktif_pada": now}).Error; err != nil {
+			return err
+		}
+		if kodePemulihan, err = buatKodePemulihan(tx, akun.ID); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "2fa.aktifkan", "akun:"+strconv.FormatUint(uint64(akun.ID), 10), "2FA TOTP diaktifkan")
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Token terbatas (peran wajib 2FA) diganti dengan token penuh untuk sesi saat ini.
+	var accessToken string
+	if claims := ambilClaims(c); claims.Terbatas {
+		if accessToken, err = terbitkanUlangAksesSesi(c, claims); err != nil {
+			log.Println("[2FA] Gagal menerbitkan ulang access token:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, tambahTokenAkses(c, gin.H{
+		"success":        true,
+		"message":        "2FA berhasil diaktifkan. Simpan kode pemulihan di tempat yang aman.",
+		"kode_pemulihan": kodePemulihan,
+	}, accessToken))
+}
+
+// terbitkanUlangAksesSesi mencabut access token saat ini dan menerbitkan penggantinya untuk sesi yang sama.
+// Klien cookie menerima token baru lewat cookie. Klien yang mengirim token lewat header Authorization
+// tidak memakai cookie, sehingga token baru dikembalikan untuk disertakan di body respons (lihat tambahTokenAkses).
+func terbitkanUlangAksesSesi(c *gin.Context, claims *Claims) (string, error) {
+	var sesi Sesi
+	if err := DB.First(&sesi, claims.IDSesi).Error; err != nil {
+		return "", err
+	}
+	peran, _, err := peranUntukUser(claims.Role, claims.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := cabutJTI(DB, claims.RegisteredClaims.ID, claims.ExpiresAt.Time); err != nil {
+		return "", err
+	}
+	accessToken, err := terbitkanAccessToken(peran.claims(claims.IDAkun), &sesi)
+	if err != nil {
+		return "", err
+	}
+	if _, bearer := bacaHeaderBearer(c); !bearer {
+		setCookieAkses(c, peran.Role, accessToken)
+	}
+	return accessToken, nil
+}
+
+// tambahTokenAkses menyertakan access token hasil terbitkanUlangAksesSesi di respons untuk klien
+// yang mengirim token lewat header Authorization; token lamanya sudah dicabut.
+func tambahTokenAkses(c *gin.Context, respons gin.H, accessToken string) gin.H {
+	if _, bearer := bacaHeaderBearer(c); bearer && accessToken != "" {
+		respons["access_token"] = accessToken
+	}
+	return respons
+}
+
+// Nonaktifkan2FA: POST /api/me/2fa/disable
+// Membutuhkan password dan kode 2FA. Ditolak jika salah satu peran akun mewajibkan 2FA.
+func Nonaktifkan2FA(c *gin.Context) {
+	var req Nonaktifkan2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password dan kode wajib diisi"})
+		return
+	}
+	akun, ok := akunPengguna(c)
+	if !ok {
+		return
+	}
+	if !akun.TOTPAktif {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA belum aktif"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(akun.Password), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password salah"})
+		return
+	}
+	daftar, err := daftarPeranAkun(akun.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat peran akun"})
+		return
+	}
+	for _, p := range daftar {
+		if peranWajib2FA(p.KodePeran) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "2FA wajib untuk peran " + p.KodePeran + " dan tidak dapat dinonaktifkan"})
+			return
+		}
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		cocok, err := verifikasiFaktorKedua(tx, akun, req.Kode)
+		if err != nil {
+			return err
+		}
+		if !cocok {
+			return errParameter("Kode verifikasi salah atau sudah dipakai")
+		}
+		if err := hapus2FA(tx, akun.ID); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "2fa.nonaktifkan", "akun:"+strconv.FormatUint(uint64(akun.ID), 10), "2FA dinonaktifkan oleh pemilik akun")
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "2FA berhasil dinonaktifkan"})
+}
+
+// hapus2FA menghapus rahasia TOTP dan seluruh kode pemulihan akun.
+func hapus2FA(tx *gorm.DB, idAkun uint) error {
+	if err := tx.Model(&Akun{}).Where("id_akun = ?", idAkun).Updates(map[string]interface{}{
+		"totp_rahasia":          "",
+		"totp_aktif":            false,
+		"totp_aktif_pada":       nil,
+		"totp_langkah_terakhir": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id_akun = ?", idAkun).Delete(&KodePemulihan{}).Error
+}
+
+// BuatUlangKodePemulihan: POST /api/me/2fa/recovery-codes
+// Mengganti seluruh kode pemulihan; kode lama tidak berlaku lagi.
+func BuatUlangKodePemulihan(c *gin.Context) {
+	var req Kode2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode wajib diisi"})
+		return
+	}
+	akun, ok := akunPengguna(c)
+	if !ok {
+		return
+	}
+	if !akun.TOTPAktif {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA belum aktif"})
+		return
+	}
+
+	var kodePemulihan []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		cocok, err := pakaiTOTP(tx, akun, normalisasiKode(req.Kode))
+		if err != nil {
+			return err
+		}
+		if !cocok {
+			return errParameter("Kode verifikasi salah")
+		}
+		if kodePemulihan, err = buatKodePemulihan(tx, akun.ID); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "2fa.kode_pemulihan", "akun:"+strconv.FormatUint(uint64(akun.ID), 10), "Kode pemulihan dibuat ulang")
+	})
+	if err != nil {
+		c.JSON(statusUntukError(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "kode_pemulihan": kodePemulihan})
+}
+
+// ----- Handler admin -----
+
+// SetWajib2FA: PUT /api/peran/:kode/wajib-2fa, mewajibkan / membebaskan 2FA untuk suatu peran
+func SetWajib2FA(c *gin.Context) {
+	var req Wajib2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field wajib (true/false) harus diisi"})
+		return
+	}
+	var peran Peran
+	if err := DB.First(&peran, "kode = ?", c.Param("kode")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Peran tidak ditemukan"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&peran).Update("wajib_2fa", *req.Wajib).Error; err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "peran.wajib_2fa", "peran:"+peran.Kode, fmt.Sprintf("Wajib 2FA: %t", *req.Wajib))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan pengaturan 2FA peran"})
+		return
+	}
+	c.JSON(http.StatusOK, peran)
+}
+
+// Reset2FAUser: POST /api/users/:role/:id/2fa/reset
+// Admin menghapus 2FA user yang kehilangan authenticator sekaligus kode pemulihannya.
+// Seluruh sesi user dicabut; user dapat mendaftarkan ulang 2FA setelah login.
+func Reset2FAUser(c *gin.Context) {
+	role := c.Param("role")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID user tidak valid"})
+		return
+	}
+	_, idAkun, err := peranUntukUser(role, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := hapus2FA(tx, idAkun); err != nil {
+			return err
+		}
+		if _, err := cabutSemuaSesi(tx, idAkun, 0, "2FA direset admin"); err != nil {
+			return err
+		}
+		return catatAudit(tx, c, "2fa.reset", targetUser(role, uint(id)), "2FA direset oleh admin")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mereset 2FA"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "2FA user berhasil direset"})
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ========= SIKLUS VALIDASI STANDAR PELAYANAN =========
+//
+// Menunggu Validasi -> Disetujui / Ditolak / Perlu Revisi (oleh Pemda).
+// Standar yang Perlu Revisi atau Ditolak dapat diperbaiki OPD lalu diajukan ulang
+// menjadi Menunggu Validasi. OPD juga dapat menarik versi yang belum disetujui
+// (Ditarik). Keputusan berlaku untuk versi terbaru; standar yang Disetujui hanya
+// dapat diubah lewat versi baru (lihat versi_standar.go).
+
+// Daftar status yang sah untuk JenisPelayanan.StatusValidasi.
+const (
+	StatusValidasiMenunggu    = "Menunggu Validasi"
+	StatusValidasiDisetujui   = "Disetujui"
+	StatusValidasiDitolak     = "Ditolak"
+	StatusValidasiPerluRevisi = "Perlu Revisi"
+	StatusValidasiDitarik     = "Ditarik" // Ditarik OPD sebelum diputuskan validator
+)
+
+// keputusanValidasi adalah status yang boleh dikirim validator.
+var keputusanValidasi = []string{StatusValidasiDisetujui, StatusValidasiDitolak, StatusValidasiPerluRevisi}
+
+// statusValidasiSah memeriksa apakah status termasuk enum StatusValidasi.
+func statusValidasiSah(status string) bool {
+	return status == StatusValidasiMenunggu || status == StatusValidasiDitarik || mengandung(keputusanValidasi, status)
+}
+
+// dapatDiajukanUlang: standar yang dikembalikan, ditolak, atau ditarik boleh diperbaiki lalu
+// diajukan ulang sebagai versi baru (lihat versi_standar.go).
+func dapatDiajukanUlang(status string) bool {
+	return status == StatusValidasiPerluRevisi || status == StatusValidasiDitolak || status == StatusValidasiDitarik
+}
+
+// dapatDitarik: versi yang belum disetujui dan masih menunggu tindakan dapat ditarik OPD.
+func dapatDitarik(status string) bool {
+	return status == StatusValidasiMenunggu || status == StatusValidasiPerluRevisi
+}
+
+// fieldKomentarRevisi adalah field standar (nama JSON) yang boleh diberi komentar revisi.
+var fieldKomentarRevisi = func() map[string]bool {
+	hasil := make(map[string]bool, len(fieldKonten))
+	for _, field := range fieldKonten {
+		hasil[field] = true
+	}
+	return hasil
+}()
+
+// bersihkanKomentarRevisi membuang komentar kosong dan menolak field yang tidak dikenal.
+func bersihkanKomentarRevisi(komentar map[string]string) (map[string]string, error) {
+	hasil := make(map[string]string, len(komentar))
+	for field, isi := range komentar {
+		if !fieldKomentarRevisi[field] {
+			return nil, errors.New("Field '" + field + "' tidak dapat diberi komentar revisi")
+		}
+		if isi = strings.TrimSpace(isi); isi != "" {
+			hasil[field] = isi
+		}
+	}
+	return hasil, nil
+}
+
+// muatStandarLengkap memuat relasi dan item persyaratan untuk response. Komentar revisi yang
+// dimuat hanya milik versi terbaru dan hanya selama standar menunggu perbaikan OPD.
+func muatStandarLengkap(standar *JenisPelayanan) error {
+	err := DB.Preload("OPD").Preload("ValidatorPemda").
+		Preload("KomentarRevisi", func(db *gorm.DB) *gorm.DB {
+			if !dapatDiajukanUlang(standar.StatusValidasi) {
+				return db.Where("1 = 0")
+			}
+			return db.Where("id_versi_standar = (?)",
+				DB.Model(&VersiStandar{}).Select("id_versi_standar").
+					Where("id_jenis_pelayanan = ? AND nomor = ?", standar.ID, standar.VersiTerbaru),
+			).Order("id_komentar_revisi")
+		}).
+		First(standar, standar.ID).Error
+	if err != nil {
+		return err
+	}
+	daftar := []JenisPelayanan{*standar}
+	if err := isiDaftarPersyaratan(DB, daftar); err != nil {
+		return err
+	}
+	standar.DaftarPersyaratan = daftar[0].DaftarPersyaratan
+	return nil
+}
+
+// targetStandar adalah format target log audit & notifikasi untuk standar pelayanan.
+func targetStandar(id uint) string {
+	return "standar:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ValidateJenisPelayanan: Memvalidasi versi terbaru standar pelayanan (Hanya oleh User Pemda).
+// Ditolak dan Perlu Revisi wajib disertai keterangan atau komentar per field.
+// Versi yang Disetujui menjadi versi aktif menggantikan versi aktif sebelumnya.
+func ValidateJenisPelayanan(c *gin.Context) {
+	id := c.Param("id")
+	var standar JenisPelayanan
+
+	// 1. Cari standar
+	if err := DB.First(&standar, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standar Pelayanan tidak ditemukan"})
+		return
+	}
+	if !cekBelumDiarsipkan(c, &standar) {
+		return
+	}
+
+	// 2. Cek status: hanya standar yang menunggu validasi yang dapat diputuskan
+	if standar.StatusValidasi != StatusValidasiMenunggu {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar ini berstatus '" + standar.StatusValidasi + "', bukan '" + StatusValidasiMenunggu + "'"})
+		return
+	}
+
+	// 3. Bind & validasi request body
+	var req ValidasiRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return
+	}
+	if !mengandung(keputusanValidasi, req.StatusValidasi) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":           "Status validasi harus salah satu dari: " + strings.Join(keputusanValidasi, ", "),
+			"status_validasi": keputusanValidasi,
+		})
+		return
+	}
+	req.KeteranganValidasi = strings.TrimSpace(req.KeteranganValidasi)
+	komentar, err := bersihkanKomentarRevisi(req.KomentarRevisi)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case req.StatusValidasi == StatusValidasiDisetujui && len(komentar) > 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Komentar revisi hanya untuk status Ditolak atau Perlu Revisi"})
+		return
+	case req.StatusValidasi != StatusValidasiDisetujui && req.KeteranganValidasi == "" && len(komentar) == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Keterangan atau komentar revisi wajib diisi untuk status '" + req.StatusValidasi + "'"})
+		return
+	}
+
+	// 4. Simpan keputusan, komentar per field, audit, dan beri tahu OPD pemilik
+	claims := ambilClaims(c)
+	validatorIDFromToken := claims.ID
+	now := time.Now()
+	var versi VersiStandar
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// Update bersyarat: gagal jika standar sudah diputuskan validator lain, ditarik / diajukan
+		// ulang OPD, atau diarsipkan sejak dibaca. Baris standar tetap terkunci sampai transaksi
+		// selesai, sehingga data yang dibaca ulang di bawah tidak berubah lagi.
+		hasil := tx.Model(&JenisPelayanan{}).
+			Where("id_jenis_pelayanan = ? AND status_validasi = ? AND versi_terbaru = ? AND diarsipkan_pada IS NULL",
+				standar.ID, StatusValidasiMenunggu, standar.VersiTerbaru).
+			Update("status_validasi", req.StatusValidasi)
+		if hasil.Error != nil {
+			return hasil.Error
+		}
+		if hasil.RowsAffected == 0 {
+			return errKonflik("Standar ini baru saja diputuskan atau diubah pengguna lain; muat ulang data")
+		}
+		idStandar := standar.ID
+		standar = JenisPelayanan{}
+		if err := tx.First(&standar, idStandar).Error; err != nil {
+			return err
+		}
+		terbaru, err := cariVersi(tx, standar.ID, standar.VersiTerbaru)
+		if err != nil {
+			return err
+		}
+		versi = terbaru
+
+		if req.KeteranganValidasi != "" {
+			standar.KeteranganValidasi = &req.KeteranganValidasi
+		} else {
+			standar.KeteranganValidasi = nil
+		}
+		standar.IDValidatorPemda = &validatorIDFromToken
+		standar.TanggalValidasi = &now
+		versi.StatusValidasi = standar.StatusValidasi
+		versi.KeteranganValidasi = standar.KeteranganValidasi
+		versi.IDValidatorPemda = standar.IDValidatorPemda
+		versi.TanggalValidasi = standar.TanggalValidasi
+
+		if standar.StatusValidasi == StatusValidasiDisetujui {
+			if err := setujuiVersi(tx, &standar, &versi, now); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit(clause.Associations).Save(&versi).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(&standar).Error; err != nil {
+			return err
+		}
+		fields := make([]string, 0, len(komentar))
+		for field := range komentar {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			baris := KomentarRevisi{
+				IDJenisPelayanan: standar.ID,
+				IDVersiStandar:   &versi.ID,
+				Putaran:          standar.JumlahRevisi,
+				Field:            field,
+				Komentar:         komentar[field],
+				IDValidatorPemda: validatorIDFromToken,
+			}
+			if err := tx.Create(&baris).Error; err != nil {
+				return err
+			}
+		}
+		if err := catatAudit(tx, c, "standar.validasi", targetStandar(standar.ID), "Versi "+strconv.Itoa(versi.Nomor)+": "+standar.StatusValidasi); err != nil {
+			return err
+		}
+		pesan := "Standar '" + standar.NamaStandar + "' " + strings.ToLower(standar.StatusValidasi) + " oleh " + claims.Nama
+		if req.KeteranganValidasi != "" {
+			pesan += ": " + req.KeteranganValidasi
+		}
+		return beritahuPemegangIzin(tx, "opd", "standar.create", standar.IDOPD,
+			"standar.divalidasi", "Standar pelayanan "+strings.ToLower(standar.StatusValidasi), pesan, targetStandar(standar.ID))
+	})
+	if err != nil {
+		if statusUntukError(err) == http.StatusConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan hasil validasi"})
+		return
+	}
+
+	// 6. Response
+	muatStandarLengkap(&standar)
+	c.JSON(http.StatusOK, standar)
+}
+
+// AjukanUlangJenisPelayanan: POST /api/standar-pelayanan/:id/ajukan-ulang
+// OPD memperbaiki standar yang Perlu Revisi / Ditolak / Ditarik (body berisi field yang diubah,
+// boleh "{}"). Perbaikan disimpan sebagai versi baru yang kembali ke antrean validasi.
+func AjukanUlangJenisPelayanan(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) || !cekBelumDiarsipkan(c, &standar) {
+		return
+	}
+	if !dapatDiajukanUlang(standar.StatusValidasi) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar berstatus '" + standar.StatusValidasi + "' tidak dapat diajukan ulang"})
+		return
+	}
+	if !ajukanUlang(c, &standar) {
+		return
+	}
+
+	muatStandarLengkap(&standar)
+	c.JSON(http.StatusOK, standar)
+}
+
+// ajukanUlang menyimpan perbaikan sebagai versi baru dari versi terbaru.
+// Jika gagal, respons error sudah dikirim dan fungsi mengembalikan false.
+func ajukanUlang(c *gin.Context, standar *JenisPelayanan) bool {
+	now := time.Now()
+	standar.JumlahRevisi++
+	standar.DiajukanUlangPada = &now
+	return ajukanVersiBaru(c, standar, standar.VersiTerbaru,
+		"standar.ajukan_ulang", "standar.diajukan_ulang", "Standar pelayanan diajukan ulang") != nil
+}
+
+// TarikJenisPelayanan: POST /api/standar-pelayanan/:id/tarik (body opsional: {"alasan": "..."})
+// OPD menarik versi yang Menunggu Validasi / Perlu Revisi. Jika standar sudah memiliki versi
+// aktif, standar kembali berstatus Disetujui dengan versi aktif tersebut; jika belum, standar
+// berstatus Ditarik dan dapat diajukan ulang kapan saja.
+func TarikJenisPelayanan(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) || !cekBelumDiarsipkan(c, &standar) {
+		return
+	}
+	if !dapatDitarik(standar.StatusValidasi) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar berstatus '" + standar.StatusValidasi + "' tidak dapat ditarik"})
+		return
+	}
+	var req TarikStandarRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+			return
+		}
+	}
+	req.Alasan = strings.TrimSpace(req.Alasan)
+
+	versi, err := cariVersi(DB, standar.ID, standar.VersiTerbaru)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Versi terbaru standar tidak ditemukan"})
+		return
+	}
+	statusLama := versi.StatusValidasi
+	versi.StatusValidasi = StatusValidasiDitarik
+	versi.KeteranganValidasi = nil
+	if req.Alasan != "" {
+		versi.KeteranganValidasi = &req.Alasan
+	}
+
+	// Status standar mengikuti versi aktif jika ada, selain itu versi yang ditarik
+	if standar.VersiAktif != 0 {
+		aktif, err := cariVersi(DB, standar.ID, standar.VersiAktif)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Versi aktif standar tidak ditemukan"})
+			return
+		}
+		standar.StatusValidasi = aktif.StatusValidasi
+		standar.KeteranganValidasi = aktif.KeteranganValidasi
+		standar.IDValidatorPemda = aktif.IDValidatorPemda
+		standar.TanggalValidasi = aktif.TanggalValidasi
+	} else {
+		standar.StatusValidasi = StatusValidasiDitarik
+		standar.KeteranganValidasi = versi.KeteranganValidasi
+		standar.IDValidatorPemda = nil
+		standar.TanggalValidasi = nil
+	}
+
+	claims := ambilClaims(c)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&versi).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(&standar).Error; err != nil {
+			return err
+		}
+		keterangan := "Versi " + strconv.Itoa(versi.Nomor) + " ditarik dari status " + statusLama
+		if req.Alasan != "" {
+			keterangan += ": " + req.Alasan
+		}
+		if err := catatAudit(tx, c, "standar.tarik", targetStandar(standar.ID), keterangan); err != nil {
+			return err
+		}
+		// Validator tidak perlu lagi memproses versi ini
+		if statusLama != StatusValidasiMenunggu {
+			return nil
+		}
+		return beritahuPemegangIzin(tx, "pemda", "standar.validate", 0,
+			"standar.ditarik", "Standar pelayanan ditarik",
+			"Standar '"+versi.NamaStandar+"' versi "+strconv.Itoa(versi.Nomor)+" ditarik oleh "+claims.Nama,
+			targetStandar(standar.ID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menarik standar"})
+		return
+	}
+
+	muatStandarLengkap(&standar)
+	c.JSON(http.StatusOK, standar)
+}
+
+// GetKomentarRevisi: GET /api/standar-pelayanan/:id/komentar-revisi
+// Seluruh komentar revisi dari semua putaran validasi, putaran terbaru lebih dulu.
+func GetKomentarRevisi(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) {
+		return
+	}
+
+	var komentar []KomentarRevisi
+	if err := DB.Where("id_jenis_pelayanan = ?", standar.ID).
+		Order("putaran DESC, id_komentar_revisi ASC").
+		Find(&komentar).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil komentar revisi"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": komentar})
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ========= VERSI STANDAR PELAYANAN =========
+//
+// Setiap perubahan isi standar menghasilkan VersiStandar baru bernomor urut yang harus
+// divalidasi Pemda. Versi aktif (terakhir Disetujui) tetap berlaku hingga versi baru
+// disetujui, sehingga pengajuan tetap dapat dibuat selama revisi berlangsung.
+
+// kontenStandar mengambil isi standar dari kolom JenisPelayanan.
+func kontenStandar(j *JenisPelayanan) KontenStandar {
+	return KontenStandar{
+		NamaStandar:                 j.NamaStandar,
+		DasarHukum:                  j.DasarHukum,
+		Persyaratan:                 j.Persyaratan,
+		SistemMekanismeProsedurPath: j.SistemMekanismeProsedurPath,
+		WaktuPelayanan:              j.WaktuPelayanan,
+		WaktuPelayananNilai:         j.WaktuPelayananNilai,
+		WaktuPelayananSatuan:        j.WaktuPelayananSatuan,
+		BiayaTarif:                  j.BiayaTarif,
+		ProdukPelayanan:             j.ProdukPelayanan,
+		Fasilitas:                   j.Fasilitas,
+		KompetensiPelaksana:         j.KompetensiPelaksana,
+		PengawasanInternal:          j.PengawasanInternal,
+		JumlahPelaksana:             j.JumlahPelaksana,
+		JaminanPelayanan:            j.JaminanPelayanan,
+		SaranDanMasukan:             j.SaranDanMasukan,
+		JaminanKeamanan:             j.JaminanKeamanan,
+		EvaluasiKinerja:             j.EvaluasiKinerja,
+	}
+}
+
+// terapkanKonten menyalin isi versi ke kolom JenisPelayanan.
+func terapkanKonten(j *JenisPelayanan, k KontenStandar) {
+	j.NamaStandar = k.NamaStandar
+	j.DasarHukum = k.DasarHukum
+	j.Persyaratan = k.Persyaratan
+	j.SistemMekanismeProsedurPath = k.SistemMekanismeProsedurPath
+	j.WaktuPelayanan = k.WaktuPelayanan
+	j.WaktuPelayananNilai = k.WaktuPelayananNilai
+	j.WaktuPelayananSatuan = k.WaktuPelayananSatuan
+	j.BiayaTarif = k.BiayaTarif
+	j.ProdukPelayanan = k.ProdukPelayanan
+	j.Fasilitas = k.Fasilitas
+	j.KompetensiPelaksana = k.KompetensiPelaksana
+	j.PengawasanInternal = k.PengawasanInternal
+	j.JumlahPelaksana = k.JumlahPelaksana
+	j.JaminanPelayanan = k.JaminanPelayanan
+	j.SaranDanMasukan = k.SaranDanMasukan
+	j.JaminanKeamanan = k.JaminanKeamanan
+	j.EvaluasiKinerja = k.EvaluasiKinerja
+}
+
+// normalisasiKonten melengkapi durasi SLA terstruktur seperti pada pembuatan standar.
+func normalisasiKonten(k *KontenStandar) {
+	var tmp JenisPelayanan
+	terapkanKonten(&tmp, *k)
+	normalisasiWaktuPelayanan(&tmp)
+	*k = kontenStandar(&tmp)
+}
+
+// fieldKonten adalah nama JSON field KontenStandar sesuai urutan struct.
+var fieldKonten = func() []string {
+	t := reflect.TypeOf(KontenStandar{})
+	hasil := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		hasil = append(hasil, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return hasil
+}()
+
+// PerbedaanField adalah satu field yang berbeda antara dua versi.
+type PerbedaanField struct {
+	Field string      `json:"field"`
+	Dari  interface{} `json:"dari"`
+	Ke    interface{} `json:"ke"`
+}
+
+// bandingkanKonten mengembalikan field yang berbeda antara dua isi standar, sesuai urutan struct.
+func bandingkanKonten(dari, ke KontenStandar) []PerbedaanField {
+	vDari, vKe := reflect.ValueOf(dari), reflect.ValueOf(ke)
+	hasil := []PerbedaanField{}
+	for i, field := range fieldKonten {
+		a, b := vDari.Field(i).Interface(), vKe.Field(i).Interface()
+		if a != b {
+			hasil = append(hasil, PerbedaanField{Field: field, Dari: a, Ke: b})
+		}
+	}
+	return hasil
+}
+
+// cariVersi mengambil versi standar berdasarkan nomornya beserta item persyaratannya.
+func cariVersi(tx *gorm.DB, idJenis uint, nomor int) (VersiStandar, error) {
+	var versi VersiStandar
+	err := tx.Preload("DaftarPersyaratan", urutPersyaratan).Where("id_jenis_pelayanan = ? AND nomor = ?", idJenis, nomor).First(&versi).Error
+	return versi, err
+}
+
+// urutPersyaratan mengurutkan preload item persyaratan.
+func urutPersyaratan(db *gorm.DB) *gorm.DB {
+	return db.Order("urutan")
+}
+
+// idVersiAktif mengembalikan ID versi aktif (versi terakhir yang Disetujui) standar. Pengajuan
+// selalu terikat ke versi aktif; standar yang belum pernah disetujui tidak dapat diajukan.
+func idVersiAktif(tx *gorm.DB, j *JenisPelayanan) (*uint, error) {
+	if j.VersiAktif == 0 {
+		return nil, errParameter("Standar Pelayanan '" + j.NamaStandar + "' belum memiliki versi yang disetujui")
+	}
+	versi, err := cariVersi(tx, j.ID, j.VersiAktif)
+	if err != nil {
+		return nil, err
+	}
+	return &versi.ID, nil
+}
+
+// kunciStandarPengajuan dipanggil di dalam transaksi yang membuat / memindahkan pengajuan ke
+// standar idJenis. Baris standar dikunci FOR SHARE lalu arsip dan versi aktifnya diperiksa ulang;
+// DeleteJenisPelayanan mengunci baris yang sama FOR UPDATE, sehingga pengarsipan dan pengajuan
+// baru tidak dapat saling mendahului di antara pemeriksaan dan penyimpanan.
+func kunciStandarPengajuan(tx *gorm.DB, idJenis uint) (*uint, error) {
+	var jenis JenisPelayanan
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&jenis, idJenis).Error; err != nil {
+		return nil, err
+	}
+	if jenis.DiarsipkanPada != nil {
+		return nil, errParameter("Standar pelayanan sudah diarsipkan")
+	}
+	return idVersiAktif(tx, &jenis)
+}
+
+// buatVersiPertama membuat versi 1 dari isi dan status JenisPelayanan saat ini.
+// Item persyaratan diambil dari DaftarPersyaratan, atau dipecah dari teks Persyaratan.
+func buatVersiPertama(tx *gorm.DB, j *JenisPelayanan, idPembuat *uint) error {
+	items := salinItemPersyaratan(j.DaftarPersyaratan)
+	if len(items) == 0 {
+		items = parsePersyaratanTeks(j.Persyaratan)
+	}
+	versi := VersiStandar{
+		IDJenisPelayanan:   j.ID,
+		Nomor:              1,
+		KontenStandar:      kontenStandar(j),
+		StatusValidasi:     j.StatusValidasi,
+		KeteranganValidasi: j.KeteranganValidasi,
+		IDValidatorPemda:   j.IDValidatorPemda,
+		TanggalValidasi:    j.TanggalValidasi,
+		IDUserOPDPembuat:   idPembuat,
+		DaftarPersyaratan:  items,
+	}
+	j.VersiTerbaru = 1
+	if j.StatusValidasi == StatusValidasiDisetujui {
+		versi.BerlakuSejak = j.TanggalValidasi
+		j.VersiAktif = 1
+	}
+	if err := tx.Create(&versi).Error; err != nil {
+		return err
+	}
+	j.DaftarPersyaratan = versi.DaftarPersyaratan
+	return tx.Model(&JenisPelayanan{}).Where("id_jenis_pelayanan = ?", j.ID).
+		Updates(map[string]interface{}{"versi_aktif": j.VersiAktif, "versi_terbaru": j.VersiTerbaru}).Error
+}
+
+// migrasiVersiStandar membuat versi 1 untuk standar lama, lalu menautkan pengajuan lama ke versi tersebut.
+func migrasiVersiStandar() {
+	var standar []JenisPelayanan
+	DB.Where("versi_terbaru = 0").Find(&standar)
+	for i := range standar {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			return buatVersiPertama(tx, &standar[i], nil)
+		})
+		if err != nil {
+			log.Println("⚠ Gagal membuat versi standar", standar[i].ID, ":", err)
+		}
+	}
+
+	err := DB.Exec(`UPDATE form_pengajuan f SET id_versi_standar = v.id_versi_standar
+		FROM versi_standar v
+		WHERE f.id_versi_standar IS NULL AND v.id_jenis_pelayanan = f.id_jenis_pelayanan AND v.nomor = 1`).Error
+	if err != nil {
+		log.Println("⚠ Gagal menautkan pengajuan lama ke versi standar:", err)
+	}
+}
+
+// bodyStandar adalah body perubahan standar: field isi standar ditambah daftar persyaratan (opsional).
+type bodyStandar struct {
+	KontenStandar
+	DaftarPersyaratan *[]ItemPersyaratan `json:"daftar_persyaratan"`
+}
+
+// bindKontenStandar membaca perubahan dari body request (boleh "{}") di atas isi versi dasar
+// dan memvalidasinya. Tanpa daftar_persyaratan, item diambil dari versi dasar, atau dipecah
+// ulang dari teks jika teks persyaratan diubah. Jika gagal, respons error sudah dikirim dan ok bernilai false.
+func bindKontenStandar(c *gin.Context, standar *JenisPelayanan, dasar VersiStandar) (konten KontenStandar, items []ItemPersyaratan, ok bool) {
+	body := bodyStandar{KontenStandar: dasar.KontenStandar}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body tidak valid"})
+		return konten, nil, false
+	}
+	konten = body.KontenStandar
+	switch {
+	case body.DaftarPersyaratan != nil:
+		var err error
+		if items, err = normalisasiDaftarPersyaratan(*body.DaftarPersyaratan); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return konten, nil, false
+		}
+		konten.Persyaratan = teksPersyaratan(items)
+	case konten.Persyaratan != dasar.Persyaratan:
+		items = parsePersyaratanTeks(konten.Persyaratan)
+	default:
+		items = salinItemPersyaratan(dasar.DaftarPersyaratan)
+	}
+	konten.NamaStandar = strings.TrimSpace(konten.NamaStandar)
+	if konten.NamaStandar == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama standar tidak boleh kosong"})
+		return konten, nil, false
+	}
+	// Teks waktu pelayanan diubah tanpa angka baru: hitung ulang durasi SLA dari teks
+	if konten.WaktuPelayanan != dasar.WaktuPelayanan && konten.WaktuPelayananNilai == dasar.WaktuPelayananNilai {
+		konten.WaktuPelayananNilai = 0
+	}
+	normalisasiKonten(&konten)
+	if !satuanWaktuValid(konten.WaktuPelayananSatuan) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Satuan waktu pelayanan harus hari_kerja, hari_kalender, atau jam"})
+		return konten, nil, false
+	}
+	if dasar.StatusValidasi == StatusValidasiDisetujui && konten == dasar.KontenStandar &&
+		bandingkanPersyaratan(dasar.DaftarPersyaratan, items).kosong() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada perubahan dibanding versi aktif"})
+		return konten, nil, false
+	}
+
+	// NamaStandar unik antar standar; diperiksa sekarang agar tidak gagal saat disetujui
+	if konten.NamaStandar != standar.NamaStandar {
+		var jumlah int64
+		DB.Model(&JenisPelayanan{}).Where("nama_standar = ? AND id_jenis_pelayanan <> ?", konten.NamaStandar, standar.ID).Count(&jumlah)
+		if jumlah > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Nama standar sudah dipakai standar lain"})
+			return konten, nil, false
+		}
+	}
+	return konten, items, true
+}
+
+// ajukanVersiBaru membuat versi berikutnya dari versi bernomor nomorDasar ditambah perubahan di body
+// request, lalu mengembalikan standar ke antrean validasi dan memberi tahu validator.
+// Jika gagal, respons error sudah dikirim dan fungsi mengembalikan nil.
+func ajukanVersiBaru(c *gin.Context, standar *JenisPelayanan, nomorDasar int, aksi, jenisNotifikasi, judulNotifikasi string) *VersiStandar {
+	dasar, err := cariVersi(DB, standar.ID, nomorDasar)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Versi dasar standar tidak ditemukan"})
+		return nil
+	}
+	konten, items, ok := bindKontenStandar(c, standar, dasar)
+	if !ok {
+		return nil
+	}
+
+	// 3. Versi baru menunggu validasi; versi aktif (jika ada) tetap berlaku
+	claims := ambilClaims(c)
+	versi := VersiStandar{
+		IDJenisPelayanan:  standar.ID,
+		Nomor:             standar.VersiTerbaru + 1,
+		KontenStandar:     konten,
+		StatusValidasi:    StatusValidasiMenunggu,
+		DaftarPersyaratan: items,
+	}
+	if claims.Role == "opd" {
+		versi.IDUserOPDPembuat = &claims.ID
+	}
+	standar.VersiTerbaru = versi.Nomor
+	standar.StatusValidasi = StatusValidasiMenunggu
+	standar.KeteranganValidasi = nil
+	standar.IDValidatorPemda = nil
+	standar.TanggalValidasi = nil
+	if standar.VersiAktif == 0 {
+		terapkanKonten(standar, konten)
+	}
+	standar.OPD, standar.ValidatorPemda, standar.KomentarRevisi = OPD{}, nil, nil
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&versi).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(standar).Error; err != nil {
+			return err
+		}
+		nomor := strconv.Itoa(versi.Nomor)
+		if err := catatAudit(tx, c, aksi, targetStandar(standar.ID), "Versi "+nomor+" diajukan"); err != nil {
+			return err
+		}
+		return beritahuPemegangIzin(tx, "pemda", "standar.validate", 0,
+			jenisNotifikasi, judulNotifikasi,
+			"Standar '"+konten.NamaStandar+"' versi "+nomor+" diajukan oleh "+claims.Nama,
+			targetStandar(standar.ID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan versi baru: " + err.Error()})
+		return nil
+	}
+	return &versi
+}
+
+// muatStandarMilik mengambil standar dari :id dan memastikan user OPD hanya mengakses milik OPD-nya.
+// Jika gagal, respons error sudah dikirim dan fungsi mengembalikan false.
+func muatStandarMilik(c *gin.Context, standar *JenisPelayanan) bool {
+	if err := DB.First(standar, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Standar Pelayanan tidak ditemukan"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return cekMilikOPD(c, standar.IDOPD)
+}
+
+// cekBelumDiarsipkan menolak perubahan pada standar yang sudah diarsipkan.
+func cekBelumDiarsipkan(c *gin.Context, standar *JenisPelayanan) bool {
+	if standar.DiarsipkanPada != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar pelayanan sudah diarsipkan"})
+		return false
+	}
+	return true
+}
+
+// BuatVersiStandar: POST /api/standar-pelayanan/:id/versions
+// Merevisi standar yang sudah Disetujui (mis. perubahan dasar hukum atau tarif tahunan).
+// Body berisi field yang diubah; versi aktif tetap berlaku sampai versi baru disetujui.
+func BuatVersiStandar(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) || !cekBelumDiarsipkan(c, &standar) {
+		return
+	}
+	if standar.StatusValidasi != StatusValidasiDisetujui {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Versi baru hanya dapat dibuat dari standar berstatus '" + StatusValidasiDisetujui + "'. " +
+				"Standar '" + StatusValidasiPerluRevisi + "' / '" + StatusValidasiDitolak + "' diperbaiki lewat ajukan-ulang.",
+		})
+		return
+	}
+
+	// Versi baru selalu berangkat dari versi aktif, bukan dari draf yang pernah ditarik
+	versi := ajukanVersiBaru(c, &standar, standar.VersiAktif, "standar.versi_baru", "standar.versi_baru", "Versi baru standar pelayanan menunggu validasi")
+	if versi == nil {
+		return
+	}
+	c.JSON(http.StatusCreated, versi)
+}
+
+// GetAllVersiStandar: GET /api/standar-pelayanan/:id/versions (versi terbaru lebih dulu)
+func GetAllVersiStandar(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) {
+		return
+	}
+
+	var versi []VersiStandar
+	if err := DB.Preload("ValidatorPemda").Preload("DaftarPersyaratan", urutPersyaratan).Where("id_jenis_pelayanan = ?", standar.ID).
+		Order("nomor DESC").Find(&versi).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil versi standar"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":          versi,
+		"versi_aktif":   standar.VersiAktif,
+		"versi_terbaru": standar.VersiTerbaru,
+	})
+}
+
+// GetVersiStandar: GET /api/standar-pelayanan/:id/versions/:nomor
+func GetVersiStandar(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) {
+		return
+	}
+	nomor, err := strconv.Atoi(c.Param("nomor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nomor versi tidak valid"})
+		return
+	}
+
+	var versi VersiStandar
+	if err := DB.Preload("ValidatorPemda").Preload("DaftarPersyaratan", urutPersyaratan).Where("id_jenis_pelayanan = ? AND nomor = ?", standar.ID, nomor).
+		First(&versi).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versi standar tidak ditemukan"})
+		return
+	}
+	c.JSON(http.StatusOK, versi)
+}
+
+// GetDiffVersiStandar: GET /api/standar-pelayanan/:id/versions/diff?dari=&ke=
+// Perbandingan per field dan per item persyaratan antara dua versi. Default: versi aktif terhadap versi terbaru.
+func GetDiffVersiStandar(c *gin.Context) {
+	var standar JenisPelayanan
+	if !muatStandarMilik(c, &standar) {
+		return
+	}
+
+	nomorDari, nomorKe := standar.VersiAktif, standar.VersiTerbaru
+	for param, nomor := range map[string]*int{"dari": &nomorDari, "ke": &nomorKe} {
+		if s := c.Query(param); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "parameter " + param + " harus nomor versi >= 1"})
+				return
+			}
+			*nomor = n
+		}
+	}
+	if nomorDari == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standar belum memiliki versi aktif; isi parameter dari"})
+		return
+	}
+
+	dari, err := cariVersi(DB, standar.ID, nomorDari)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versi " + strconv.Itoa(nomorDari) + " tidak ditemukan"})
+		return
+	}
+	ke, err := cariVersi(DB, standar.ID, nomorKe)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versi " + strconv.Itoa(nomorKe) + " tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dari":        gin.H{"nomor": dari.Nomor, "status_validasi": dari.StatusValidasi, "created_at": dari.CreatedAt},
+		"ke":          gin.H{"nomor": ke.Nomor, "status_validasi": ke.StatusValidasi, "created_at": ke.CreatedAt},
+		"perbedaan":   bandingkanKonten(dari.KontenStandar, ke.KontenStandar),
+		"persyaratan": bandingkanPersyaratan(dari.DaftarPersyaratan, ke.DaftarPersyaratan),
+	})
+}
+
+// setujuiVersi menjadikan versi sebagai versi aktif: isinya disalin ke JenisPelayanan
+// dan versi aktif sebelumnya ditandai digantikan.
+func setujuiVersi(tx *gorm.DB, standar *JenisPelayanan, versi *VersiStandar, now time.Time) error {
+	if standar.VersiAktif != 0 {
+		if err := tx.Model(&VersiStandar{}).
+			Where("id_jenis_pelayanan = ? AND nomor = ?", standar.ID, standar.VersiAktif).
+			Update("digantikan_pada", now).Error; err != nil {
+			return err
+		}
+	}
+	versi.BerlakuSejak = &now
+	standar.VersiAktif = versi.Nomor
+	terapkanKonten(standar, versi.KontenStandar)
+	return nil
+}